@@ -0,0 +1,374 @@
+package airbytetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// AcceptanceConfig configures RunAcceptanceTests
+type AcceptanceConfig struct {
+	// Config is the connector config used for every command
+	// It is marshalled to json unless it is already a []byte or json.RawMessage
+	Config interface{}
+	// Streams limits the read tests to these stream names, all discovered streams are read if empty
+	Streams []string
+	// CursorPaths is the path of the cursor of every incremental stream in the state, e.g.
+	// {"users": {"streams", "users", "cursor"}}. The records of a read with the state can't be older than it
+	// Streams without a path keep their cursor at their name followed by their cursor field, like the airbyte
+	// python acceptance tests expect
+	CursorPaths map[string][]string
+}
+
+// RunAcceptanceTests runs the connector acceptance tests against your source, one subtest per check
+// like the airbyte python acceptance tests do
+//
+// Example usage
+//
+//	func TestAcceptance(t *testing.T) {
+//		airbytetest.RunAcceptanceTests(t, mysource.New(), airbytetest.AcceptanceConfig{
+//			Config: mysource.Config{APIKey: os.Getenv("API_KEY")},
+//		})
+//	}
+func RunAcceptanceTests(t *testing.T, src airbyte.Source, cfg AcceptanceConfig) {
+	t.Helper()
+
+	t.Run("TestSpec", func(t *testing.T) {
+		testSpec(t, src, cfg)
+	})
+
+	t.Run("TestConnection", func(t *testing.T) {
+		out, err := Check(src, cfg.Config)
+		if err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+		if out.ConnectionStatus != StatusSucceeded {
+			t.Fatalf("expected connection status %s, got %q", StatusSucceeded, out.ConnectionStatus)
+		}
+	})
+
+	var catalog *airbyte.Catalog
+	t.Run("TestDiscovery", func(t *testing.T) {
+		catalog = testDiscovery(t, src, cfg)
+	})
+	if catalog == nil {
+		t.Log("skipping read tests, discovery failed")
+		return
+	}
+
+	t.Run("TestBasicRead", func(t *testing.T) {
+		testBasicRead(t, src, cfg, catalog)
+	})
+
+	t.Run("TestIncremental", func(t *testing.T) {
+		testIncremental(t, src, cfg, catalog)
+	})
+}
+
+func testSpec(t *testing.T, src airbyte.Source, cfg AcceptanceConfig) {
+	out, err := Spec(src)
+	if err != nil {
+		t.Fatalf("spec failed: %v", err)
+	}
+	if out.Spec == nil {
+		t.Fatal("no spec emitted")
+	}
+
+	cs := out.Spec.ConnectionSpecification
+	if cs.Type != "object" {
+		t.Errorf("connectionSpecification type should be object, got %q", cs.Type)
+	}
+
+	root := airbyte.PropertySpec{
+		PropertyType: airbyte.PropertyType{Type: []airbyte.PropType{airbyte.Object}},
+		Properties:   cs.Properties.Properties,
+		Required:     cs.Required,
+	}
+	specErrs := validateSpec("", root)
+	for _, err := range specErrs {
+		t.Errorf("connectionSpecification is not a valid json schema: %v", err)
+	}
+	if len(specErrs) > 0 {
+		return
+	}
+
+	config, err := marshalInput(cfg.Config)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(config))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("config is not valid json: %v", err)
+	}
+	for _, err := range validateValue("", v, root) {
+		t.Errorf("config does not match connectionSpecification: %v", err)
+	}
+}
+
+func testDiscovery(t *testing.T, src airbyte.Source, cfg AcceptanceConfig) *airbyte.Catalog {
+	out, err := Discover(src, cfg.Config)
+	if err != nil {
+		t.Fatalf("discover failed: %v", err)
+	}
+	if out.Catalog == nil || len(out.Catalog.Streams) == 0 {
+		t.Fatal("no streams discovered")
+	}
+
+	seen := map[string]bool{}
+	for _, s := range out.Catalog.Streams {
+		if s.Name == "" {
+			t.Error("stream without a name")
+			continue
+		}
+		if seen[s.Name] {
+			t.Errorf("stream %s: discovered more than once", s.Name)
+		}
+		seen[s.Name] = true
+
+		if len(s.JSONSchema.Properties) == 0 {
+			t.Errorf("stream %s: json_schema has no properties", s.Name)
+		}
+		root := airbyte.PropertySpec{Properties: s.JSONSchema.Properties, Required: s.JSONSchema.Required}
+		for _, err := range validateSpec("", root) {
+			t.Errorf("stream %s: json_schema is not a valid json schema: %v", s.Name, err)
+		}
+
+		if len(s.SupportedSyncModes) == 0 {
+			t.Errorf("stream %s: no supported_sync_modes", s.Name)
+		}
+		if supportsMode(s, airbyte.SyncModeIncremental) && s.SourceDefinedCursor && len(s.DefaultCursorField) == 0 {
+			t.Errorf("stream %s: source defined cursor without default_cursor_field", s.Name)
+		}
+		if len(s.DefaultCursorField) > 0 && !hasPath(s.JSONSchema, s.DefaultCursorField) {
+			t.Errorf("stream %s: cursor field %s is not in the json_schema", s.Name, strings.Join(s.DefaultCursorField, "."))
+		}
+		for _, pk := range s.SourceDefinedPrimaryKey {
+			if !hasPath(s.JSONSchema, pk) {
+				t.Errorf("stream %s: primary key %s is not in the json_schema", s.Name, strings.Join(pk, "."))
+			}
+		}
+	}
+
+	return out.Catalog
+}
+
+func testBasicRead(t *testing.T, src airbyte.Source, cfg AcceptanceConfig, catalog *airbyte.Catalog) {
+	configured := filterCatalog(ConfigureCatalog(catalog, airbyte.SyncModeFullRefresh), cfg.Streams)
+	if len(configured.Streams) == 0 {
+		t.Fatal("no streams to read")
+	}
+
+	out, err := Read(src, cfg.Config, configured, nil)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	streams := map[string]airbyte.Stream{}
+	for _, cs := range configured.Streams {
+		streams[cs.Stream.Name] = cs.Stream
+	}
+
+	if out.RecordCount() == 0 {
+		t.Error("no records emitted")
+	}
+	for name, records := range out.Records {
+		s, ok := streams[name]
+		if !ok {
+			t.Errorf("stream %s: %d records emitted but the stream was not configured", name, len(records))
+			continue
+		}
+		for i, r := range records {
+			if err := ValidateRecord(r.Data, s.JSONSchema); err != nil {
+				t.Errorf("stream %s: record %d: %v", name, i, err)
+			}
+		}
+	}
+
+	for _, l := range out.Logs {
+		if l.Level == airbyte.LogLevelError || l.Level == airbyte.LogLevelFatal {
+			t.Errorf("%s log emitted: %s", l.Level, l.Message)
+		}
+	}
+
+	// a source only reads the configured streams
+	if len(configured.Streams) < 2 {
+		return
+	}
+	first := &airbyte.ConfiguredCatalog{Streams: configured.Streams[:1]}
+	out, err = Read(src, cfg.Config, first, nil)
+	if err != nil {
+		t.Fatalf("read of stream %s failed: %v", first.Streams[0].Stream.Name, err)
+	}
+	for name, records := range out.Records {
+		if name != first.Streams[0].Stream.Name {
+			t.Errorf("stream %s: %d records emitted but only %s was configured", name, len(records), first.Streams[0].Stream.Name)
+		}
+	}
+}
+
+func testIncremental(t *testing.T, src airbyte.Source, cfg AcceptanceConfig, catalog *airbyte.Catalog) {
+	configured := filterCatalog(ConfigureCatalog(catalog, airbyte.SyncModeIncremental), cfg.Streams)
+	incremental := &airbyte.ConfiguredCatalog{}
+	for _, cs := range configured.Streams {
+		if cs.SyncMode == airbyte.SyncModeIncremental {
+			incremental.Streams = append(incremental.Streams, cs)
+		}
+	}
+	if len(incremental.Streams) == 0 {
+		t.Skip("no stream supports incremental sync")
+	}
+
+	first, err := Read(src, cfg.Config, incremental, nil)
+	if err != nil {
+		t.Fatalf("first read failed: %v", err)
+	}
+	if len(first.States) == 0 {
+		t.Fatal("first read emitted no state")
+	}
+
+	state := first.States[len(first.States)-1]
+	second, err := Read(src, cfg.Config, incremental, state)
+	if err != nil {
+		t.Fatalf("second read with state failed: %v", err)
+	}
+
+	for _, cs := range incremental.Streams {
+		name := cs.Stream.Name
+		if len(cs.CursorField) == 0 {
+			t.Errorf("stream %s: incremental stream without cursor field", name)
+			continue
+		}
+		for i, r := range first.Records[name] {
+			if _, ok := cursorValue(r.Data, cs.CursorField); !ok {
+				t.Errorf("stream %s: record %d has no cursor %s", name, i, strings.Join(cs.CursorField, "."))
+			}
+		}
+		if len(first.Records[name]) == 0 {
+			continue
+		}
+
+		path, ok := cfg.CursorPaths[name]
+		if !ok {
+			path = append([]string{name}, cs.CursorField...)
+		}
+		cursor, ok := cursorValue(state, path)
+		if !ok {
+			t.Errorf("stream %s: the state has no cursor at %s, set AcceptanceConfig.CursorPaths", name, strings.Join(path, "."))
+			continue
+		}
+
+		for i, r := range second.Records[name] {
+			v, ok := cursorValue(r.Data, cs.CursorField)
+			if !ok {
+				t.Errorf("stream %s: record %d of the second read has no cursor", name, i)
+				continue
+			}
+			c, ok := compareCursor(v, cursor)
+			if !ok {
+				t.Errorf("stream %s: cursor values %v and %v can't be compared", name, v, cursor)
+				continue
+			}
+			if c < 0 {
+				t.Errorf("stream %s: record %d of the second read has cursor %v older than the state cursor %v", name, i, v, cursor)
+			}
+		}
+	}
+}
+
+func supportsMode(s airbyte.Stream, mode airbyte.SyncMode) bool {
+	for _, m := range s.SupportedSyncModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPath reports whether the nested property path is declared in the schema
+func hasPath(schema airbyte.Properties, path []string) bool {
+	props := schema.Properties
+	for i, name := range path {
+		p, ok := props[airbyte.PropertyName(name)]
+		if !ok {
+			return false
+		}
+		if i == len(path)-1 {
+			return true
+		}
+		props = p.Properties
+	}
+	return false
+}
+
+func filterCatalog(cat *airbyte.ConfiguredCatalog, streams []string) *airbyte.ConfiguredCatalog {
+	if len(streams) == 0 {
+		return cat
+	}
+
+	filtered := &airbyte.ConfiguredCatalog{}
+	for _, cs := range cat.Streams {
+		for _, name := range streams {
+			if cs.Stream.Name == name {
+				filtered.Streams = append(filtered.Streams, cs)
+			}
+		}
+	}
+	return filtered
+}
+
+func cursorValue(data json.RawMessage, path []string) (interface{}, bool) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	for _, name := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok = obj[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// compareCursor compares two numeric or two string cursor values, strings are expected to sort like
+// their values do, which holds for RFC 3339 timestamps in the same timezone
+func compareCursor(a, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return 0, false
+		}
+		af, aerr := av.Float64()
+		bf, berr := bv.Float64()
+		if aerr != nil || berr != nil {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	}
+	return 0, false
+}
@@ -0,0 +1,419 @@
+// Package cassette records the HTTP traffic of a source once and replays it deterministically afterwards,
+// so tests of HTTP based sources run offline in CI
+//
+// Example usage
+//
+//	rec, err := cassette.New("testdata/users.json", cassette.Options{
+//		Mode:          cassette.ModeReplayOrRecord,
+//		RedactHeaders: []string{"Authorization"},
+//		RedactQuery:   []string{"apiKey"},
+//		RedactBody:    []string{"client_secret", "refresh_token"},
+//	})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer rec.Stop()
+//
+//	src := apisource.NewAPISource("https://api.bitstrapped.com", &http.Client{Transport: rec})
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNoInteraction is returned when replaying a request the cassette has no recorded response for
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// Redacted replaces the value of redacted headers, query parameters and body fields
+const Redacted = "REDACTED"
+
+// Mode defines whether a cassette talks to the network
+type Mode int
+
+const (
+	// ModeReplay only replays recorded interactions and fails on anything else
+	ModeReplay Mode = iota
+	// ModeRecord sends every request to the network and overwrites the cassette on Stop
+	ModeRecord
+	// ModeReplayOrRecord replays if the cassette file exists and records it otherwise
+	ModeReplayOrRecord
+)
+
+// Request is the recorded part of an outgoing request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is the recorded part of a response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Interaction is a single recorded request/response pair
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Matcher decides whether a recorded request answers the outgoing request
+// The outgoing request has already been redacted, so redacted values compare equal
+type Matcher func(r Request, recorded Request) bool
+
+// MatchMethodAndURL matches on the method and the full url, query parameters in any order
+// Every page of a paginated request has its own page parameter or url, so each page matches its own recording
+func MatchMethodAndURL(r Request, recorded Request) bool {
+	return r.Method == recorded.Method && normalizeURL(r.URL) == normalizeURL(recorded.URL)
+}
+
+// MatchMethodURLAndBody additionally matches the request body, for APIs which paginate with POST bodies
+func MatchMethodURLAndBody(r Request, recorded Request) bool {
+	return MatchMethodAndURL(r, recorded) && r.Body == recorded.Body
+}
+
+// IgnoreQuery wraps a matcher to ignore query parameters which change between runs, e.g. timestamps or nonces
+func IgnoreQuery(m Matcher, params ...string) Matcher {
+	return func(r Request, recorded Request) bool {
+		return m(withoutQuery(r, params), withoutQuery(recorded, params))
+	}
+}
+
+// Options configures a cassette
+type Options struct {
+	Mode Mode
+	// Transport sends requests while recording, defaults to http.DefaultTransport
+	Transport http.RoundTripper
+	// Matcher defaults to MatchMethodAndURL
+	Matcher Matcher
+	// RedactHeaders lists request and response headers whose values are never written to the cassette
+	RedactHeaders []string
+	// RedactQuery lists query parameters whose values are never written to the cassette
+	RedactQuery []string
+	// RedactBody lists fields of form and json request bodies whose values are never written to the cassette,
+	// json fields are redacted at any depth
+	RedactBody []string
+}
+
+// Cassette is an http.RoundTripper which records or replays interactions
+// Recorded interactions are replayed in order and each one only once, so repeated identical requests
+// get their responses in the order they were recorded
+type Cassette struct {
+	path      string
+	opts      Options
+	recording bool
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New loads the cassette at path, or prepares to record it
+func New(path string, opts Options) (*Cassette, error) {
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.Matcher == nil {
+		opts.Matcher = MatchMethodAndURL
+	}
+
+	c := &Cassette{
+		path: path,
+		opts: opts,
+	}
+
+	switch opts.Mode {
+	case ModeRecord:
+		c.recording = true
+		return c, nil
+	case ModeReplayOrRecord:
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			c.recording = true
+			return c, nil
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.interactions); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	c.used = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// Recording reports whether the cassette talks to the network
+func (c *Cassette) Recording() bool {
+	return c.recording
+}
+
+// Interactions returns the recorded interactions
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// RoundTrip implements http.RoundTripper
+// The body of the request is read through GetBody, requests without it are cloned so the caller's request
+// isn't changed
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.GetBody != nil:
+		rc, err := req.GetBody()
+		if err != nil {
+			req.Body.Close()
+			return nil, err
+		}
+		body, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			req.Body.Close()
+			return nil, err
+		}
+	default:
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	recReq := c.redactRequest(Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   string(body),
+	})
+
+	if !c.recording {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return c.replay(req, recReq)
+	}
+
+	resp, err := c.opts.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, Interaction{
+		Request: recReq,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     c.redactHeader(resp.Header.Clone()),
+			Body:       string(respBody),
+		},
+	})
+	c.used = append(c.used, true)
+	return resp, nil
+}
+
+func (c *Cassette) replay(req *http.Request, recReq Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, in := range c.interactions {
+		if c.used[i] || !c.opts.Matcher(recReq, in.Request) {
+			continue
+		}
+		c.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recReq.Method, recReq.URL)
+}
+
+// Stop writes the cassette file if it was recording
+func (c *Cassette) Stop() error {
+	if !c.recording {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(b, '\n'), 0o644)
+}
+
+// Unused returns the recorded interactions which have not been replayed, useful to assert a sync made every request
+func (c *Cassette) Unused() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var unused []Interaction
+	for i, in := range c.interactions {
+		if !c.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+func (c *Cassette) redactRequest(r Request) Request {
+	r.Body = c.redactBody(r.Body, r.Header.Get("Content-Type"))
+	r.Header = c.redactHeader(r.Header)
+
+	u, err := url.Parse(r.URL)
+	if err != nil || len(c.opts.RedactQuery) == 0 {
+		return r
+	}
+	q := u.Query()
+	for _, p := range c.opts.RedactQuery {
+		if _, ok := q[p]; ok {
+			q.Set(p, Redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+	r.URL = u.String()
+	return r
+}
+
+// redactBody redacts the fields of a form or json body, other bodies are recorded as they are
+func (c *Cassette) redactBody(body string, contentType string) string {
+	if body == "" || len(c.opts.RedactBody) == 0 {
+		return body
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(body)
+		if err != nil {
+			return body
+		}
+		redacted := false
+		for _, f := range c.opts.RedactBody {
+			if _, ok := form[f]; ok {
+				form.Set(f, Redacted)
+				redacted = true
+			}
+		}
+		if !redacted {
+			return body
+		}
+		return form.Encode()
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil || !c.redactJSON(v) {
+		return body
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+// redactJSON redacts the fields of the objects in v and reports whether it redacted any
+func (c *Cassette) redactJSON(v interface{}) bool {
+	redacted := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			if c.redactField(k) {
+				v[k] = Redacted
+				redacted = true
+				continue
+			}
+			redacted = c.redactJSON(fv) || redacted
+		}
+	case []interface{}:
+		for _, ev := range v {
+			redacted = c.redactJSON(ev) || redacted
+		}
+	}
+	return redacted
+}
+
+func (c *Cassette) redactField(name string) bool {
+	for _, f := range c.opts.RedactBody {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cassette) redactHeader(h http.Header) http.Header {
+	for _, name := range c.opts.RedactHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, Redacted)
+		}
+	}
+	return h
+}
+
+// normalizeURL sorts the query parameters so their order doesn't matter
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	q := u.Query()
+	for _, vs := range q {
+		sort.Strings(vs)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func withoutQuery(r Request, params []string) Request {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return r
+	}
+	q := u.Query()
+	for _, p := range params {
+		q.Del(p)
+	}
+	u.RawQuery = q.Encode()
+	r.URL = u.String()
+	return r
+}
@@ -0,0 +1,155 @@
+package cassette_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitstrapped/airbyte/airbytetest/cassette"
+)
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, "page %s call %d", r.URL.Query().Get("page"), calls)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	opts := cassette.Options{
+		Mode:        cassette.ModeReplayOrRecord,
+		RedactQuery: []string{"apiKey"},
+	}
+
+	rec, err := cassette.New(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Recording() {
+		t.Fatal("expected a missing cassette to record")
+	}
+	client := &http.Client{Transport: rec}
+	first := get(t, client, srv.URL+"/users?apiKey=secret&page=1")
+	second := get(t, client, srv.URL+"/users?apiKey=secret&page=2")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Fatal("api key leaked into the cassette")
+	}
+
+	srv.Close()
+	replay, err := cassette.New(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Recording() {
+		t.Fatal("expected an existing cassette to replay")
+	}
+	client = &http.Client{Transport: replay}
+	// pages are matched by their query, not by the order they are requested in
+	if got := get(t, client, srv.URL+"/users?page=2&apiKey=other"); got != second {
+		t.Fatalf("expected %q, got %q", second, got)
+	}
+	if got := get(t, client, srv.URL+"/users?page=1&apiKey=other"); got != first {
+		t.Fatalf("expected %q, got %q", first, got)
+	}
+
+	_, err = client.Get(srv.URL + "/users?page=1")
+	if !errors.Is(err, cassette.ErrNoInteraction) {
+		t.Fatalf("expected ErrNoInteraction once every interaction is used, got %v", err)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "got %d bytes", len(b))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	opts := cassette.Options{
+		Mode:       cassette.ModeReplayOrRecord,
+		Matcher:    cassette.MatchMethodURLAndBody,
+		RedactBody: []string{"client_secret", "refresh_token"},
+	}
+	post := func(rt http.RoundTripper, contentType, body string) string {
+		t.Helper()
+		// a body without GetBody, which the cassette can't read again
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/token", ioutil.NopCloser(strings.NewReader(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		reqBody := req.Body
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if req.Body != reqBody {
+			t.Error("the body of the caller's request was replaced")
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	rec, err := cassette.New(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := post(rec, "application/x-www-form-urlencoded", "grant_type=refresh_token&client_secret=secret1&refresh_token=secret2")
+	js := post(rec, "application/json", `{"auth": {"refresh_token": "secret3"}, "client_id": "app"}`)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret1", "secret2", "secret3"} {
+		if strings.Contains(string(b), secret) {
+			t.Fatalf("%s leaked into the cassette", secret)
+		}
+	}
+
+	replay, err := cassette.New(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := post(replay, "application/json", `{"client_id": "app", "auth": {"refresh_token": "other"}}`); got != js {
+		t.Errorf("expected %q, got %q", js, got)
+	}
+	if got := post(replay, "application/x-www-form-urlencoded", "client_secret=other&grant_type=refresh_token&refresh_token=other"); got != form {
+		t.Errorf("expected %q, got %q", form, got)
+	}
+}
@@ -0,0 +1,273 @@
+// Package airbytetest helps to test connectors built with the airbyte package
+// It runs sources in-process through the real SourceRunner and hands back everything they emitted as typed values,
+// destinations run through the real DestinationRunner
+package airbytetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// Record is a single record emitted by a source
+type Record struct {
+	Stream    string
+	Namespace string
+	EmittedAt int64
+	Data      json.RawMessage
+}
+
+// Unmarshal decodes the record data into v
+func (r Record) Unmarshal(v interface{}) error {
+	return json.Unmarshal(r.Data, v)
+}
+
+// Log is a single log line emitted by a source
+type Log struct {
+	Level   airbyte.LogLevel
+	Message string
+}
+
+// ConnectionStatus is the outcome of a check
+type ConnectionStatus string
+
+const (
+	// StatusSucceeded means the check passed
+	StatusSucceeded ConnectionStatus = "SUCCEEDED"
+	// StatusFailed means the check failed
+	StatusFailed ConnectionStatus = "FAILED"
+)
+
+// Output holds everything a source emitted while running a single command
+type Output struct {
+	// Spec is set by Spec
+	Spec *airbyte.ConnectorSpecification
+	// ConnectionStatus is set by Check
+	ConnectionStatus ConnectionStatus
+	// Catalog is set by Discover
+	Catalog *airbyte.Catalog
+	// Records holds the emitted records grouped by stream name, in the order they were emitted
+	Records map[string][]Record
+	// States holds every emitted state in order
+	States []json.RawMessage
+	// Logs holds every emitted log line in order
+	Logs []Log
+	// Configs holds every emitted config update in order
+	Configs []json.RawMessage
+}
+
+// RecordCount returns the number of records emitted across all streams
+func (o *Output) RecordCount() int {
+	n := 0
+	for _, rs := range o.Records {
+		n += len(rs)
+	}
+	return n
+}
+
+// LastState decodes the last emitted state into v
+func (o *Output) LastState(v interface{}) error {
+	if len(o.States) == 0 {
+		return errors.New("no state emitted")
+	}
+	return json.Unmarshal(o.States[len(o.States)-1], v)
+}
+
+// Spec runs the spec command
+func Spec(src airbyte.Source) (*Output, error) {
+	return run(src, []string{"spec"})
+}
+
+// Check runs the check command with the given config
+// config is marshalled to json unless it is already a []byte or json.RawMessage
+func Check(src airbyte.Source, config interface{}) (*Output, error) {
+	return withFiles(func(write func(name string, v interface{}) (string, error)) (*Output, error) {
+		cfgPath, err := write("config.json", config)
+		if err != nil {
+			return nil, err
+		}
+		return run(src, []string{"check", "--config", cfgPath})
+	})
+}
+
+// Discover runs the discover command with the given config
+// config is marshalled to json unless it is already a []byte or json.RawMessage
+func Discover(src airbyte.Source, config interface{}) (*Output, error) {
+	return withFiles(func(write func(name string, v interface{}) (string, error)) (*Output, error) {
+		cfgPath, err := write("config.json", config)
+		if err != nil {
+			return nil, err
+		}
+		return run(src, []string{"discover", "--config", cfgPath})
+	})
+}
+
+// Read runs the read command with the given config, catalog and state
+// A nil state runs the read without --state, like a first sync
+// config and state are marshalled to json unless they are already a []byte or json.RawMessage
+func Read(src airbyte.Source, config interface{}, catalog *airbyte.ConfiguredCatalog, state interface{}) (*Output, error) {
+	return withFiles(func(write func(name string, v interface{}) (string, error)) (*Output, error) {
+		cfgPath, err := write("config.json", config)
+		if err != nil {
+			return nil, err
+		}
+		catPath, err := write("catalog.json", catalog)
+		if err != nil {
+			return nil, err
+		}
+
+		args := []string{"read", "--config", cfgPath, "--catalog", catPath}
+		if state != nil {
+			stPath, err := write("state.json", state)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "--state", stPath)
+		}
+		return run(src, args)
+	})
+}
+
+// Write runs the write command of a destination with the given config and catalog, input holds the messages of the
+// source, e.g. newline delimited RECORD and STATE messages
+// The output holds the states the destination passed on after committing the records before them
+func Write(dst airbyte.Destination, config interface{}, catalog *airbyte.ConfiguredCatalog, input io.Reader) (*Output, error) {
+	return withFiles(func(write func(name string, v interface{}) (string, error)) (*Output, error) {
+		cfgPath, err := write("config.json", config)
+		if err != nil {
+			return nil, err
+		}
+		catPath, err := write("catalog.json", catalog)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		runErr := airbyte.NewDestinationRunner(dst, input, &buf).StartWithArgs([]string{"write", "--config", cfgPath, "--catalog", catPath})
+		return decode(&buf, runErr)
+	})
+}
+
+// withFiles hands fn a func to write json files into a temp dir which is removed afterwards
+// the source only accepts paths, so in-memory values have to take a detour through the file system
+func withFiles(fn func(write func(name string, v interface{}) (string, error)) (*Output, error)) (*Output, error) {
+	dir, err := ioutil.TempDir("", "airbytetest")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	return fn(func(name string, v interface{}) (string, error) {
+		b, err := marshalInput(v)
+		if err != nil {
+			return "", err
+		}
+
+		p := filepath.Join(dir, name)
+		return p, ioutil.WriteFile(p, b, 0o600)
+	})
+}
+
+// run starts the source through the SourceRunner and decodes its output
+// The output is returned even if the source failed, so tests can assert on what was emitted up to the failure
+func run(src airbyte.Source, args []string) (*Output, error) {
+	var buf bytes.Buffer
+	runErr := airbyte.NewSourceRunner(src, &buf).StartWithArgs(args)
+	return decode(&buf, runErr)
+}
+
+// decode decodes the output of a runner, runErr is returned along with it
+func decode(buf *bytes.Buffer, runErr error) (*Output, error) {
+	out := &Output{
+		Records: map[string][]Record{},
+	}
+	err := airbyte.ReadMessages(buf, airbyte.MessageHandler{
+		Record: func(data json.RawMessage, streamName string, namespace string, emittedAt int64) error {
+			out.Records[streamName] = append(out.Records[streamName], Record{
+				Stream:    streamName,
+				Namespace: namespace,
+				EmittedAt: emittedAt,
+				Data:      data,
+			})
+			return nil
+		},
+		State: func(data json.RawMessage) error {
+			out.States = append(out.States, data)
+			return nil
+		},
+		Log: func(level airbyte.LogLevel, s string) error {
+			out.Logs = append(out.Logs, Log{Level: level, Message: s})
+			return nil
+		},
+		ConnectionStatus: func(succeeded bool) error {
+			out.ConnectionStatus = StatusFailed
+			if succeeded {
+				out.ConnectionStatus = StatusSucceeded
+			}
+			return nil
+		},
+		Catalog: func(catalog *airbyte.Catalog) error {
+			out.Catalog = catalog
+			return nil
+		},
+		Spec: func(spec *airbyte.ConnectorSpecification) error {
+			out.Spec = spec
+			return nil
+		},
+		Config: func(config json.RawMessage) error {
+			out.Configs = append(out.Configs, config)
+			return nil
+		},
+	})
+	if runErr != nil {
+		return out, runErr
+	}
+
+	return out, err
+}
+
+// ConfigureCatalog selects every stream of a discovered catalog with the given sync mode, like a user would in the UI
+// Incremental streams use their default cursor field and primary key; streams which don't support
+// the sync mode fall back to full refresh
+func ConfigureCatalog(cat *airbyte.Catalog, mode airbyte.SyncMode) *airbyte.ConfiguredCatalog {
+	configured := &airbyte.ConfiguredCatalog{}
+	for _, s := range cat.Streams {
+		streamMode := airbyte.SyncModeFullRefresh
+		for _, m := range s.SupportedSyncModes {
+			if m == mode {
+				streamMode = mode
+			}
+		}
+
+		cs := airbyte.ConfiguredStream{
+			Stream:              s,
+			SyncMode:            streamMode,
+			DestinationSyncMode: airbyte.DestinationSyncModeOverwrite,
+			PrimaryKey:          s.SourceDefinedPrimaryKey,
+		}
+		if streamMode == airbyte.SyncModeIncremental {
+			cs.CursorField = s.DefaultCursorField
+			cs.DestinationSyncMode = airbyte.DestinationSyncModeAppend
+		}
+		configured.Streams = append(configured.Streams, cs)
+	}
+
+	return configured
+}
+
+// marshalInput marshals config, catalog and state values unless they already are json
+func marshalInput(v interface{}) ([]byte, error) {
+	switch tv := v.(type) {
+	case []byte:
+		return tv, nil
+	case json.RawMessage:
+		return tv, nil
+	}
+	return json.Marshal(v)
+}
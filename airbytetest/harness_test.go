@@ -0,0 +1,192 @@
+package airbytetest_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+type counterConfig struct {
+	Count int `json:"count"`
+}
+
+type counterState struct {
+	Cursor int `json:"cursor"`
+}
+
+type counter struct {
+	ID int `json:"id"`
+}
+
+// counterSource emits count records with increasing ids, continuing after the id in its state
+type counterSource struct{}
+
+func (counterSource) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecification, error) {
+	props, err := airbyte.InferSchemaFromStruct(counterConfig{}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+	return &airbyte.ConnectorSpecification{
+		SupportsIncremental: true,
+		SupportedDestinationSyncModes: []airbyte.DestinationSyncMode{
+			airbyte.DestinationSyncModeAppend,
+		},
+		ConnectionSpecification: airbyte.ConnectionSpecification{
+			Title:      "Counter",
+			Type:       "object",
+			Required:   []airbyte.PropertyName{"count"},
+			Properties: props,
+		},
+	}, nil
+}
+
+func (counterSource) Check(srcCfgPath string, logTracker airbyte.LogTracker) error {
+	var cfg counterConfig
+	if err := airbyte.UnmarshalFromPath(srcCfgPath, &cfg); err != nil {
+		return err
+	}
+	if cfg.Count < 0 {
+		return errors.New("count must not be negative")
+	}
+	return nil
+}
+
+func (counterSource) Discover(srcCfgPath string, logTracker airbyte.LogTracker) (*airbyte.Catalog, error) {
+	props, err := airbyte.InferSchemaFromStruct(counter{}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+	return &airbyte.Catalog{Streams: []airbyte.Stream{{
+		Name:                    "counters",
+		JSONSchema:              props,
+		SupportedSyncModes:      []airbyte.SyncMode{airbyte.SyncModeFullRefresh, airbyte.SyncModeIncremental},
+		SourceDefinedCursor:     true,
+		DefaultCursorField:      []string{"id"},
+		SourceDefinedPrimaryKey: [][]string{{"id"}},
+	}}}, nil
+}
+
+func (counterSource) Read(sourceCfgPath string, prevStatePath string, configuredCat *airbyte.ConfiguredCatalog,
+	tracker airbyte.MessageTracker) error {
+	var cfg counterConfig
+	if err := airbyte.UnmarshalFromPath(sourceCfgPath, &cfg); err != nil {
+		return err
+	}
+
+	var st counterState
+	if prevStatePath != "" {
+		if err := airbyte.UnmarshalFromPath(prevStatePath, &st); err != nil {
+			return err
+		}
+	}
+
+	for _, stream := range configuredCat.Streams {
+		if err := tracker.Log(airbyte.LogLevelInfo, "reading "+stream.Stream.Name); err != nil {
+			return err
+		}
+		for i := st.Cursor + 1; i <= st.Cursor+cfg.Count; i++ {
+			if err := tracker.Record(counter{ID: i}, stream.Stream.Name, stream.Stream.Namespace); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tracker.State(counterState{Cursor: st.Cursor + cfg.Count})
+}
+
+func TestRead(t *testing.T) {
+	disc, err := airbytetest.Discover(counterSource{}, counterConfig{Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental)
+
+	out, err := airbytetest.Read(counterSource{}, counterConfig{Count: 3}, cat, counterState{Cursor: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := out.Records["counters"]
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	var c counter
+	if err := records[0].Unmarshal(&c); err != nil || c.ID != 11 {
+		t.Fatalf("expected first id 11, got %d (%v)", c.ID, err)
+	}
+
+	var st counterState
+	if err := out.LastState(&st); err != nil || st.Cursor != 13 {
+		t.Fatalf("expected cursor 13, got %d (%v)", st.Cursor, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	out, err := airbytetest.Check(counterSource{}, []byte(`{"count": -1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ConnectionStatus != airbytetest.StatusFailed {
+		t.Fatalf("expected failed check, got %q", out.ConnectionStatus)
+	}
+}
+
+func TestAcceptance(t *testing.T) {
+	airbytetest.RunAcceptanceTests(t, counterSource{}, airbytetest.AcceptanceConfig{
+		Config:      counterConfig{Count: 5},
+		CursorPaths: map[string][]string{"counters": {"cursor"}},
+	})
+}
+
+func TestRecordingTracker(t *testing.T) {
+	out, err := airbytetest.Discover(counterSource{}, counterConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat := airbytetest.ConfigureCatalog(out.Catalog, airbyte.SyncModeFullRefresh)
+
+	cfg, err := ioutil.TempFile(t.TempDir(), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.WriteString(`{"count": 5}`); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Close()
+
+	rt := airbytetest.NewRecordingTracker()
+	errBroken := errors.New("broken pipe")
+	rt.FailRecordsAfter(2, errBroken)
+
+	err = counterSource{}.Read(cfg.Name(), "", cat, rt.MessageTracker())
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	if n := len(rt.RecordsFor("counters")); n != 2 {
+		t.Fatalf("expected 2 records before the failure, got %d", n)
+	}
+	if len(rt.States()) != 0 {
+		t.Fatal("expected no state after a failed read")
+	}
+	rt.AssertNoErrorLogs(t)
+}
+
+func TestValidateRecord(t *testing.T) {
+	schema := airbyte.Properties{
+		Properties: map[airbyte.PropertyName]airbyte.PropertySpec{
+			"id":   {PropertyType: airbyte.PropertyType{Type: []airbyte.PropType{airbyte.Integer}}},
+			"name": {PropertyType: airbyte.PropertyType{Type: []airbyte.PropType{airbyte.String, airbyte.Null}}},
+		},
+		Required: []airbyte.PropertyName{"id"},
+	}
+
+	if err := airbytetest.ValidateRecord([]byte(`{"id": 1}`), schema); err != nil {
+		t.Errorf("expected a record without an optional property to match, got %v", err)
+	}
+	if err := airbytetest.ValidateRecord([]byte(`{"name": "a"}`), schema); !errors.Is(err, airbytetest.ErrSchemaMismatch) {
+		t.Errorf("expected a record without a required property to fail, got %v", err)
+	}
+}
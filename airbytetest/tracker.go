@@ -0,0 +1,208 @@
+package airbytetest
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// RecordingTracker keeps records, states and logs in memory so a source's Read can be unit tested
+// without a SourceRunner. Like the real tracker it is thread-safe
+//
+// Example usage
+//
+//	rt := airbytetest.NewRecordingTracker()
+//	rt.FailRecordsAfter(10, errors.New("broken pipe"))
+//	err := src.Read(cfgPath, "", catalog, rt.MessageTracker())
+//	// err should be the broken pipe
+//	rt.AssertNoErrorLogs(t)
+type RecordingTracker struct {
+	mu        sync.Mutex
+	records   []Record
+	states    []json.RawMessage
+	logs      []Log
+	configs   []json.RawMessage
+	recordErr func(r Record) error
+	stateErr  func(n int) error
+}
+
+// NewRecordingTracker returns an empty RecordingTracker
+func NewRecordingTracker() *RecordingTracker {
+	return &RecordingTracker{}
+}
+
+// MessageTracker returns the tracker to pass into Read
+func (rt *RecordingTracker) MessageTracker() airbyte.MessageTracker {
+	return airbyte.MessageTracker{
+		Record: rt.record,
+		State:  rt.state,
+		Log:    rt.log,
+		Config: rt.config,
+	}
+}
+
+// LogTracker returns the tracker to pass into Spec, Check and Discover
+func (rt *RecordingTracker) LogTracker() airbyte.LogTracker {
+	return airbyte.LogTracker{
+		Log:    rt.log,
+		Config: rt.config,
+	}
+}
+
+func (rt *RecordingTracker) record(v interface{}, streamName string, namespace string) error {
+	// marshal like the real writer does, so unmarshallable records fail here as well
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	r := Record{
+		Stream:    streamName,
+		Namespace: namespace,
+		EmittedAt: time.Now().UnixMilli(),
+		Data:      b,
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.recordErr != nil {
+		if err := rt.recordErr(r); err != nil {
+			return err
+		}
+	}
+	rt.records = append(rt.records, r)
+	return nil
+}
+
+func (rt *RecordingTracker) state(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.stateErr != nil {
+		if err := rt.stateErr(len(rt.states)); err != nil {
+			return err
+		}
+	}
+	rt.states = append(rt.states, b)
+	return nil
+}
+
+func (rt *RecordingTracker) log(level airbyte.LogLevel, s string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.logs = append(rt.logs, Log{Level: level, Message: s})
+	return nil
+}
+
+func (rt *RecordingTracker) config(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.configs = append(rt.configs, b)
+	return nil
+}
+
+// InjectRecordError calls fn before every record is stored, a non nil error is returned from tracker.Record
+// and the record is dropped. fn runs under the tracker's lock, so it must not call back into the tracker
+func (rt *RecordingTracker) InjectRecordError(fn func(r Record) error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.recordErr = fn
+}
+
+// FailRecordsAfter stores the first n records and fails every record after that with err
+func (rt *RecordingTracker) FailRecordsAfter(n int, err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.recordErr = func(Record) error {
+		if len(rt.records) >= n {
+			return err
+		}
+		return nil
+	}
+}
+
+// FailStatesAfter stores the first n states and fails every state after that with err
+func (rt *RecordingTracker) FailStatesAfter(n int, err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.stateErr = func(stored int) error {
+		if stored >= n {
+			return err
+		}
+		return nil
+	}
+}
+
+// Records returns every stored record in order
+func (rt *RecordingTracker) Records() []Record {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]Record(nil), rt.records...)
+}
+
+// RecordsFor returns the stored records of a single stream in order
+func (rt *RecordingTracker) RecordsFor(stream string) []Record {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var records []Record
+	for _, r := range rt.records {
+		if r.Stream == stream {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// States returns every stored state in order
+func (rt *RecordingTracker) States() []json.RawMessage {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]json.RawMessage(nil), rt.states...)
+}
+
+// LastState decodes the last stored state into v
+func (rt *RecordingTracker) LastState(v interface{}) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.states) == 0 {
+		return errors.New("no state emitted")
+	}
+	return json.Unmarshal(rt.states[len(rt.states)-1], v)
+}
+
+// Logs returns every stored log line in order
+func (rt *RecordingTracker) Logs() []Log {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]Log(nil), rt.logs...)
+}
+
+// Configs returns every emitted config update in order
+func (rt *RecordingTracker) Configs() []json.RawMessage {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]json.RawMessage(nil), rt.configs...)
+}
+
+// AssertNoErrorLogs fails the test for every ERROR or FATAL log line
+func (rt *RecordingTracker) AssertNoErrorLogs(t testing.TB) {
+	t.Helper()
+	for _, l := range rt.Logs() {
+		if l.Level == airbyte.LogLevelError || l.Level == airbyte.LogLevelFatal {
+			t.Errorf("unexpected %s log: %s", l.Level, l.Message)
+		}
+	}
+}
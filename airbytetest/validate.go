@@ -0,0 +1,354 @@
+package airbytetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// ErrSchemaMismatch is wrapped by every error ValidateRecord returns
+var ErrSchemaMismatch = errors.New("record does not match schema")
+
+var knownTypes = map[airbyte.PropType]bool{
+	airbyte.String:  true,
+	airbyte.Number:  true,
+	airbyte.Integer: true,
+	airbyte.Object:  true,
+	airbyte.Array:   true,
+	airbyte.Boolean: true,
+	airbyte.Null:    true,
+}
+
+// ValidateRecord checks the record data against the json schema of its stream
+// Properties which are not declared by the schema are reported as well
+func ValidateRecord(data json.RawMessage, schema airbyte.Properties) error {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	errs := validateValue("", v, airbyte.PropertySpec{
+		PropertyType:         airbyte.PropertyType{Type: []airbyte.PropType{airbyte.Object}},
+		Properties:           schema.Properties,
+		Required:             schema.Required,
+		AdditionalProperties: &airbyte.AdditionalProperties{Allowed: false},
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Errorf("%w: %s", ErrSchemaMismatch, strings.Join(msgs, "; "))
+}
+
+// validateSpec checks that a property spec is a valid JSON Schema
+func validateSpec(path string, p airbyte.PropertySpec) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", displayPath(path), fmt.Sprintf(format, args...)))
+	}
+
+	for _, t := range p.Type {
+		if !knownTypes[t] {
+			fail("unknown type %q", t)
+		}
+	}
+
+	for _, r := range p.Required {
+		if _, ok := p.Properties[r]; !ok {
+			fail("required property %q is not declared", r)
+		}
+	}
+
+	if p.Pattern != "" {
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			fail("invalid pattern: %v", err)
+		}
+	}
+
+	if p.Minimum != nil && p.Maximum != nil && *p.Minimum > *p.Maximum {
+		fail("minimum %v is greater than maximum %v", *p.Minimum, *p.Maximum)
+	}
+	if p.MinLength != nil && p.MaxLength != nil && *p.MinLength > *p.MaxLength {
+		fail("minLength %d is greater than maxLength %d", *p.MinLength, *p.MaxLength)
+	}
+	if p.MinItems != nil && p.MaxItems != nil && *p.MinItems > *p.MaxItems {
+		fail("minItems %d is greater than maxItems %d", *p.MinItems, *p.MaxItems)
+	}
+	if p.MultipleOf < 0 {
+		fail("multipleOf %v must be positive", p.MultipleOf)
+	}
+
+	for name, prop := range p.Properties {
+		errs = append(errs, validateSpec(joinPath(path, string(name)), prop)...)
+	}
+	if p.Items != nil {
+		errs = append(errs, validateSpec(path+"[]", *p.Items)...)
+	}
+	if p.AdditionalProperties != nil && p.AdditionalProperties.Spec != nil {
+		errs = append(errs, validateSpec(joinPath(path, "*"), *p.AdditionalProperties.Spec)...)
+	}
+	for i, o := range p.OneOf {
+		errs = append(errs, validateSpec(fmt.Sprintf("%s.oneOf[%d]", path, i), o)...)
+	}
+
+	if len(errs) > 0 {
+		// keywords like default only make sense once the spec itself is valid
+		return errs
+	}
+
+	if p.Default != nil {
+		for _, err := range validateValue(path, normalize(p.Default), p) {
+			fail("default does not match: %v", err)
+		}
+	}
+	if p.Const != nil {
+		for _, err := range validateValue(path, normalize(p.Const), p) {
+			fail("const does not match: %v", err)
+		}
+	}
+
+	return errs
+}
+
+// validateValue checks a decoded json value (numbers as json.Number) against a property spec
+func validateValue(path string, v interface{}, p airbyte.PropertySpec) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", displayPath(path), fmt.Sprintf(format, args...)))
+	}
+
+	if len(p.Type) > 0 && !matchesType(v, p.Type) {
+		fail("%s is not of type %v", jsonType(v), p.Type)
+		return errs
+	}
+
+	if len(p.Enum) > 0 {
+		found := false
+		for _, e := range p.Enum {
+			found = found || equalJSON(v, normalize(e))
+		}
+		if !found {
+			fail("%v is not one of %v", v, p.Enum)
+		}
+	}
+
+	if p.Const != nil && !equalJSON(v, normalize(p.Const)) {
+		fail("%v is not %v", v, p.Const)
+	}
+
+	switch tv := v.(type) {
+	case string:
+		n := uint64(utf8.RuneCountInString(tv))
+		if p.MinLength != nil && n < *p.MinLength {
+			fail("shorter than %d", *p.MinLength)
+		}
+		if p.MaxLength != nil && n > *p.MaxLength {
+			fail("longer than %d", *p.MaxLength)
+		}
+		if p.Pattern != "" {
+			if re, err := regexp.Compile(p.Pattern); err == nil && !re.MatchString(tv) {
+				fail("%q does not match %s", tv, p.Pattern)
+			}
+		}
+
+	case json.Number:
+		f, _ := tv.Float64()
+		if p.Minimum != nil {
+			if f < *p.Minimum || (p.ExclusiveMinimum != nil && *p.ExclusiveMinimum && f == *p.Minimum) {
+				fail("%v is below the minimum %v", f, *p.Minimum)
+			}
+		}
+		if p.Maximum != nil {
+			if f > *p.Maximum || (p.ExclusiveMaximum != nil && *p.ExclusiveMaximum && f == *p.Maximum) {
+				fail("%v is above the maximum %v", f, *p.Maximum)
+			}
+		}
+		if p.MultipleOf > 0 {
+			if q := f / p.MultipleOf; q != math.Trunc(q) {
+				fail("%v is not a multiple of %v", f, p.MultipleOf)
+			}
+		}
+
+	case []interface{}:
+		n := uint64(len(tv))
+		if p.MinItems != nil && n < *p.MinItems {
+			fail("fewer than %d items", *p.MinItems)
+		}
+		if p.MaxItems != nil && n > *p.MaxItems {
+			fail("more than %d items", *p.MaxItems)
+		}
+		if p.UniqueItems {
+			for i := range tv {
+				for j := i + 1; j < len(tv); j++ {
+					if equalJSON(tv[i], tv[j]) {
+						fail("items %d and %d are equal", i, j)
+					}
+				}
+			}
+		}
+		if p.Items != nil {
+			for i, item := range tv {
+				errs = append(errs, validateValue(fmt.Sprintf("%s[%d]", path, i), item, *p.Items)...)
+			}
+		}
+
+	case map[string]interface{}:
+		n := uint64(len(tv))
+		if p.MinProperties != nil && n < *p.MinProperties {
+			fail("fewer than %d properties", *p.MinProperties)
+		}
+		if p.MaxProperties != nil && n > *p.MaxProperties {
+			fail("more than %d properties", *p.MaxProperties)
+		}
+		for _, r := range p.Required {
+			if _, ok := tv[string(r)]; !ok {
+				fail("missing required property %q", r)
+			}
+		}
+
+		keys := make([]string, 0, len(tv))
+		for k := range tv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := p.Properties[airbyte.PropertyName(k)]; ok {
+				errs = append(errs, validateValue(joinPath(path, k), tv[k], prop)...)
+				continue
+			}
+			ap := p.AdditionalProperties
+			switch {
+			case ap == nil:
+			case ap.Spec != nil:
+				errs = append(errs, validateValue(joinPath(path, k), tv[k], *ap.Spec)...)
+			case !ap.Allowed:
+				fail("property %q is not declared", k)
+			}
+		}
+	}
+
+	if len(p.OneOf) > 0 {
+		matches := 0
+		for _, o := range p.OneOf {
+			if len(validateValue(path, v, o)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fail("matches %d of the oneOf schemas instead of exactly one", matches)
+		}
+	}
+
+	return errs
+}
+
+func matchesType(v interface{}, types []airbyte.PropType) bool {
+	for _, t := range types {
+		switch t {
+		case airbyte.Null:
+			if v == nil {
+				return true
+			}
+		case airbyte.Boolean:
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case airbyte.String:
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case airbyte.Number:
+			if _, ok := v.(json.Number); ok {
+				return true
+			}
+		case airbyte.Integer:
+			if n, ok := v.(json.Number); ok {
+				if f, err := n.Float64(); err == nil && f == math.Trunc(f) {
+					return true
+				}
+			}
+		case airbyte.Array:
+			if _, ok := v.([]interface{}); ok {
+				return true
+			}
+		case airbyte.Object:
+			if _, ok := v.(map[string]interface{}); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// normalize turns a go value into what decoding its json with UseNumber returns
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var n interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&n); err != nil {
+		return v
+	}
+	return n
+}
+
+func equalJSON(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		return aerr == nil && berr == nil && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
@@ -0,0 +1,279 @@
+package airbyte
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BenchmarkReportVersion is bumped whenever the meaning of a BenchmarkReport field changes,
+// only compare reports with the same version
+const BenchmarkReportVersion = 1
+
+const modulePath = "github.com/bitstrapped/airbyte"
+
+// BenchmarkReport describes a single Read of a source whose output was discarded
+// Durations are in nanoseconds and rates are per second so reports can be diffed across versions
+type BenchmarkReport struct {
+	ReportVersion int    `json:"report_version"`
+	SDKVersion    string `json:"sdk_version"`
+	GoVersion     string `json:"go_version"`
+	GOOS          string `json:"goos"`
+	GOARCH        string `json:"goarch"`
+	NumCPU        int    `json:"num_cpu"`
+	GOMAXPROCS    int    `json:"gomaxprocs"`
+
+	DurationNs    int64   `json:"duration_ns"`
+	Records       int64   `json:"records"`
+	States        int64   `json:"states"`
+	Logs          int64   `json:"logs"`
+	Bytes         int64   `json:"bytes"`
+	RecordsPerSec float64 `json:"records_per_sec"`
+	BytesPerSec   float64 `json:"bytes_per_sec"`
+
+	// MarshalNs is the time spent encoding messages to json
+	MarshalNs int64 `json:"marshal_ns"`
+	// WriteNs is the time spent writing encoded messages, including LockWaitNs
+	WriteNs int64 `json:"write_ns"`
+	// ConnectorNs is the rest of the Read, the time spent in the connector itself
+	// It's only meaningful for sources which emit from a single goroutine
+	ConnectorNs int64 `json:"connector_ns"`
+
+	// LockWaitNs is the total time writes waited for the output lock, LockWaitMaxNs the longest single wait
+	LockWaitNs    int64 `json:"lock_wait_ns"`
+	LockWaitMaxNs int64 `json:"lock_wait_max_ns"`
+
+	// Allocs, AllocBytes and GCCycles are process wide, measured with runtime.ReadMemStats around the Read
+	Allocs     uint64 `json:"allocs"`
+	AllocBytes uint64 `json:"alloc_bytes"`
+	GCCycles   uint32 `json:"gc_cycles"`
+
+	// Streams holds per stream numbers sorted by namespace and name
+	Streams []StreamBenchmark `json:"streams"`
+}
+
+// StreamBenchmark describes the records of a single stream
+// A stream's duration starts after the message emitted before its first record and ends with its last record
+type StreamBenchmark struct {
+	Stream        string  `json:"stream"`
+	Namespace     string  `json:"namespace"`
+	Records       int64   `json:"records"`
+	Bytes         int64   `json:"bytes"`
+	DurationNs    int64   `json:"duration_ns"`
+	RecordsPerSec float64 `json:"records_per_sec"`
+	BytesPerSec   float64 `json:"bytes_per_sec"`
+	MarshalNs     int64   `json:"marshal_ns"`
+}
+
+// Benchmark runs Read on your source with its output discarded and reports where the time went
+// It's what the bench command of SourceRunner runs, which takes the same flags as read:
+//
+//	./source bench --config config.json --catalog catalog.json [--state state.json]
+func Benchmark(src Source, srcCfgPath string, prevStatePath string, catalog *ConfiguredCatalog) (*BenchmarkReport, error) {
+	b := &benchRecorder{
+		streams: map[streamKey]*streamBench{},
+	}
+	b.w = &safeWriter{
+		w:        io.Discard,
+		lockWait: b.addLockWait,
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.start = time.Now()
+	b.last = b.start
+
+	err := src.Read(srcCfgPath, prevStatePath, catalog, MessageTracker{
+		Record: func(v interface{}, stream string, namespace string) error {
+			return b.write(newRecordMessage(v, stream, namespace))
+		},
+		State: func(v interface{}) error {
+			return b.write(newStateMessage(v))
+		},
+		Log: func(lvl LogLevel, s string) error {
+			return b.write(newLogMessage(lvl, s))
+		},
+		Config: func(config interface{}) error {
+			return b.write(newConfigMessage(config))
+		},
+	})
+	elapsed := time.Since(b.start)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.report(elapsed, &before, &after), nil
+}
+
+type streamKey struct {
+	namespace string
+	name      string
+}
+
+type streamBench struct {
+	records int64
+	bytes   int64
+	marshal time.Duration
+	// from is when the stream's window started, to when its last record was written
+	from time.Time
+	to   time.Time
+}
+
+type benchRecorder struct {
+	w     *safeWriter
+	start time.Time
+
+	// mu guards everything below
+	mu          sync.Mutex
+	last        time.Time
+	records     int64
+	states      int64
+	logs        int64
+	bytes       int64
+	marshal     time.Duration
+	written     time.Duration
+	lockWait    time.Duration
+	lockWaitMax time.Duration
+	streams     map[streamKey]*streamBench
+}
+
+// addLockWait is called by the safeWriter while it holds its own lock, it must never take that lock
+func (b *benchRecorder) addLockWait(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lockWait += d
+	if d > b.lockWaitMax {
+		b.lockWaitMax = d
+	}
+}
+
+func (b *benchRecorder) write(m *message) error {
+	start := time.Now()
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(m); err != nil {
+		return err
+	}
+	encoded := time.Now()
+	if _, err := b.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	done := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := int64(buf.Len())
+	b.bytes += n
+	b.marshal += encoded.Sub(start)
+	b.written += done.Sub(encoded)
+
+	switch m.Type {
+	case msgTypeRecord:
+		b.records++
+		k := streamKey{namespace: m.record.Namespace, name: m.record.Stream}
+		s, ok := b.streams[k]
+		if !ok {
+			s = &streamBench{from: b.last}
+			b.streams[k] = s
+		}
+		s.records++
+		s.bytes += n
+		s.marshal += encoded.Sub(start)
+		s.to = done
+	case msgTypeState:
+		b.states++
+	case msgTypeLog:
+		b.logs++
+	}
+	b.last = done
+
+	return nil
+}
+
+func (b *benchRecorder) report(elapsed time.Duration, before, after *runtime.MemStats) *BenchmarkReport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r := &BenchmarkReport{
+		ReportVersion: BenchmarkReportVersion,
+		SDKVersion:    sdkVersion(),
+		GoVersion:     runtime.Version(),
+		GOOS:          runtime.GOOS,
+		GOARCH:        runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		DurationNs:    elapsed.Nanoseconds(),
+		Records:       b.records,
+		States:        b.states,
+		Logs:          b.logs,
+		Bytes:         b.bytes,
+		RecordsPerSec: perSec(b.records, elapsed),
+		BytesPerSec:   perSec(b.bytes, elapsed),
+		MarshalNs:     b.marshal.Nanoseconds(),
+		WriteNs:       b.written.Nanoseconds(),
+		LockWaitNs:    b.lockWait.Nanoseconds(),
+		LockWaitMaxNs: b.lockWaitMax.Nanoseconds(),
+		Allocs:        after.Mallocs - before.Mallocs,
+		AllocBytes:    after.TotalAlloc - before.TotalAlloc,
+		GCCycles:      after.NumGC - before.NumGC,
+		Streams:       []StreamBenchmark{},
+	}
+	if connector := elapsed - b.marshal - b.written; connector > 0 {
+		r.ConnectorNs = connector.Nanoseconds()
+	}
+
+	for k, s := range b.streams {
+		d := s.to.Sub(s.from)
+		r.Streams = append(r.Streams, StreamBenchmark{
+			Stream:        k.name,
+			Namespace:     k.namespace,
+			Records:       s.records,
+			Bytes:         s.bytes,
+			DurationNs:    d.Nanoseconds(),
+			RecordsPerSec: perSec(s.records, d),
+			BytesPerSec:   perSec(s.bytes, d),
+			MarshalNs:     s.marshal.Nanoseconds(),
+		})
+	}
+	sort.Slice(r.Streams, func(i, j int) bool {
+		if r.Streams[i].Namespace != r.Streams[j].Namespace {
+			return r.Streams[i].Namespace < r.Streams[j].Namespace
+		}
+		return r.Streams[i].Stream < r.Streams[j].Stream
+	})
+
+	return r
+}
+
+func perSec(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// sdkVersion is the version of this module the connector was built with, if the build recorded it
+func sdkVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if bi.Main.Path == modulePath {
+		return bi.Main.Version
+	}
+	for _, d := range bi.Deps {
+		if d.Path == modulePath {
+			if d.Replace != nil {
+				return d.Replace.Version
+			}
+			return d.Version
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package airbyte
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fanOutSource emits n records per configured stream, every stream from its own goroutine
+type fanOutSource struct {
+	n int
+}
+
+func (s fanOutSource) Spec(LogTracker) (*ConnectorSpecification, error) { return nil, nil }
+func (s fanOutSource) Check(string, LogTracker) error                   { return nil }
+func (s fanOutSource) Discover(string, LogTracker) (*Catalog, error)    { return nil, nil }
+
+func (s fanOutSource) Read(_ string, _ string, cat *ConfiguredCatalog, tracker MessageTracker) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(cat.Streams))
+	for _, cs := range cat.Streams {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; i < s.n; i++ {
+				if err := tracker.Record(map[string]int{"id": i}, name, "bench"); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(cs.Stream.Name)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	if err := tracker.Log(LogLevelInfo, "done"); err != nil {
+		return err
+	}
+	return tracker.State(map[string]int{"done": s.n})
+}
+
+func TestBenchCommand(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.json")
+	cat := filepath.Join(dir, "catalog.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	catalog := `{"streams": [{"stream": {"name": "b"}}, {"stream": {"name": "a"}}]}`
+	if err := ioutil.WriteFile(cat, []byte(catalog), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err := NewSourceRunner(fanOutSource{n: 500}, &out).StartWithArgs([]string{"bench", "--config", cfg, "--catalog", cat})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r BenchmarkReport
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("report is not json: %v\n%s", err, out.String())
+	}
+	if r.ReportVersion != BenchmarkReportVersion || r.GoVersion == "" {
+		t.Errorf("missing environment: %+v", r)
+	}
+	if r.Records != 1000 || r.States != 1 || r.Logs != 1 {
+		t.Errorf("expected 1000 records, 1 state and 1 log, got %d, %d and %d", r.Records, r.States, r.Logs)
+	}
+	if r.Bytes == 0 || r.MarshalNs == 0 || r.DurationNs == 0 || r.Allocs == 0 {
+		t.Errorf("expected bytes, marshal time, duration and allocations to be measured: %+v", r)
+	}
+	if r.LockWaitMaxNs > r.LockWaitNs {
+		t.Errorf("max lock wait %d exceeds the total %d", r.LockWaitMaxNs, r.LockWaitNs)
+	}
+
+	if len(r.Streams) != 2 || r.Streams[0].Stream != "a" || r.Streams[1].Stream != "b" {
+		t.Fatalf("expected streams a and b in order, got %+v", r.Streams)
+	}
+	for _, s := range r.Streams {
+		if s.Records != 500 || s.Namespace != "bench" || s.DurationNs <= 0 || s.RecordsPerSec <= 0 {
+			t.Errorf("unexpected stream numbers %+v", s)
+		}
+	}
+}
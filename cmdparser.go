@@ -1,34 +1,64 @@
 package airbyte
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
+
+	"github.com/bitstrapped/airbyte/schema"
 )
 
-func getSourceConfigPath() (string, error) {
-	if os.Args[2] != "--config" {
+// getSourceConfigPath returns the path after --config, args are the command line arguments without the program
+// name, e.g. read --config <path> --catalog <path> [--state <path>]
+func getSourceConfigPath(args []string) (string, error) {
+	if len(args) <= 2 || args[1] != "--config" {
 		return "", fmt.Errorf("expect --config")
 	}
-	return os.Args[3], nil
+	return args[2], nil
 }
 
-func getStatePath() (string, error) {
-	if len(os.Args) <= 6 {
+// getStatePath returns the path after --state, which is optional, in args like getSourceConfigPath's
+func getStatePath(args []string) (string, error) {
+	if len(args) <= 5 {
 		return "", nil
 	}
-	if os.Args[6] != "--state" {
+	if len(args) <= 6 || args[5] != "--state" {
 		return "", fmt.Errorf("expect --state")
 	}
-	return os.Args[7], nil
+	return args[6], nil
 }
 
-func getCatalogPath() (string, error) {
-	if os.Args[4] != "--catalog" {
+// getCatalogPath returns the path after --catalog in args like getSourceConfigPath's
+func getCatalogPath(args []string) (string, error) {
+	if len(args) <= 4 || args[3] != "--catalog" {
 		return "", fmt.Errorf("expect --catalog")
 	}
-	return os.Args[5], nil
+	return args[4], nil
+}
+
+// getReadArgs returns the config path, state path and configured catalog of a read
+func getReadArgs(args []string) (string, string, *ConfiguredCatalog, error) {
+	p, err := getCatalogPath(args)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var incat ConfiguredCatalog
+	err = UnmarshalFromPath(p, &incat)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	srp, err := getSourceConfigPath(args)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	stp, err := getStatePath(args)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	return srp, stp, &incat, nil
 }
 
 // UnmarshalFromPath is used to unmarshal json files into respective struct's
@@ -49,11 +79,12 @@ func getCatalogPath() (string, error) {
 //  	 // cs is populated
 //   }
 //
+// Interface fields registered with schema.RegisterOneOf are decoded into the variant chosen in the config
 func UnmarshalFromPath(path string, v interface{}) error {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(b, v)
+	return schema.Unmarshal(b, v)
 }
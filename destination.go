@@ -0,0 +1,24 @@
+package airbyte
+
+import "encoding/json"
+
+// Destination is the interface you define to create your destination, the counterpart of Source
+type Destination interface {
+	// Spec returns the input "form" spec needed for your destination
+	Spec(logTracker LogTracker) (*ConnectorSpecification, error)
+	// Check verifies the destination - usually verify creds/connection etc.
+	Check(dstCfgPath string, logTracker LogTracker) error
+	// Open prepares the configured streams for a sync and returns the writer the records of the sync are passed to
+	Open(dstCfgPath string, configuredCat *ConfiguredCatalog, logTracker LogTracker) (DestinationWriter, error)
+}
+
+// DestinationWriter receives the records of a sync from the DestinationRunner, it's only used by a single goroutine
+type DestinationWriter interface {
+	// Write receives a record of a configured stream, it may hold on to it until the next Flush
+	Write(data json.RawMessage, streamName string, namespace string, emittedAt int64) error
+	// Flush commits every record written so far, the runner passes on the state which followed the records once it returns
+	// so the next sync resumes after them
+	Flush() error
+	// Close ends the sync, the records written since the last Flush are committed if succeeded and discarded otherwise
+	Close(succeeded bool) error
+}
@@ -0,0 +1,122 @@
+package airbyte
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// DestinationRunner runs your destination, it reads the messages of the source from r and writes its own to w
+type DestinationRunner struct {
+	r          io.Reader
+	w          io.Writer
+	dst        Destination
+	msgTracker MessageTracker
+}
+
+// NewDestinationRunner takes your defined Destination and plugs it in with the rest of airbyte
+// r is usually os.Stdin, where airbyte pipes the output of the source
+func NewDestinationRunner(dst Destination, r io.Reader, w io.Writer) DestinationRunner {
+	w = newSafeWriter(w)
+	return DestinationRunner{
+		r:   r,
+		w:   w,
+		dst: dst,
+		msgTracker: MessageTracker{
+			State:  newStateWriter(w),
+			Log:    newLogWriter(w),
+			Config: newConfigWriter(w),
+		},
+	}
+}
+
+// Start starts your destination, see SourceRunner.Start
+func (dr DestinationRunner) Start() error {
+	return dr.StartWithArgs(os.Args[1:])
+}
+
+// StartWithArgs starts your destination with the given command line arguments (without the program name)
+// e.g. []string{"write", "--config", "config.json", "--catalog", "catalog.json"}
+func (dr DestinationRunner) StartWithArgs(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expect a command")
+	}
+
+	switch cmd(args[0]) {
+	case cmdSpec:
+		spec, err := dr.dst.Spec(dr.logTracker())
+		if err != nil {
+			dr.msgTracker.Log(LogLevelError, "failed"+err.Error())
+			return err
+		}
+		return write(dr.w, &message{
+			Type:                   msgTypeSpec,
+			ConnectorSpecification: spec,
+		})
+
+	case cmdCheck:
+		inP, err := getSourceConfigPath(args)
+		if err != nil {
+			return err
+		}
+		status := checkStatusSuccess
+		if err := dr.dst.Check(inP, dr.logTracker()); err != nil {
+			log.Println(err)
+			status = checkStatusFailed
+		}
+		return write(dr.w, &message{
+			Type:             msgTypeConnectionStat,
+			connectionStatus: &connectionStatus{Status: status},
+		})
+
+	case cmdWrite:
+		inP, err := getSourceConfigPath(args)
+		if err != nil {
+			return err
+		}
+		catP, err := getCatalogPath(args)
+		if err != nil {
+			return err
+		}
+		var incat ConfiguredCatalog
+		if err := UnmarshalFromPath(catP, &incat); err != nil {
+			return err
+		}
+		return dr.write(inP, &incat)
+
+	default:
+		return fmt.Errorf("unknown command %s", args[0])
+	}
+}
+
+// write passes the records of the source to the destination and emits every state once the destination
+// committed the records before it
+func (dr DestinationRunner) write(cfgPath string, incat *ConfiguredCatalog) error {
+	dw, err := dr.dst.Open(cfgPath, incat, dr.logTracker())
+	if err != nil {
+		return err
+	}
+
+	err = ReadMessages(dr.r, MessageHandler{
+		Record: dw.Write,
+		State: func(data json.RawMessage) error {
+			if err := dw.Flush(); err != nil {
+				return err
+			}
+			return dr.msgTracker.State(data)
+		},
+	})
+	if cerr := dw.Close(err == nil); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (dr DestinationRunner) logTracker() LogTracker {
+	return LogTracker{
+		Log:    dr.msgTracker.Log,
+		Config: dr.msgTracker.Config,
+	}
+}
@@ -50,7 +50,7 @@ func (h HTTPSource) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpeci
 				Properties: map[airbyte.PropertyName]airbyte.PropertySpec{
 					"apiKey": {
 						Description: "api key to access http source, valid uuid",
-						Examples:    []string{"xxxx-xxxx-xxxx-xxxx"},
+						Examples:    []interface{}{"xxxx-xxxx-xxxx-xxxx"},
 						PropertyType: airbyte.PropertyType{
 							Type: []airbyte.PropType{
 								airbyte.String,
@@ -90,6 +90,11 @@ func (h HTTPSource) Discover(srcCfgPath string, logTracker airbyte.LogTracker) (
 		return nil, err
 	}
 
+	paymentSchema, err := airbyte.InferSchemaFromStruct(Payment{}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+
 	return &airbyte.Catalog{Streams: []airbyte.Stream{{
 		Name: "users",
 		JSONSchema: airbyte.Properties{
@@ -116,7 +121,7 @@ func (h HTTPSource) Discover(srcCfgPath string, logTracker airbyte.LogTracker) (
 	},
 		{
 			Name:       "payments",
-			JSONSchema: airbyte.InferSchemaFromStruct(Payment{}, logTracker),
+			JSONSchema: paymentSchema,
 			SupportedSyncModes: []airbyte.SyncMode{
 				airbyte.SyncModeFullRefresh,
 			},
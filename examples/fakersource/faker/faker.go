@@ -0,0 +1,324 @@
+// Package faker is a source which generates deterministic synthetic data, no upstream needed
+// The same seed always generates the same records, which makes it useful for load tests, benchmarks
+// and end-to-end tests of destinations
+package faker
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+// Kind selects the record template of a stream
+type Kind string
+
+const (
+	KindUsers     Kind = "users"
+	KindPurchases Kind = "purchases"
+	KindEvents    Kind = "events"
+)
+
+// epoch is the cursor of the first record, every record is a minute after the previous one
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type FakerSource struct{}
+
+type Config struct {
+	Seed    int64          `json:"seed" title:"Seed" description:"the same seed always generates the same records" order:"0"`
+	Streams []StreamConfig `json:"streams" title:"Streams" description:"streams offered by discover" order:"1" minItems:"1"`
+}
+
+type StreamConfig struct {
+	Name       string `json:"name" description:"stream name"`
+	Kind       Kind   `json:"kind" description:"record template" enum:"users,purchases,events"`
+	Records    int64  `json:"records" description:"records generated per sync" minimum:"0"`
+	RecordSize int    `json:"record_size,omitempty" description:"bytes of padding added to every record" minimum:"0"`
+	StateEvery int64  `json:"state_every,omitempty" description:"emit a state every n records, 0 only emits it once the stream is done" minimum:"0"`
+}
+
+// State keeps the position of every stream, so incremental syncs continue where the last one stopped
+type State struct {
+	Streams map[string]StreamState `json:"streams"`
+}
+
+type StreamState struct {
+	// Cursor is the updated_at of the last emitted record
+	Cursor time.Time `json:"cursor"`
+	// Offset is the number of records emitted so far
+	Offset int64 `json:"offset"`
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email" format:"email"`
+	Age       int       `json:"age" minimum:"18"`
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Padding   string    `json:"padding,omitempty"`
+}
+
+type Purchase struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Product   string    `json:"product"`
+	Quantity  int       `json:"quantity" minimum:"1"`
+	Amount    float64   `json:"amount" minimum:"0"`
+	Currency  string    `json:"currency" enum:"USD,EUR,CAD"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Padding   string    `json:"padding,omitempty"`
+}
+
+type Event struct {
+	ID         int64             `json:"id"`
+	Type       string            `json:"type" enum:"page_view,click,signup"`
+	UserID     *int64            `json:"user_id"`
+	Properties map[string]string `json:"properties"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Padding    string            `json:"padding,omitempty"`
+}
+
+var (
+	firstNames = []string{"Ada", "Alan", "Grace", "Linus", "Barbara", "Ken", "Margaret", "Dennis", "Frances", "Edsger"}
+	lastNames  = []string{"Lovelace", "Turing", "Hopper", "Torvalds", "Liskov", "Thompson", "Hamilton", "Ritchie", "Allen", "Dijkstra"}
+	countries  = []string{"CA", "US", "GB", "DE", "FR", "JP", "BR", "IN"}
+	products   = []string{"keyboard", "mouse", "monitor", "laptop", "headset", "webcam", "dock", "cable"}
+	currencies = []string{"USD", "EUR", "CAD"}
+	eventTypes = []string{"page_view", "click", "signup"}
+	pages      = []string{"/", "/pricing", "/docs", "/blog", "/signup"}
+)
+
+func NewFakerSource() airbyte.Source {
+	return FakerSource{}
+}
+
+func (f FakerSource) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecification, error) {
+	props, err := airbyte.InferSchemaFromStructWithOptions(Config{}, schema.Options{Nullability: schema.NullableExplicit}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+
+	return &airbyte.ConnectorSpecification{
+		DocumentationURL:      "https://bitstrapped.com",
+		ChangeLogURL:          "https://bitstrapped.com",
+		SupportsIncremental:   true,
+		SupportsNormalization: false,
+		SupportsDBT:           false,
+		SupportedDestinationSyncModes: []airbyte.DestinationSyncMode{
+			airbyte.DestinationSyncModeOverwrite,
+			airbyte.DestinationSyncModeAppend,
+		},
+		ConnectionSpecification: airbyte.ConnectionSpecification{
+			Title:       "Faker Source",
+			Description: "Generates deterministic synthetic data for load and end-to-end tests",
+			Type:        "object",
+			Required:    []airbyte.PropertyName{"seed", "streams"},
+			Properties:  props,
+		},
+	}, nil
+}
+
+func (f FakerSource) Check(srcCfgPath string, logTracker airbyte.LogTracker) error {
+	_, err := loadConfig(srcCfgPath)
+	return err
+}
+
+func (f FakerSource) Discover(srcCfgPath string, logTracker airbyte.LogTracker) (*airbyte.Catalog, error) {
+	cfg, err := loadConfig(srcCfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []airbyte.Stream
+	for _, sc := range cfg.Streams {
+		props, err := airbyte.InferSchemaFromStructWithOptions(sc.Kind.template(), schema.Options{Nullability: schema.NullableExplicit}, logTracker)
+		if err != nil {
+			return nil, err
+		}
+
+		streams = append(streams, airbyte.Stream{
+			Name:       sc.Name,
+			JSONSchema: props,
+			SupportedSyncModes: []airbyte.SyncMode{
+				airbyte.SyncModeFullRefresh,
+				airbyte.SyncModeIncremental,
+			},
+			SourceDefinedCursor:     true,
+			DefaultCursorField:      []string{"updated_at"},
+			SourceDefinedPrimaryKey: [][]string{{"id"}},
+			Namespace:               "faker",
+		})
+	}
+
+	return &airbyte.Catalog{Streams: streams}, nil
+}
+
+func (f FakerSource) Read(sourceCfgPath string, prevStatePath string, configuredCat *airbyte.ConfiguredCatalog,
+	tracker airbyte.MessageTracker) error {
+	cfg, err := loadConfig(sourceCfgPath)
+	if err != nil {
+		return err
+	}
+
+	st := State{Streams: map[string]StreamState{}}
+	if prevStatePath != "" {
+		if err := airbyte.UnmarshalFromPath(prevStatePath, &st); err != nil {
+			return err
+		}
+		if st.Streams == nil {
+			st.Streams = map[string]StreamState{}
+		}
+	}
+
+	for _, stream := range configuredCat.Streams {
+		sc, ok := cfg.stream(stream.Stream.Name)
+		if !ok {
+			return fmt.Errorf("stream %s is not configured", stream.Stream.Name)
+		}
+
+		var start int64
+		if stream.SyncMode == airbyte.SyncModeIncremental {
+			start = st.Streams[sc.Name].Offset
+		}
+		if err := tracker.Log(airbyte.LogLevelInfo, fmt.Sprintf("generating %d %s records from offset %d", sc.Records, sc.Name, start)); err != nil {
+			return err
+		}
+
+		for i := start; i < start+sc.Records; i++ {
+			rec, cursor := sc.generate(cfg.Seed, i)
+			if err := tracker.Record(rec, stream.Stream.Name, stream.Stream.Namespace); err != nil {
+				return err
+			}
+
+			st.Streams[sc.Name] = StreamState{Cursor: cursor, Offset: i + 1}
+			if sc.StateEvery > 0 && (i+1-start)%sc.StateEvery == 0 {
+				if err := tracker.State(&st); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tracker.State(&st); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadConfig(path string) (*Config, error) {
+	var cfg Config
+	if err := airbyte.UnmarshalFromPath(path, &cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Streams) == 0 {
+		return nil, errors.New("no streams configured")
+	}
+	seen := map[string]bool{}
+	for _, sc := range cfg.Streams {
+		if sc.Name == "" {
+			return nil, errors.New("stream without a name")
+		}
+		if seen[sc.Name] {
+			return nil, fmt.Errorf("stream %s is configured more than once", sc.Name)
+		}
+		seen[sc.Name] = true
+		if sc.Kind.template() == nil {
+			return nil, fmt.Errorf("stream %s: unknown kind %q", sc.Name, sc.Kind)
+		}
+		if sc.Records < 0 || sc.RecordSize < 0 || sc.StateEvery < 0 {
+			return nil, fmt.Errorf("stream %s: records, record_size and state_every can't be negative", sc.Name)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) stream(name string) (StreamConfig, bool) {
+	for _, sc := range c.Streams {
+		if sc.Name == name {
+			return sc, true
+		}
+	}
+	return StreamConfig{}, false
+}
+
+// template returns the zero record of a kind, which is used to infer its schema
+func (k Kind) template() interface{} {
+	switch k {
+	case KindUsers:
+		return User{}
+	case KindPurchases:
+		return Purchase{}
+	case KindEvents:
+		return Event{}
+	}
+	return nil
+}
+
+// generate returns record i of the stream along with its cursor
+// Every record has its own random source, so a record is the same no matter which sync or offset generates it
+func (sc StreamConfig) generate(seed int64, i int64) (interface{}, time.Time) {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%d", seed, sc.Name, i)
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	id := i + 1
+	updatedAt := epoch.Add(time.Duration(i) * time.Minute)
+	padding := strings.Repeat("x", sc.RecordSize)
+
+	switch sc.Kind {
+	case KindUsers:
+		first, last := pick(r, firstNames), pick(r, lastNames)
+		return User{
+			ID:        id,
+			Name:      first + " " + last,
+			Email:     fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), id),
+			Age:       18 + r.Intn(70),
+			Country:   pick(r, countries),
+			CreatedAt: updatedAt.Add(-time.Duration(r.Intn(1000)) * time.Hour),
+			UpdatedAt: updatedAt,
+			Padding:   padding,
+		}, updatedAt
+	case KindPurchases:
+		quantity := 1 + r.Intn(5)
+		return Purchase{
+			ID:        id,
+			UserID:    1 + r.Int63n(1000),
+			Product:   pick(r, products),
+			Quantity:  quantity,
+			Amount:    float64(quantity) * float64(100+r.Intn(99900)) / 100,
+			Currency:  pick(r, currencies),
+			UpdatedAt: updatedAt,
+			Padding:   padding,
+		}, updatedAt
+	default:
+		e := Event{
+			ID:   id,
+			Type: pick(r, eventTypes),
+			Properties: map[string]string{
+				"page":    pick(r, pages),
+				"browser": pick(r, []string{"firefox", "chrome", "safari"}),
+			},
+			UpdatedAt: updatedAt,
+			Padding:   padding,
+		}
+		if r.Intn(3) > 0 {
+			userID := 1 + r.Int63n(1000)
+			e.UserID = &userID
+		}
+		return e, updatedAt
+	}
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
@@ -0,0 +1,93 @@
+package faker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+var testConfig = Config{
+	Seed: 42,
+	Streams: []StreamConfig{
+		{Name: "users", Kind: KindUsers, Records: 25, StateEvery: 10},
+		{Name: "purchases", Kind: KindPurchases, Records: 10, RecordSize: 64},
+		{Name: "events", Kind: KindEvents, Records: 10},
+	},
+}
+
+func TestAcceptance(t *testing.T) {
+	airbytetest.RunAcceptanceTests(t, NewFakerSource(), airbytetest.AcceptanceConfig{
+		Config: testConfig,
+		CursorPaths: map[string][]string{
+			"users":     {"streams", "users", "cursor"},
+			"purchases": {"streams", "purchases", "cursor"},
+			"events":    {"streams", "events", "cursor"},
+		},
+	})
+}
+
+func TestDeterministic(t *testing.T) {
+	out, err := airbytetest.Discover(NewFakerSource(), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := airbytetest.ConfigureCatalog(out.Catalog, airbyte.SyncModeIncremental)
+
+	first, err := airbytetest.Read(NewFakerSource(), testConfig, catalog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := airbytetest.Read(NewFakerSource(), testConfig, catalog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data(first, "users"), data(again, "users")) {
+		t.Fatal("the same seed generated different records")
+	}
+	// 2 checkpoints for users, one final state per stream
+	if len(first.States) != 5 {
+		t.Fatalf("expected 5 states, got %d", len(first.States))
+	}
+
+	var st State
+	if err := first.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Streams["users"].Offset != 25 {
+		t.Fatalf("expected users offset 25, got %d", st.Streams["users"].Offset)
+	}
+
+	// the next incremental sync continues where the first one stopped
+	next, err := airbytetest.Read(NewFakerSource(), testConfig, catalog, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var u User
+	if err := next.Records["users"][0].Unmarshal(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 26 || !u.UpdatedAt.After(st.Streams["users"].Cursor) {
+		t.Fatalf("expected the sync to continue after user 25, got %+v", u)
+	}
+
+	// a different seed generates different data
+	other := testConfig
+	other.Seed = 7
+	diff, err := airbytetest.Read(NewFakerSource(), other, catalog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(data(first, "users"), data(diff, "users")) {
+		t.Fatal("different seeds generated the same records")
+	}
+}
+
+func data(out *airbytetest.Output, stream string) []string {
+	var d []string
+	for _, r := range out.Records[stream] {
+		d = append(d, string(r.Data))
+	}
+	return d
+}
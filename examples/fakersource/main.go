@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/examples/fakersource/faker"
+)
+
+func main() {
+	fsrc := faker.NewFakerSource()
+	runner := airbyte.NewSourceRunner(fsrc, os.Stdout)
+	err := runner.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/filedestination"
+)
+
+func main() {
+	// the destination path is resolved in /local, where airbyte mounts local files
+	dst := filedestination.Destination{Dir: "/local"}
+
+	runner := airbyte.NewDestinationRunner(dst, os.Stdin, os.Stdout)
+	if err := runner.Start(); err != nil {
+		log.Fatal(err)
+	}
+}
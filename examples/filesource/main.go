@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/filesource"
+)
+
+func main() {
+	// relative globs are resolved in /local, where airbyte mounts local files
+	src := filesource.Source{Dir: "/local", Workers: 2}
+
+	runner := airbyte.NewSourceRunner(src, os.Stdout)
+	if err := runner.Start(); err != nil {
+		log.Fatal(err)
+	}
+}
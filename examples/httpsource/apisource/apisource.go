@@ -8,10 +8,13 @@ import (
 	"time"
 
 	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/httpstream/auth"
+	"github.com/bitstrapped/airbyte/httpstream/retry"
 )
 
 type APISource struct {
 	baseURL string
+	client  *http.Client
 }
 
 type LastSyncTime struct {
@@ -22,9 +25,15 @@ type HTTPConfig struct {
 	APIKey string `json:"apiKey"`
 }
 
-func NewAPISource(baseURL string) airbyte.Source {
+// NewAPISource creates the source, all requests go through client so tests can inject a recording transport
+// a nil client uses http.DefaultClient
+func NewAPISource(baseURL string, client *http.Client) airbyte.Source {
+	if client == nil {
+		client = http.DefaultClient
+	}
 	return APISource{
 		baseURL: baseURL,
+		client:  client,
 	}
 }
 
@@ -50,7 +59,7 @@ func (h APISource) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecif
 				Properties: map[airbyte.PropertyName]airbyte.PropertySpec{
 					"apiKey": {
 						Description: "api key to access http source, valid uuid",
-						Examples:    []string{"xxxx-xxxx-xxxx-xxxx"},
+						Examples:    []interface{}{"xxxx-xxxx-xxxx-xxxx"},
 						PropertyType: airbyte.PropertyType{
 							Type: []airbyte.PropType{
 								airbyte.String,
@@ -73,7 +82,8 @@ func (h APISource) Check(srcCfgPath string, logTracker airbyte.LogTracker) error
 		return err
 	}
 
-	resp, err := http.Get(fmt.Sprintf("%s/ping?key=%s", h.baseURL, srcCfg.APIKey))
+	h.client = retry.Client(retry.Options{}, logTracker.Log, authenticate(h.client, srcCfg))
+	resp, err := h.client.Get(fmt.Sprintf("%s/ping", h.baseURL))
 	if err != nil {
 		return err
 	}
@@ -164,6 +174,8 @@ func (h APISource) Read(sourceCfgPath string, prevStatePath string, configuredCa
 		return err
 	}
 
+	h.client = retry.Client(retry.Options{}, tracker.Log, authenticate(h.client, src))
+
 	// see if there is a last sync
 	var st LastSyncTime
 	_ = airbyte.UnmarshalFromPath(sourceCfgPath, &st)
@@ -174,8 +186,8 @@ func (h APISource) Read(sourceCfgPath string, prevStatePath string, configuredCa
 	for _, stream := range configuredCat.Streams {
 		if stream.Stream.Name == "users" {
 			var u []User
-			uri := fmt.Sprintf("https://api.bistrapped.com/users?apiKey=%s", src.APIKey)
-			if err := httpGet(uri, &u); err != nil {
+			uri := fmt.Sprintf("%s/users", h.baseURL)
+			if err := h.httpGet(uri, &u); err != nil {
 				return err
 			}
 
@@ -189,8 +201,8 @@ func (h APISource) Read(sourceCfgPath string, prevStatePath string, configuredCa
 
 		if stream.Stream.Name == "payments" {
 			var p []Payment
-			uri := fmt.Sprintf("%s/payments?apiKey=%s", h.baseURL, src.APIKey)
-			if err := httpGet(uri, &p); err != nil {
+			uri := fmt.Sprintf("%s/payments", h.baseURL)
+			if err := h.httpGet(uri, &p); err != nil {
 				return err
 			}
 
@@ -208,11 +220,21 @@ func (h APISource) Read(sourceCfgPath string, prevStatePath string, configuredCa
 	})
 }
 
-func httpGet(uri string, v interface{}) error {
-	resp, err := http.Get(uri)
+// authenticate sends the api key in a header, unlike a query param it doesn't end up in access logs
+func authenticate(client *http.Client, cfg HTTPConfig) *http.Client {
+	return auth.Client(auth.APIKey{Key: cfg.APIKey, Header: "X-API-Key"}, client)
+}
+
+func (h APISource) httpGet(uri string, v interface{}) error {
+	resp, err := h.client.Get(uri)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// don't decode error pages into records
+		return fmt.Errorf("GET %s: %s", uri, resp.Status)
+	}
 	return json.NewDecoder(resp.Body).Decode(v)
 }
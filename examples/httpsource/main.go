@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"net/http"
 	"os"
 
 	"github.com/bitstrapped/airbyte"
@@ -9,7 +10,7 @@ import (
 )
 
 func main() {
-	hsrc := apisource.NewAPISource("https://api.bitstrapped.com")
+	hsrc := apisource.NewAPISource("https://api.bitstrapped.com", http.DefaultClient)
 	runner := airbyte.NewSourceRunner(hsrc, os.Stdout)
 	err := runner.Start()
 	if err != nil {
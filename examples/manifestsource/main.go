@@ -0,0 +1,29 @@
+package main
+
+import (
+	_ "embed"
+	"log"
+	"os"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/manifest"
+)
+
+//go:embed manifest.yaml
+var manifestYAML []byte
+
+func main() {
+	m, err := manifest.Parse(manifestYAML)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := m.Source()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	runner := airbyte.NewSourceRunner(src, os.Stdout)
+	if err := runner.Start(); err != nil {
+		log.Fatal(err)
+	}
+}
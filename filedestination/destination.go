@@ -0,0 +1,177 @@
+// Package filedestination is a ready-made airbyte destination writing every stream to a jsonl or csv file
+// Records are written to a temp file which is committed into the stream file at every state, so a failed or killed
+// sync leaves the files as they were at its last state. It's handy as a local sink to debug sources with
+//
+// Example config
+//
+//	{
+//		"destination_path": "exports",
+//		"format": "csv"
+//	}
+package filedestination
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+// Fields of every written record
+const (
+	// EmittedAtField is the time the source emitted the record in milliseconds since the epoch
+	EmittedAtField = "_airbyte_emitted_at"
+	// DataField is the record data, a json string in csv files
+	DataField = "_airbyte_data"
+)
+
+// Destination is an airbyte.Destination writing the records of every stream to a file of its own
+// The file of a stream is named after the stream, prefixed with the namespace and an underscore if it has one
+//
+// Example usage
+//
+//	err := airbyte.NewDestinationRunner(filedestination.Destination{Dir: "/local"}, os.Stdin, os.Stdout).Start()
+type Destination struct {
+	// Dir is the directory the destination path is resolved in, the working directory if empty
+	// The destination path can't leave it
+	Dir string
+}
+
+var _ airbyte.Destination = Destination{}
+
+type Config struct {
+	DestinationPath string     `json:"destination_path" title:"Destination Path" description:"directory the files are written to" order:"0"`
+	Format          FormatType `json:"format" title:"Format" description:"file format" enum:"jsonl,csv" order:"1"`
+}
+
+// FormatType is the format of the written files
+type FormatType string
+
+const (
+	// FormatJSONL writes a json object with the fields EmittedAtField and DataField per line
+	FormatJSONL FormatType = "jsonl"
+	// FormatCSV writes a header row and the columns EmittedAtField and DataField
+	FormatCSV FormatType = "csv"
+)
+
+// Spec returns the spec of Config
+func (d Destination) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecification, error) {
+	props, err := airbyte.InferSchemaFromStructWithOptions(Config{}, schema.Options{Nullability: schema.NullableExplicit}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+
+	return &airbyte.ConnectorSpecification{
+		SupportsIncremental: true,
+		SupportedDestinationSyncModes: []airbyte.DestinationSyncMode{
+			airbyte.DestinationSyncModeOverwrite,
+			airbyte.DestinationSyncModeAppend,
+		},
+		ConnectionSpecification: airbyte.ConnectionSpecification{
+			Title:       "Files",
+			Description: "Writes every stream to a jsonl or csv file",
+			Type:        "object",
+			Required:    []airbyte.PropertyName{"destination_path", "format"},
+			Properties:  props,
+		},
+	}, nil
+}
+
+// Check fails unless a file can be written to the destination path
+func (d Destination) Check(dstCfgPath string, logTracker airbyte.LogTracker) error {
+	dir, _, err := d.load(dstCfgPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Open creates the destination path and returns the writer of the configured streams
+func (d Destination) Open(dstCfgPath string, configuredCat *airbyte.ConfiguredCatalog,
+	logTracker airbyte.LogTracker) (airbyte.DestinationWriter, error) {
+	dir, cfg, err := d.load(dstCfgPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	w := &writer{files: map[[2]string]*file{}}
+	paths := map[string]string{}
+	for _, cs := range configuredCat.Streams {
+		name := fileName(cs.Stream) + "." + string(cfg.Format)
+		if other, ok := paths[name]; ok {
+			return nil, fmt.Errorf("streams %s and %s are both written to %s", other, cs.Stream.Name, name)
+		}
+		paths[name] = cs.Stream.Name
+
+		f := &file{path: filepath.Join(dir, name), format: cfg.Format}
+		switch cs.DestinationSyncMode {
+		case airbyte.DestinationSyncModeOverwrite:
+			f.replace = true
+		case airbyte.DestinationSyncModeAppend:
+		default:
+			return nil, fmt.Errorf("stream %s: unsupported destination sync mode %q", cs.Stream.Name, cs.DestinationSyncMode)
+		}
+		if err := f.restore(); err != nil {
+			return nil, err
+		}
+		w.files[[2]string{cs.Stream.Namespace, cs.Stream.Name}] = f
+		w.order = append(w.order, f)
+	}
+	return w, nil
+}
+
+// fileName is the file name of a stream without extension, characters which aren't safe in file names are
+// replaced by underscores
+func fileName(s airbyte.Stream) string {
+	name := s.Name
+	if s.Namespace != "" {
+		name = s.Namespace + "_" + name
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// load returns the destination directory and config
+func (d Destination) load(path string) (string, *Config, error) {
+	var cfg Config
+	if err := airbyte.UnmarshalFromPath(path, &cfg); err != nil {
+		return "", nil, err
+	}
+	switch cfg.Format {
+	case FormatJSONL, FormatCSV:
+	default:
+		return "", nil, fmt.Errorf("unknown format %q", cfg.Format)
+	}
+	if cfg.DestinationPath == "" {
+		return "", nil, errors.New("no destination_path configured")
+	}
+
+	dir := cfg.DestinationPath
+	if d.Dir != "" {
+		dir = filepath.Join(d.Dir, dir)
+		if rel, err := filepath.Rel(d.Dir, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil, fmt.Errorf("destination_path %s is outside of %s", cfg.DestinationPath, d.Dir)
+		}
+	}
+	return dir, &cfg, nil
+}
@@ -0,0 +1,146 @@
+package filedestination
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+func catalog() *airbyte.ConfiguredCatalog {
+	return &airbyte.ConfiguredCatalog{Streams: []airbyte.ConfiguredStream{
+		{Stream: airbyte.Stream{Name: "users"}, DestinationSyncMode: airbyte.DestinationSyncModeOverwrite},
+		{Stream: airbyte.Stream{Name: "events", Namespace: "app"}, DestinationSyncMode: airbyte.DestinationSyncModeAppend},
+		{Stream: airbyte.Stream{Name: "orders"}, DestinationSyncMode: airbyte.DestinationSyncModeOverwrite},
+	}}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestDestination(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	config := map[string]interface{}{"destination_path": "out", "format": "jsonl"}
+	dst := Destination{Dir: dir}
+
+	// the first sync creates the destination path
+	if _, err := airbytetest.Write(dst, config, catalog(), strings.NewReader("")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(out, "users.jsonl"), []byte("old user\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(out, "app_events.jsonl"), []byte("old event\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the records after the last state are dropped when the input breaks off
+	input := `{"type": "RECORD", "record": {"stream": "users", "data": {"id": 1}, "emitted_at": 1}}
+{"type": "RECORD", "record": {"stream": "events", "namespace": "app", "data": {"id": "a"}, "emitted_at": 2}}
+{"type": "STATE", "state": {"data": {"cursor": 1}}}
+{"type": "RECORD", "record": {"stream": "users", "data": {"id": 2}, "emitted_at": 3}}
+{"type": "STATE", "state": {"data": {"cursor": 2}}}
+{"type": "RECORD", "record": {"stream": "users", "data": {"id": 3}, "emitted_at": 4}}
+{"type": "RECO`
+	res, err := airbytetest.Write(dst, config, catalog(), strings.NewReader(input))
+	if err == nil {
+		t.Fatal("expected the broken message to fail the sync")
+	}
+	if len(res.States) != 2 || string(res.States[1]) != `{"cursor":2}` {
+		t.Fatalf("expected both states to be passed on, got %s", res.States)
+	}
+	if got := readFile(t, filepath.Join(out, "users.jsonl")); got != `{"_airbyte_emitted_at":1,"_airbyte_data":{"id":1}}`+"\n"+
+		`{"_airbyte_emitted_at":3,"_airbyte_data":{"id":2}}`+"\n" {
+		t.Errorf("unexpected users %q", got)
+	}
+	if got := readFile(t, filepath.Join(out, "app_events.jsonl")); got != "old event\n"+`{"_airbyte_emitted_at":2,"_airbyte_data":{"id":"a"}}`+"\n" {
+		t.Errorf("unexpected events %q", got)
+	}
+	files, err := ioutil.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if strings.Join(names, ",") != "app_events.jsonl,orders.jsonl,users.jsonl" {
+		t.Errorf("expected the stream files without temp files, got %v", names)
+	}
+
+	if _, err := airbytetest.Write(dst, map[string]interface{}{"destination_path": "../elsewhere", "format": "jsonl"},
+		catalog(), strings.NewReader("")); err == nil {
+		t.Error("expected a destination path outside of the directory to fail")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	dir := t.TempDir()
+	config := map[string]interface{}{"destination_path": ".", "format": "csv"}
+	cat := catalog()
+	input := `{"type": "RECORD", "record": {"stream": "events", "namespace": "app", "data": {"note": "a, \"b\""}, "emitted_at": 5}}`
+
+	// appended syncs only write the header once, the records after the last state are committed at the end
+	for i := 0; i < 2; i++ {
+		if _, err := airbytetest.Write(Destination{Dir: dir}, config, cat, strings.NewReader(input)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := readFile(t, filepath.Join(dir, "app_events.csv"))
+	want := "_airbyte_emitted_at,_airbyte_data\n" + strings.Repeat(`5,"{""note"": ""a, \""b\""""}"`+"\n", 2)
+	if got != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+	if got := readFile(t, filepath.Join(dir, "users.csv")); got != "_airbyte_emitted_at,_airbyte_data\n" {
+		t.Errorf("expected an overwritten stream without records to only have a header, got %q", got)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	config := map[string]interface{}{"destination_path": ".", "format": "jsonl"}
+	events := filepath.Join(dir, "app_events.jsonl")
+
+	// a sync killed while appending leaves its journal and part of the batch
+	if err := ioutil.WriteFile(events, []byte("old event\nhalf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".app_events.jsonl.journal"), []byte("10"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// and the temp files of the batch and the journal
+	for _, name := range []string{".app_events.jsonl.123.tmp", ".app_events.jsonl.456.tmp", ".users.jsonl.789.tmp"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("temp"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input := `{"type": "RECORD", "record": {"stream": "events", "namespace": "app", "data": {"id": "a"}, "emitted_at": 2}}`
+	if _, err := airbytetest.Write(Destination{Dir: dir}, config, catalog(), strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, events); got != "old event\n"+`{"_airbyte_emitted_at":2,"_airbyte_data":{"id":"a"}}`+"\n" {
+		t.Errorf("expected the half appended batch to be dropped, got %q", got)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if strings.Join(names, ",") != "app_events.jsonl,orders.jsonl,users.jsonl" {
+		t.Errorf("expected the journal and the temp files to be removed, got %v", names)
+	}
+}
@@ -0,0 +1,278 @@
+package filedestination
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writer writes the records of a sync to the files of their streams
+type writer struct {
+	files map[[2]string]*file
+	// order is the catalog order, files are committed in it
+	order []*file
+}
+
+func (w *writer) Write(data json.RawMessage, streamName string, namespace string, emittedAt int64) error {
+	f, ok := w.files[[2]string{namespace, streamName}]
+	if !ok {
+		return fmt.Errorf("record of stream %s which isn't configured", streamName)
+	}
+	return f.write(data, emittedAt)
+}
+
+// Flush commits the records of every stream written since the last state
+func (w *writer) Flush() error {
+	for _, f := range w.order {
+		if err := f.commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close commits the records since the last state if the sync succeeded and removes them otherwise
+// Overwritten streams without records end up with empty files
+func (w *writer) Close(succeeded bool) error {
+	if !succeeded {
+		for _, f := range w.order {
+			f.discard()
+		}
+		return nil
+	}
+
+	for _, f := range w.order {
+		if f.replace && f.tmp == nil {
+			if err := f.begin(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		w.Close(false)
+		return err
+	}
+	return nil
+}
+
+// file is the file of a stream, the records since the last state are written to a temp file next to it which is
+// renamed over the file at the first commit of an overwritten stream and appended to it otherwise, so a commit
+// costs a copy of its records. An append is journaled, a sync killed while appending leaves a journal with the
+// committed size of the file which the next sync truncates it back to
+type file struct {
+	path   string
+	format FormatType
+	// replace is set until the first commit of an overwritten stream, which replaces the file instead of
+	// extending it
+	replace bool
+
+	tmp *os.File
+	w   *bufio.Writer
+	csv *csv.Writer
+}
+
+func (f *file) write(data json.RawMessage, emittedAt int64) error {
+	if f.tmp == nil {
+		if err := f.begin(); err != nil {
+			return err
+		}
+	}
+
+	if f.csv != nil {
+		return f.csv.Write([]string{strconv.FormatInt(emittedAt, 10), string(data)})
+	}
+	b, err := json.Marshal(struct {
+		EmittedAt int64           `json:"_airbyte_emitted_at"`
+		Data      json.RawMessage `json:"_airbyte_data"`
+	}{emittedAt, data})
+	if err != nil {
+		return err
+	}
+	f.w.Write(b)
+	return f.w.WriteByte('\n')
+}
+
+// begin starts the temp file of the next commit, csv files get a header unless the file they are appended to
+// has one
+func (f *file) begin() error {
+	empty := true
+	if !f.replace {
+		info, err := os.Stat(f.path)
+		switch {
+		case err == nil:
+			empty = info.Size() == 0
+		case !os.IsNotExist(err):
+			return err
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), f.tempPattern())
+	if err != nil {
+		return err
+	}
+	f.tmp = tmp
+	f.w = bufio.NewWriter(tmp)
+	if f.format == FormatCSV {
+		f.csv = csv.NewWriter(f.w)
+		if empty {
+			return f.csv.Write([]string{EmittedAtField, DataField})
+		}
+	}
+	return nil
+}
+
+// commit moves the records of the temp file into the file once they are on disk
+func (f *file) commit() error {
+	if f.tmp == nil {
+		return nil
+	}
+	if f.csv != nil {
+		f.csv.Flush()
+		if err := f.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := f.w.Flush(); err != nil {
+		return err
+	}
+	if err := f.tmp.Sync(); err != nil {
+		return err
+	}
+	if err := f.tmp.Close(); err != nil {
+		return err
+	}
+	if f.replace {
+		if err := os.Rename(f.tmp.Name(), f.path); err != nil {
+			return err
+		}
+	} else {
+		if err := f.append(f.tmp.Name()); err != nil {
+			return err
+		}
+		os.Remove(f.tmp.Name())
+	}
+	f.tmp, f.w, f.csv = nil, nil, nil
+	f.replace = false
+	return nil
+}
+
+// append appends the records of a temp file to the file, the journal keeps the committed size of the file until
+// they are on disk
+func (f *file) append(name string) error {
+	out, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	size, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if err := f.journal(size); err != nil {
+		return err
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		out.Truncate(size)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Truncate(size)
+		return err
+	}
+	return os.Remove(f.journalPath())
+}
+
+// journal writes the committed size of the file to its journal, the journal is complete once it exists
+func (f *file) journal(size int64) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), f.tempPattern())
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(strconv.FormatInt(size, 10))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.journalPath())
+}
+
+// restore cleans up after a sync killed while writing the file, it removes the temp files which were left and
+// truncates the file to the committed size of its journal if the sync was appending
+func (f *file) restore() error {
+	dir := filepath.Dir(f.path)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if f.isTemp(fi.Name()) {
+			if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	b, err := ioutil.ReadFile(f.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return fmt.Errorf("journal of %s: %w", f.path, err)
+	}
+	if err := os.Truncate(f.path, size); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(f.journalPath())
+}
+
+// tempPattern is the ioutil.TempFile pattern of the temp files of the file
+func (f *file) tempPattern() string {
+	return "." + filepath.Base(f.path) + ".*.tmp"
+}
+
+// isTemp reports whether name is a temp file of the file, ioutil.TempFile puts random digits in place of the *
+func (f *file) isTemp(name string) bool {
+	prefix, suffix := "."+filepath.Base(f.path)+".", ".tmp"
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return false
+	}
+	_, err := strconv.ParseUint(name[len(prefix):len(name)-len(suffix)], 10, 64)
+	return err == nil
+}
+
+func (f *file) journalPath() string {
+	return filepath.Join(filepath.Dir(f.path), "."+filepath.Base(f.path)+".journal")
+}
+
+// discard removes the temp file and with it the records since the last commit
+func (f *file) discard() {
+	if f.tmp == nil {
+		return
+	}
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+	f.tmp, f.w, f.csv = nil, nil, nil
+}
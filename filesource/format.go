@@ -0,0 +1,67 @@
+package filesource
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bitstrapped/airbyte/httpstream"
+)
+
+// errSampled stops reading a file once enough records were sampled
+var errSampled = errors.New("sampled")
+
+// records reads the records of the file at path and hands them to fn, the values of csv records are strings
+func (f Format) records(path string, fn func(record map[string]interface{}) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if f.Type == FormatParquet {
+		fi, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		p, err := openParquet(file, fi.Size())
+		if err != nil {
+			return err
+		}
+		return p.each(fn)
+	}
+
+	var r io.Reader = bufio.NewReaderSize(file, 1<<16)
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	_, err = f.decoder().Decode(r, func(v interface{}) error {
+		rec, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("record of type %T isn't an object", v)
+		}
+		return fn(rec)
+	})
+	return err
+}
+
+func (f Format) decoder() httpstream.Decoder {
+	if f.Type == FormatCSV {
+		comma, _ := utf8.DecodeRuneInString(f.Delimiter)
+		if comma == utf8.RuneError {
+			comma = 0
+		}
+		return httpstream.CSVDecoder{Comma: comma, Header: f.ColumnNames}
+	}
+	return httpstream.JSONLinesDecoder{}
+}
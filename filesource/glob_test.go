@@ -0,0 +1,43 @@
+package filesource
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.csv", "x/b.csv", "x/y/c.csv", "x/y/c.json", "z/d.csv"} {
+		writeFile(t, filepath.Join(dir, name), "", time.Now())
+	}
+
+	for pattern, want := range map[string][]string{
+		"*.csv":        {"a.csv"},
+		"x/*.csv":      {"x/b.csv"},
+		"**/*.csv":     {"a.csv", "x/b.csv", "x/y/c.csv", "z/d.csv"},
+		"x/**/*.csv":   {"x/b.csv", "x/y/c.csv"},
+		"x/**":         {"x/b.csv", "x/y/c.csv", "x/y/c.json"},
+		"**/y/*":       {"x/y/c.csv", "x/y/c.json"},
+		"[xz]/**/?.*":  {"x/b.csv", "x/y/c.csv", "x/y/c.json", "z/d.csv"},
+		"missing/**/*": nil,
+	} {
+		matches, err := glob(filepath.Join(dir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, filepath.ToSlash(strings.TrimPrefix(m, dir+string(filepath.Separator))))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", pattern, want, got)
+		}
+	}
+
+	if _, err := glob(filepath.Join(dir, "**/[")); err == nil {
+		t.Error("expected a bad pattern error")
+	}
+}
@@ -0,0 +1,172 @@
+package filesource
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// infer infers the schema of a stream from the records of its newest files, csv values are sampled as the type
+// they look like. Every field is nullable, the sampled records don't prove a field is always set
+func (s Source) infer(sc StreamConfig, files []file) (airbyte.Properties, error) {
+	n := s.SampleRows
+	if n <= 0 {
+		n = 1000
+	}
+
+	root := &inferred{}
+	for i := len(files) - 1; i >= 0 && n > 0; i-- {
+		err := sc.Format.records(files[i].path, func(rec map[string]interface{}) error {
+			if sc.Format.Type == FormatCSV {
+				for k, v := range rec {
+					s, _ := v.(string)
+					rec[k] = guess(s)
+				}
+			}
+			root.add(rec)
+			if n--; n == 0 {
+				return errSampled
+			}
+			return nil
+		})
+		if err != nil && err != errSampled {
+			return airbyte.Properties{}, err
+		}
+	}
+
+	props := map[airbyte.PropertyName]airbyte.PropertySpec{}
+	for name, p := range root.props {
+		props[airbyte.PropertyName(name)] = p.spec()
+	}
+	props[FileURLField] = airbyte.PropertySpec{PropertyType: airbyte.PropertyType{Type: []airbyte.PropType{airbyte.String}}}
+	props[LastModifiedField] = airbyte.PropertySpec{
+		PropertyType: airbyte.PropertyType{Type: []airbyte.PropType{airbyte.String}},
+		Format:       "date-time",
+	}
+	return airbyte.Properties{Properties: props}, nil
+}
+
+// inferred collects the types of the sampled values of a field
+type inferred struct {
+	types map[airbyte.PropType]bool
+	props map[string]*inferred
+	items *inferred
+}
+
+func (n *inferred) add(v interface{}) {
+	if n.types == nil {
+		n.types = map[airbyte.PropType]bool{}
+	}
+	switch v := v.(type) {
+	case nil:
+		n.types[airbyte.Null] = true
+	case bool:
+		n.types[airbyte.Boolean] = true
+	case string, []byte:
+		n.types[airbyte.String] = true
+	case json.Number:
+		if strings.ContainsAny(string(v), ".eE") {
+			n.types[airbyte.Number] = true
+		} else {
+			n.types[airbyte.Integer] = true
+		}
+	case int64, uint64:
+		n.types[airbyte.Integer] = true
+	case float64:
+		n.types[airbyte.Number] = true
+	case map[string]interface{}:
+		n.types[airbyte.Object] = true
+		if n.props == nil {
+			n.props = map[string]*inferred{}
+		}
+		for k, x := range v {
+			p, ok := n.props[k]
+			if !ok {
+				p = &inferred{}
+				n.props[k] = p
+			}
+			p.add(x)
+		}
+	case []interface{}:
+		n.types[airbyte.Array] = true
+		if n.items == nil {
+			n.items = &inferred{}
+		}
+		for _, x := range v {
+			n.items.add(x)
+		}
+	}
+}
+
+func (n *inferred) spec() airbyte.PropertySpec {
+	var spec airbyte.PropertySpec
+	for _, t := range []airbyte.PropType{airbyte.String, airbyte.Number, airbyte.Integer, airbyte.Boolean, airbyte.Object, airbyte.Array} {
+		// integers are numbers too
+		if n.types[t] && !(t == airbyte.Integer && n.types[airbyte.Number]) {
+			spec.Type = append(spec.Type, t)
+		}
+	}
+	if len(spec.Type) == 0 {
+		// only nulls were sampled
+		spec.Type = []airbyte.PropType{airbyte.String}
+	}
+	spec.Type = append(spec.Type, airbyte.Null)
+
+	if len(n.props) > 0 {
+		spec.Properties = make(map[airbyte.PropertyName]airbyte.PropertySpec, len(n.props))
+		for name, p := range n.props {
+			spec.Properties[airbyte.PropertyName(name)] = p.spec()
+		}
+	}
+	if n.items != nil && len(n.items.types) > 0 {
+		items := n.items.spec()
+		spec.Items = &items
+	}
+	return spec
+}
+
+// guess returns the value a csv value looks like, empty values are null
+func guess(s string) interface{} {
+	switch {
+	case s == "":
+		return nil
+	case isNumber(s):
+		return json.Number(s)
+	case strings.EqualFold(s, "true"), strings.EqualFold(s, "false"):
+		return strings.EqualFold(s, "true")
+	}
+	return s
+}
+
+// cast converts the values of a csv record to the types of the stream schema, empty values are null
+// Values which don't match their type are left as strings
+func cast(rec map[string]interface{}, props map[airbyte.PropertyName]airbyte.PropertySpec) {
+	for k, v := range rec {
+		s, _ := v.(string)
+		types := props[airbyte.PropertyName(k)].Type
+		switch {
+		case s == "":
+			rec[k] = nil
+		case (hasType(types, airbyte.Integer) || hasType(types, airbyte.Number)) && isNumber(s):
+			rec[k] = json.Number(s)
+		case hasType(types, airbyte.Boolean) && (strings.EqualFold(s, "true") || strings.EqualFold(s, "false")):
+			rec[k] = strings.EqualFold(s, "true")
+		}
+	}
+}
+
+// isNumber reports whether s is a json number, so 007 and 1,5 aren't numbers
+func isNumber(s string) bool {
+	return s != "" && (s[0] == '-' || s[0] >= '0' && s[0] <= '9') && s[len(s)-1] >= '0' && s[len(s)-1] <= '9' &&
+		json.Valid([]byte(s))
+}
+
+func hasType(types []airbyte.PropType, t airbyte.PropType) bool {
+	for _, pt := range types {
+		if pt == t {
+			return true
+		}
+	}
+	return false
+}
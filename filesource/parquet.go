@@ -0,0 +1,222 @@
+package filesource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetFile reads the rows of a parquet file with a flat schema, nested and repeated columns aren't supported
+type parquetFile struct {
+	file *parquet.File
+	// columns are in the order of the leaf columns of the file
+	columns []parquetColumn
+}
+
+// valueKind is how the physical values of a column are turned into json values, it comes from the logical type
+type valueKind int
+
+const (
+	kindPlain valueKind = iota
+	kindString
+	kindDate
+	kindTimestampMillis
+	kindTimestampMicros
+	kindTimestampNanos
+	kindDecimal
+	kindUnsigned
+	kindUUID
+)
+
+type parquetColumn struct {
+	name  string
+	kind  valueKind
+	scale int
+}
+
+func openParquet(r io.ReaderAt, size int64) (*parquetFile, error) {
+	f, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %w", err)
+	}
+
+	p := &parquetFile{file: f}
+	for _, field := range f.Schema().Fields() {
+		col, err := newParquetColumn(field)
+		if err != nil {
+			return nil, err
+		}
+		p.columns = append(p.columns, col)
+	}
+	return p, nil
+}
+
+func newParquetColumn(field parquet.Field) (parquetColumn, error) {
+	col := parquetColumn{name: field.Name()}
+	switch {
+	case !field.Leaf():
+		return col, fmt.Errorf("parquet: nested column %s is not supported", col.name)
+	case field.Repeated():
+		return col, fmt.Errorf("parquet: repeated column %s is not supported", col.name)
+	}
+
+	// parquet-go turns the converted types of files without logical types into logical types
+	logical := field.Type().LogicalType()
+	switch {
+	case logical == nil:
+	case logical.UTF8 != nil, logical.Enum != nil, logical.Json != nil:
+		col.kind = kindString
+	case logical.Decimal != nil:
+		col.kind = kindDecimal
+		col.scale = int(logical.Decimal.Scale)
+	case logical.Date != nil:
+		col.kind = kindDate
+	case logical.Timestamp != nil:
+		unit := logical.Timestamp.Unit
+		switch {
+		case unit.Millis != nil:
+			col.kind = kindTimestampMillis
+		case unit.Micros != nil:
+			col.kind = kindTimestampMicros
+		case unit.Nanos != nil:
+			col.kind = kindTimestampNanos
+		}
+	case logical.Integer != nil:
+		if !logical.Integer.IsSigned {
+			col.kind = kindUnsigned
+		}
+	case logical.UUID != nil:
+		col.kind = kindUUID
+	}
+	return col, nil
+}
+
+// each hands every row of the file to fn, null values are nil
+func (p *parquetFile) each(fn func(row map[string]interface{}) error) error {
+	r := parquet.NewReader(p.file)
+	defer r.Close()
+
+	rows := make([]parquet.Row, 128)
+	for {
+		n, err := r.ReadRows(rows)
+		for _, row := range rows[:n] {
+			rec := make(map[string]interface{}, len(p.columns))
+			for _, v := range row {
+				c := &p.columns[v.Column()]
+				rec[c.name] = c.value(v)
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parquet: %w", err)
+		}
+	}
+}
+
+// value turns a parquet value into a json value: dates and timestamps become RFC 3339 strings,
+// decimals exact numbers and byte arrays strings unless they are plain binary
+func (c *parquetColumn) value(v parquet.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		i := v.Int32()
+		switch c.kind {
+		case kindDate:
+			return time.Unix(int64(i)*86400, 0).UTC().Format("2006-01-02")
+		case kindDecimal:
+			return decimal(big.NewInt(int64(i)), c.scale)
+		case kindUnsigned:
+			return int64(uint32(i))
+		}
+		return int64(i)
+	case parquet.Int64:
+		i := v.Int64()
+		switch c.kind {
+		case kindTimestampMillis:
+			return timestamp(time.Unix(i/1e3, i%1e3*1e6))
+		case kindTimestampMicros:
+			return timestamp(time.Unix(i/1e6, i%1e6*1e3))
+		case kindTimestampNanos:
+			return timestamp(time.Unix(0, i))
+		case kindDecimal:
+			return decimal(big.NewInt(i), c.scale)
+		case kindUnsigned:
+			return uint64(i)
+		}
+		return i
+	case parquet.Int96:
+		// legacy timestamps: nanoseconds of the day and the julian day
+		i := v.Int96()
+		nanos := int64(uint64(i[1])<<32 | uint64(i[0]))
+		return timestamp(time.Unix((int64(i[2])-2440588)*86400, nanos))
+	case parquet.Float:
+		f := v.Float()
+		if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+			return nil
+		}
+		// formatted as a float32, so 50.1 doesn't turn into 50.099998474121094
+		return json.Number(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	case parquet.Double:
+		f := v.Double()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return f
+	}
+
+	// the reader reuses the buffers of byte arrays
+	b := append([]byte(nil), v.ByteArray()...)
+	switch c.kind {
+	case kindString:
+		return string(b)
+	case kindDecimal:
+		i := new(big.Int).SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			// two's complement
+			i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+		}
+		return decimal(i, c.scale)
+	case kindUUID:
+		if len(b) == 16 {
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+		}
+	}
+	return b
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// decimal formats an unscaled decimal exactly
+func decimal(unscaled *big.Int, scale int) json.Number {
+	if scale <= 0 {
+		return json.Number(unscaled.String())
+	}
+	s := new(big.Int).Abs(unscaled).String()
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return json.Number(s)
+}
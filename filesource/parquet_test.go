@@ -0,0 +1,138 @@
+package filesource
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetSource(t *testing.T) {
+	// written by github.com/xitongsys/parquet-go with snappy compression
+	config := map[string]interface{}{
+		"streams": []map[string]interface{}{
+			{"name": "students", "globs": []string{"testdata/*.parquet"}, "format": map[string]string{"type": "parquet"}},
+		},
+	}
+	disc, err := airbytetest.Discover(Source{}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := disc.Catalog.Streams[0].JSONSchema.Properties
+	for field, typ := range map[airbyte.PropertyName]airbyte.PropType{
+		"name": airbyte.String, "age": airbyte.Integer, "id": airbyte.Integer, "weight": airbyte.Number,
+		"sex": airbyte.Boolean, "day": airbyte.String,
+	} {
+		if props[field].Type[0] != typ {
+			t.Errorf("expected %s to be a %s, got %v", field, typ, props[field].Type)
+		}
+	}
+
+	out, err := airbytetest.Read(Source{}, config, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["students"]) != 10 {
+		t.Fatalf("expected 10 students, got %d", len(out.Records["students"]))
+	}
+	var student map[string]interface{}
+	if err := out.Records["students"][1].Unmarshal(&student); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"name": "StudentName", "age": 21.0, "id": 1.0, "weight": 50.1, "sex": false, "day": "2019-05-24"}
+	for k, v := range want {
+		if student[k] != v {
+			t.Errorf("expected %s %v, got %v", k, v, student[k])
+		}
+	}
+}
+
+type encodedRow struct {
+	Name   *string   `parquet:"name,optional,dict"`
+	Amount int64     `parquet:"amount,decimal(2:10)"`
+	At     time.Time `parquet:"at,timestamp(microsecond)"`
+	ID     uint32    `parquet:"id"`
+}
+
+func TestParquetEncodings(t *testing.T) {
+	at := time.Date(2023, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	a, b := "a", "b"
+	rows := []encodedRow{
+		{&a, 1234, at, 1},
+		{nil, -5, at.Add(time.Second), 2},
+		{&b, 0, at.Add(2 * time.Second), 3},
+		{&a, 100, at.Add(3 * time.Second), 4},
+	}
+	want := []map[string]interface{}{
+		{"name": "a", "amount": json.Number("12.34"), "at": "2023-01-02T03:04:05.123456Z", "id": int64(1)},
+		{"name": nil, "amount": json.Number("-0.05"), "at": "2023-01-02T03:04:06.123456Z", "id": int64(2)},
+		{"name": "b", "amount": json.Number("0.00"), "at": "2023-01-02T03:04:07.123456Z", "id": int64(3)},
+		{"name": "a", "amount": json.Number("1.00"), "at": "2023-01-02T03:04:08.123456Z", "id": int64(4)},
+	}
+
+	for name, opts := range map[string][]parquet.WriterOption{
+		"v1 gzip":   {parquet.Compression(&parquet.Gzip)},
+		"v2 snappy": {parquet.Compression(&parquet.Snappy), parquet.DataPageVersion(2)},
+		"v2 zstd":   {parquet.Compression(&parquet.Zstd), parquet.DataPageVersion(2)},
+	} {
+		file := writeParquet(t, rows, opts...)
+		p, err := openParquet(bytes.NewReader(file), int64(len(file)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got []map[string]interface{}
+		if err := p.each(func(row map[string]interface{}) error {
+			got = append(got, row)
+			return nil
+		}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected\n%v\ngot\n%v", name, want, got)
+		}
+	}
+}
+
+func TestParquetUnsupported(t *testing.T) {
+	type address struct {
+		City string `parquet:"city"`
+	}
+	for name, c := range map[string]struct {
+		rows interface{}
+		err  string
+	}{
+		"nested": {[]struct {
+			Address address `parquet:"address"`
+		}{{address{"x"}}}, "parquet: nested column address is not supported"},
+		"repeated": {[]struct {
+			Tags []string `parquet:"tags"`
+		}{{[]string{"x"}}}, "parquet: repeated column tags is not supported"},
+	} {
+		file := writeParquet(t, c.rows)
+		if _, err := openParquet(bytes.NewReader(file), int64(len(file))); err == nil || err.Error() != c.err {
+			t.Errorf("%s: expected %q, got %v", name, c.err, err)
+		}
+	}
+}
+
+// writeParquet writes a slice of rows to a parquet file
+func writeParquet(t *testing.T, rows interface{}, opts ...parquet.WriterOption) []byte {
+	t.Helper()
+	v := reflect.ValueOf(rows)
+	var b bytes.Buffer
+	w := parquet.NewWriter(&b, append([]parquet.WriterOption{parquet.SchemaOf(v.Index(0).Interface())}, opts...)...)
+	for i := 0; i < v.Len(); i++ {
+		if err := w.Write(v.Index(i).Interface()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,381 @@
+// Package filesource is a ready-made airbyte source for csv, jsonl and parquet files on disk
+// Every stream reads the files matching its glob patterns, Discover infers the stream schemas by sampling records
+// and incremental syncs skip the files which were read before by their modification time and path
+//
+// Example config
+//
+//	{
+//		"streams": [{
+//			"name": "orders",
+//			"globs": ["/local/exports/**/orders-*.csv.gz"],
+//			"format": {"type": "csv", "delimiter": ";"},
+//			"primary_key": ["order_id"]
+//		}],
+//		"start_date": "2023-01-01T00:00:00Z"
+//	}
+package filesource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+// Fields added to every record
+const (
+	// FileURLField is the path of the file a record was read from
+	FileURLField = "_ab_source_file_url"
+	// LastModifiedField is the modification time of the file a record was read from, it's the cursor of the stream
+	LastModifiedField = "_ab_source_file_last_modified"
+)
+
+// Source is an airbyte.Source reading the files matching the globs of its config
+//
+// Example usage
+//
+//	err := airbyte.NewSourceRunner(filesource.Source{Dir: "/local"}, os.Stdout).Start()
+type Source struct {
+	// Dir is the directory relative globs are resolved in, the working directory if empty
+	Dir string
+	// SampleRows is the number of records the schema of a stream is inferred from, 1000 if 0
+	// The newest files are sampled first
+	SampleRows int
+	// Workers is the number of streams read at once, see airbyte.StreamScheduler
+	Workers int
+}
+
+var _ airbyte.Source = Source{}
+
+type Config struct {
+	Streams   []StreamConfig `json:"streams" title:"Streams" description:"streams and the files they read" order:"0" minItems:"1"`
+	StartDate string         `json:"start_date,omitempty" title:"Start Date" description:"files modified before it are skipped, e.g. 2023-01-01T00:00:00Z" order:"1"`
+
+	startDate time.Time
+}
+
+type StreamConfig struct {
+	Name       string   `json:"name" description:"stream name"`
+	Globs      []string `json:"globs" description:"glob patterns of the files, ** matches any number of directories" minItems:"1"`
+	Format     Format   `json:"format" description:"how the files are parsed"`
+	PrimaryKey []string `json:"primary_key,omitempty" description:"fields of the primary key"`
+}
+
+// FormatType is the file format of a stream
+type FormatType string
+
+const (
+	FormatCSV     FormatType = "csv"
+	FormatJSONL   FormatType = "jsonl"
+	FormatParquet FormatType = "parquet"
+)
+
+// Format configures how the files of a stream are parsed, csv and jsonl files ending in .gz are decompressed
+type Format struct {
+	Type        FormatType `json:"type" description:"file format" enum:"csv,jsonl,parquet"`
+	Delimiter   string     `json:"delimiter,omitempty" description:"field delimiter of csv files, a comma if empty" maxLength:"1"`
+	ColumnNames []string   `json:"column_names,omitempty" description:"column names of csv files without a header row, the first row is the header if empty"`
+}
+
+// State keeps the cursor of every incremental stream by stream name
+type State map[string]StreamState
+
+// StreamState is the last file read by a stream, files are read in order of modification time and path
+// A file modified since it was read is read again in full
+type StreamState struct {
+	Modified time.Time `json:"modified"`
+	Path     string    `json:"path"`
+}
+
+// done reports whether f was read before the cursor
+func (c StreamState) done(f file) bool {
+	return !c.Modified.IsZero() && !(file{path: c.Path, modified: c.Modified}).before(f)
+}
+
+// Spec returns the spec of Config
+func (s Source) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecification, error) {
+	props, err := airbyte.InferSchemaFromStructWithOptions(Config{}, schema.Options{Nullability: schema.NullableExplicit}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+
+	return &airbyte.ConnectorSpecification{
+		SupportsIncremental: true,
+		SupportedDestinationSyncModes: []airbyte.DestinationSyncMode{
+			airbyte.DestinationSyncModeOverwrite,
+			airbyte.DestinationSyncModeAppend,
+		},
+		ConnectionSpecification: airbyte.ConnectionSpecification{
+			Title:       "Files",
+			Description: "Reads csv, jsonl and parquet files matching glob patterns",
+			Type:        "object",
+			Required:    []airbyte.PropertyName{"streams"},
+			Properties:  props,
+		},
+	}, nil
+}
+
+// Check fails unless every stream matches a file and the newest file of every stream can be parsed
+func (s Source) Check(srcCfgPath string, logTracker airbyte.LogTracker) error {
+	cfg, err := loadConfig(srcCfgPath)
+	if err != nil {
+		return err
+	}
+
+	for _, sc := range cfg.Streams {
+		files, err := s.files(sc)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("stream %s: no files match %v", sc.Name, sc.Globs)
+		}
+		newest := files[len(files)-1]
+		if err := logTracker.Log(airbyte.LogLevelDebug, fmt.Sprintf("checking stream %s with %s", sc.Name, newest.path)); err != nil {
+			return err
+		}
+		err = sc.Format.records(newest.path, func(map[string]interface{}) error {
+			return errSampled
+		})
+		if err != nil && err != errSampled {
+			return fmt.Errorf("stream %s: %s: %w", sc.Name, newest.path, err)
+		}
+	}
+	return nil
+}
+
+// Discover returns every configured stream with the schema inferred from its files
+func (s Source) Discover(srcCfgPath string, logTracker airbyte.LogTracker) (*airbyte.Catalog, error) {
+	cfg, err := loadConfig(srcCfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cat := &airbyte.Catalog{}
+	for _, sc := range cfg.Streams {
+		files, err := s.files(sc)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			msg := fmt.Sprintf("stream %s: no files match %v, the schema only has the file fields", sc.Name, sc.Globs)
+			if err := logTracker.Log(airbyte.LogLevelWarn, msg); err != nil {
+				return nil, err
+			}
+		}
+		props, err := s.infer(sc, files)
+		if err != nil {
+			return nil, fmt.Errorf("stream %s: %w", sc.Name, err)
+		}
+
+		var pk [][]string
+		for _, k := range sc.PrimaryKey {
+			pk = append(pk, []string{k})
+		}
+		cat.Streams = append(cat.Streams, airbyte.Stream{
+			Name:       sc.Name,
+			JSONSchema: props,
+			SupportedSyncModes: []airbyte.SyncMode{
+				airbyte.SyncModeFullRefresh,
+				airbyte.SyncModeIncremental,
+			},
+			SourceDefinedCursor:     true,
+			DefaultCursorField:      []string{LastModifiedField},
+			SourceDefinedPrimaryKey: pk,
+		})
+	}
+	return cat, nil
+}
+
+// Read reads the files of every configured stream, incremental streams skip the files read by previous syncs
+// and checkpoint after every file
+func (s Source) Read(sourceCfgPath string, prevStatePath string, configuredCat *airbyte.ConfiguredCatalog,
+	tracker airbyte.MessageTracker) error {
+	cfg, err := loadConfig(sourceCfgPath)
+	if err != nil {
+		return err
+	}
+
+	state := State{}
+	if prevStatePath != "" {
+		if err := airbyte.UnmarshalFromPath(prevStatePath, &state); err != nil {
+			return err
+		}
+		if state == nil {
+			state = State{}
+		}
+	}
+
+	for _, cs := range configuredCat.Streams {
+		if _, ok := cfg.stream(cs.Stream.Name); !ok {
+			return fmt.Errorf("stream %s is not configured", cs.Stream.Name)
+		}
+	}
+
+	states := make(map[string]interface{}, len(state))
+	for name, ss := range state {
+		states[name] = ss
+	}
+	cp := airbyte.NewCheckpointer(states, tracker.State)
+	sched := airbyte.StreamScheduler{Workers: s.Workers, Log: tracker.Log}
+	return sched.Run(context.Background(), configuredCat.Streams, func(ctx context.Context, cs airbyte.ConfiguredStream) error {
+		sc, _ := cfg.stream(cs.Stream.Name)
+		return s.read(ctx, sc, cs, cfg.startDate, cp, tracker)
+	})
+}
+
+func (s Source) read(ctx context.Context, sc StreamConfig, cs airbyte.ConfiguredStream, start time.Time,
+	cp *airbyte.Checkpointer, tracker airbyte.MessageTracker) error {
+	files, err := s.files(sc)
+	if err != nil {
+		return err
+	}
+
+	incremental := cs.SyncMode == airbyte.SyncModeIncremental
+	cursor, _ := cp.Get(sc.Name).(StreamState)
+	var todo []file
+	for _, f := range files {
+		if f.modified.Before(start) || incremental && cursor.done(f) {
+			continue
+		}
+		todo = append(todo, f)
+	}
+	if err := tracker.Log(airbyte.LogLevelInfo,
+		fmt.Sprintf("stream %s: reading %d of %d files", sc.Name, len(todo), len(files))); err != nil {
+		return err
+	}
+
+	props := cs.Stream.JSONSchema.Properties
+	for _, f := range todo {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		modified := timestamp(f.modified)
+		err := sc.Format.records(f.path, func(rec map[string]interface{}) error {
+			if sc.Format.Type == FormatCSV {
+				cast(rec, props)
+			}
+			rec[FileURLField] = f.path
+			rec[LastModifiedField] = modified
+			return tracker.Record(rec, cs.Stream.Name, cs.Stream.Namespace)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+
+		if incremental {
+			if err := cp.Set(sc.Name, StreamState{Modified: f.modified, Path: f.path}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// file is a file matching the globs of a stream
+type file struct {
+	path     string
+	modified time.Time
+}
+
+// before orders files by modification time and path, which is the order they are read in
+func (f file) before(o file) bool {
+	if !f.modified.Equal(o.modified) {
+		return f.modified.Before(o.modified)
+	}
+	return f.path < o.path
+}
+
+// files returns the regular files matching the globs of a stream in the order they are read in
+func (s Source) files(sc StreamConfig) ([]file, error) {
+	seen := map[string]bool{}
+	var files []file
+	for _, g := range sc.Globs {
+		if s.Dir != "" && !filepath.IsAbs(g) {
+			g = filepath.Join(s.Dir, g)
+		}
+		matches, err := glob(g)
+		if err != nil {
+			return nil, fmt.Errorf("stream %s: glob %s: %w", sc.Name, g, err)
+		}
+		for _, m := range matches {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			fi, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if fi.Mode().IsRegular() {
+				files = append(files, file{path: m, modified: fi.ModTime()})
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].before(files[j])
+	})
+	return files, nil
+}
+
+func loadConfig(path string) (*Config, error) {
+	var cfg Config
+	if err := airbyte.UnmarshalFromPath(path, &cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Streams) == 0 {
+		return nil, errors.New("no streams configured")
+	}
+	seen := map[string]bool{}
+	for _, sc := range cfg.Streams {
+		if sc.Name == "" {
+			return nil, errors.New("stream without a name")
+		}
+		if seen[sc.Name] {
+			return nil, fmt.Errorf("stream %s is configured more than once", sc.Name)
+		}
+		seen[sc.Name] = true
+		if len(sc.Globs) == 0 {
+			return nil, fmt.Errorf("stream %s: no globs", sc.Name)
+		}
+		for _, g := range sc.Globs {
+			if _, err := filepath.Match(g, ""); err != nil {
+				return nil, fmt.Errorf("stream %s: glob %s: %w", sc.Name, g, err)
+			}
+		}
+		switch sc.Format.Type {
+		case FormatCSV, FormatJSONL, FormatParquet:
+		default:
+			return nil, fmt.Errorf("stream %s: unknown format %q", sc.Name, sc.Format.Type)
+		}
+		if sc.Format.Delimiter != "" && utf8.RuneCountInString(sc.Format.Delimiter) != 1 {
+			return nil, fmt.Errorf("stream %s: delimiter %q isn't a single character", sc.Name, sc.Format.Delimiter)
+		}
+	}
+
+	if cfg.StartDate != "" {
+		t, err := time.Parse(time.RFC3339, cfg.StartDate)
+		if err != nil {
+			return nil, fmt.Errorf("start_date: %w", err)
+		}
+		cfg.startDate = t
+	}
+	return &cfg, nil
+}
+
+func (c *Config) stream(name string) (StreamConfig, bool) {
+	for _, sc := range c.Streams {
+		if sc.Name == name {
+			return sc, true
+		}
+	}
+	return StreamConfig{}, false
+}
@@ -0,0 +1,142 @@
+package filesource
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+func writeFile(t *testing.T, path, content string, modified time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	b := []byte(content)
+	if strings.HasSuffix(path, ".gz") {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(b)
+		gz.Close()
+		b = buf.Bytes()
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir := t.TempDir()
+	jan := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := jan.AddDate(0, 1, 0)
+	writeFile(t, filepath.Join(dir, "orders/2023/01.csv"), "id,amount,paid,note\n1,9.5,true,\n2,10,false,zip 007\n", jan)
+	writeFile(t, filepath.Join(dir, "orders/2023/02.csv.gz"), "id,amount,paid,note\n3,1,TRUE,x\n", feb)
+	writeFile(t, filepath.Join(dir, "orders/notes.txt"), "not an order", feb)
+	writeFile(t, filepath.Join(dir, "events.jsonl"), `{"id": 1, "tags": ["a"], "user": {"id": 2}}`+"\n\n"+`{"id": 1.5}`+"\n", jan)
+
+	src := Source{Dir: dir}
+	config := map[string]interface{}{
+		"streams": []map[string]interface{}{
+			{"name": "orders", "globs": []string{"orders/**/*.csv*"}, "format": map[string]string{"type": "csv"}, "primary_key": []string{"id"}},
+			{"name": "events", "globs": []string{"*.jsonl"}, "format": map[string]string{"type": "jsonl"}},
+		},
+	}
+
+	out, err := airbytetest.Check(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ConnectionStatus != airbytetest.StatusSucceeded {
+		t.Fatalf("expected the check to pass, got %v", out.Logs)
+	}
+
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, events := disc.Catalog.Streams[0].JSONSchema.Properties, disc.Catalog.Streams[1].JSONSchema.Properties
+	for field, typ := range map[airbyte.PropertyName]airbyte.PropType{
+		"id": airbyte.Integer, "amount": airbyte.Number, "paid": airbyte.Boolean, "note": airbyte.String,
+	} {
+		if types := orders[field].Type; len(types) != 2 || types[0] != typ || types[1] != airbyte.Null {
+			t.Errorf("expected orders.%s to be a nullable %s, got %v", field, typ, types)
+		}
+	}
+	if events["id"].Type[0] != airbyte.Number || events["tags"].Items.Type[0] != airbyte.String ||
+		events["user"].Properties["id"].Type[0] != airbyte.Integer {
+		t.Errorf("unexpected events schema %+v", events)
+	}
+	if disc.Catalog.Streams[0].DefaultCursorField[0] != LastModifiedField || orders[FileURLField].Type[0] != airbyte.String {
+		t.Errorf("expected the file fields, got %+v", disc.Catalog.Streams[0])
+	}
+
+	cat := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental)
+	out, err = airbytetest.Read(src, config, cat, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["orders"]) != 3 || len(out.Records["events"]) != 2 {
+		t.Fatalf("expected 3 orders and 2 events, got %d and %d", len(out.Records["orders"]), len(out.Records["events"]))
+	}
+	var order map[string]interface{}
+	if err := out.Records["orders"][0].Unmarshal(&order); err != nil {
+		t.Fatal(err)
+	}
+	if order["amount"] != 9.5 || order["paid"] != true || order["note"] != nil ||
+		order[LastModifiedField] != "2023-01-01T00:00:00Z" || !strings.HasSuffix(order[FileURLField].(string), "01.csv") {
+		t.Errorf("unexpected order %v", order)
+	}
+	var st State
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if !st["orders"].Modified.Equal(feb) || !strings.HasSuffix(st["orders"].Path, "02.csv.gz") {
+		t.Fatalf("expected the orders cursor at the february file, got %+v", st)
+	}
+
+	// only new files are read by the next sync
+	writeFile(t, filepath.Join(dir, "orders/2023/03.csv"), "id,amount,paid,note\n4,2,false,y\n", feb)
+	out, err = airbytetest.Read(src, config, cat, out.States[len(out.States)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["orders"]) != 1 || len(out.Records["events"]) != 0 {
+		t.Fatalf("expected only the new order, got %d orders and %d events", len(out.Records["orders"]), len(out.Records["events"]))
+	}
+
+	// files modified before the start date are skipped by full refreshes as well
+	config["start_date"] = "2023-01-15T00:00:00Z"
+	out, err = airbytetest.Read(src, config, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["orders"]) != 2 || len(out.Records["events"]) != 0 || len(out.States) != 0 {
+		t.Fatalf("expected 2 orders without a state, got %d orders, %d events and %d states",
+			len(out.Records["orders"]), len(out.Records["events"]), len(out.States))
+	}
+}
+
+func TestCheckWithoutFiles(t *testing.T) {
+	config := map[string]interface{}{
+		"streams": []map[string]interface{}{
+			{"name": "orders", "globs": []string{"*.csv"}, "format": map[string]string{"type": "csv"}},
+		},
+	}
+	out, err := airbytetest.Check(Source{Dir: t.TempDir()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ConnectionStatus != airbytetest.StatusFailed {
+		t.Fatal("expected the check to fail without files")
+	}
+}
@@ -0,0 +1,98 @@
+// Package auth authenticates the requests of http sources
+// Authenticators decorate outgoing requests, either one by one or through a Transport, and Credentials
+// are the matching config values of a connector, along with their spec
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Authenticator adds credentials to an outgoing request
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// APIKey sends an api key in a header or query param
+// Prefer a header, query strings end up in access logs and error messages
+type APIKey struct {
+	Key string
+	// Header is the header the key is sent in, e.g. "X-API-Key"
+	Header string
+	// Param is the query param the key is sent in if Header is empty
+	Param string
+	// Prefix is put in front of the key in the header, e.g. "Token "
+	Prefix string
+}
+
+func (a APIKey) Authenticate(req *http.Request) error {
+	switch {
+	case a.Header != "":
+		req.Header.Set(a.Header, a.Prefix+a.Key)
+	case a.Param != "":
+		q := req.URL.Query()
+		q.Set(a.Param, a.Key)
+		req.URL.RawQuery = q.Encode()
+	default:
+		return errors.New("auth: api key needs a header or a param")
+	}
+	return nil
+}
+
+// Bearer sends a static token in the Authorization header
+type Bearer struct {
+	Token string
+}
+
+func (b Bearer) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+	return nil
+}
+
+// Basic sends a username and password with http basic auth
+type Basic struct {
+	Username string
+	Password string
+}
+
+func (b Basic) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(b.Username, b.Password)
+	return nil
+}
+
+// Transport authenticates every request before handing it to Base
+type Transport struct {
+	Auth Authenticator
+	// Base sends the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request it was given
+	r := req.Clone(req.Context())
+	if err := t.Auth.Authenticate(r); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
+
+// Client returns a copy of base which authenticates every request with a, a nil base copies http.DefaultClient
+func Client(a Authenticator, base *http.Client) *http.Client {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	c := *base
+	c.Transport = &Transport{
+		Auth: a,
+		Base: base.Transport,
+	}
+	return &c
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthenticators(t *testing.T) {
+	tests := []struct {
+		name  string
+		auth  Authenticator
+		check func(r *http.Request) bool
+	}{
+		{"api key header", APIKey{Key: "k", Header: "Authorization", Prefix: "Token "}, func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Token k" && r.URL.RawQuery == "a=1"
+		}},
+		{"api key param", APIKey{Key: "k", Param: "api_key"}, func(r *http.Request) bool {
+			return r.URL.Query().Get("api_key") == "k" && r.URL.Query().Get("a") == "1"
+		}},
+		{"bearer", Bearer{Token: "t"}, func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer t"
+		}},
+		{"basic", Basic{Username: "u", Password: "p"}, func(r *http.Request) bool {
+			u, p, ok := r.BasicAuth()
+			return ok && u == "u" && p == "p"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ok bool
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ok = tt.check(r)
+			}))
+			defer api.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, api.URL+"/?a=1", nil)
+			resp, err := Client(tt.auth, nil).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if !ok {
+				t.Error("request was not authenticated")
+			}
+			if req.Header.Get("Authorization") != "" || req.URL.Query().Get("api_key") != "" {
+				t.Error("the transport modified the original request")
+			}
+		})
+	}
+
+	if err := (APIKey{Key: "k"}).Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("expected an api key without header or param to fail")
+	}
+}
+
+// tokenServer hands out numbered tokens which expire after expiresIn seconds
+func tokenServer(t *testing.T, expiresIn int, rotate bool) (*httptest.Server, *int32) {
+	var issued int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client"}`)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		body := map[string]interface{}{
+			"access_token": fmt.Sprintf("access-%d", n),
+			"expires_in":   fmt.Sprint(expiresIn),
+		}
+		switch r.Form.Get("grant_type") {
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != fmt.Sprintf("refresh-%d", n-1) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error": "invalid_grant"}`)
+				return
+			}
+			if rotate {
+				body["refresh_token"] = fmt.Sprintf("refresh-%d", n)
+			}
+		case "client_credentials":
+			if r.Form.Get("scope") != "read write" {
+				t.Errorf("unexpected scope %q", r.Form.Get("scope"))
+			}
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	return srv, &issued
+}
+
+func TestClientCredentials(t *testing.T) {
+	srv, issued := tokenServer(t, 3600, false)
+	defer srv.Close()
+
+	cc := &ClientCredentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret", Scopes: []string{"read", "write"}}
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := cc.Authenticate(req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("Authorization") != "Bearer access-1" {
+			t.Fatalf("unexpected authorization %q", req.Header.Get("Authorization"))
+		}
+	}
+	if *issued != 1 {
+		t.Errorf("expected the token to be reused, %d were issued", *issued)
+	}
+
+	// a token about to expire is refreshed ahead of time
+	cc.token.Expiry = time.Now().Add(ExpiryLeeway / 2)
+	if err := cc.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if *issued != 2 {
+		t.Errorf("expected a token close to its expiry to be refreshed")
+	}
+
+	bad := &ClientCredentials{TokenURL: srv.URL, ClientSecret: "wrong"}
+	if err := bad.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("expected a rejected token request to fail")
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	srv, _ := tokenServer(t, 0, true)
+	defer srv.Close()
+
+	var persisted []Credentials
+	creds, err := ParseCredentials([]byte(`{"auth_type": "oauth2_refresh_token", "client_id": "id", "client_secret": "secret", "refresh_token": "refresh-0"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := creds.Authenticator(Options{
+		TokenURL: srv.URL,
+		OnRotate: func(c Credentials) error {
+			persisted = append(persisted, c)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tokens expiring right away are refreshed on every request, rotating the refresh token each time
+	for i := 1; i <= 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := a.Authenticate(req); err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("Bearer access-%d", i); req.Header.Get("Authorization") != want {
+			t.Fatalf("expected %q, got %q", want, req.Header.Get("Authorization"))
+		}
+	}
+
+	if len(persisted) != 2 {
+		t.Fatalf("expected 2 rotations, got %d", len(persisted))
+	}
+	last := persisted[1].(RefreshTokenConfig)
+	if last.RefreshToken != "refresh-2" || last.AccessToken != "access-2" || last.TokenExpiry == "" || last.AuthType != string(MethodRefreshToken) {
+		t.Errorf("unexpected rotated credentials %+v", last)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	spec, err := Spec("Authentication", MethodRefreshToken, MethodAPIKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Title != "Authentication" || len(spec.OneOf) != 2 {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+	if spec.OneOf[0].Title != "OAuth2" || spec.OneOf[1].Title != "API Key" {
+		t.Errorf("expected the methods in order, got %q and %q", spec.OneOf[0].Title, spec.OneOf[1].Title)
+	}
+	if spec.OneOf[1].Properties["auth_type"].Const != "api_key" || !spec.OneOf[1].Properties["api_key"].IsSecret {
+		t.Errorf("unexpected api key variant %+v", spec.OneOf[1])
+	}
+
+	if _, err := Spec("Authentication", "password"); err == nil {
+		t.Error("expected an unknown method to fail")
+	}
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+// Credentials is the auth part of a connector config, the user picks one of the methods in the airbyte UI
+// It's registered with schema.RegisterOneOf, so a config struct field of type Credentials gets a oneOf spec
+// with every method and airbyte.UnmarshalFromPath decodes it into the chosen one
+// Use Spec to offer only some of the methods
+type Credentials interface {
+	// Authenticator builds the authenticator of the credentials, opts holds what the connector knows about its api
+	Authenticator(opts Options) (Authenticator, error)
+}
+
+// Options are the connector specific parts of authenticating, which the user doesn't configure
+type Options struct {
+	// APIKeyHeader is the header api keys are sent in, e.g. "X-API-Key"
+	APIKeyHeader string
+	// APIKeyParam is the query param api keys are sent in if APIKeyHeader is empty
+	APIKeyParam string
+	// APIKeyPrefix is put in front of api keys sent in a header, e.g. "Token "
+	APIKeyPrefix string
+	// TokenURL is the oauth2 token endpoint
+	TokenURL string
+	// Scopes are requested with oauth2 client credentials
+	Scopes []string
+	// Client sends the oauth2 token requests, http.DefaultClient if nil
+	Client *http.Client
+	// OnRotate is called with the updated credentials after oauth2 tokens were refreshed
+	// Emit them as a config update, e.g. through MessageTracker.Config, otherwise a rotated refresh token is lost
+	OnRotate func(Credentials) error
+}
+
+// Method is the value of the auth_type discriminator of a Credentials variant
+type Method string
+
+const (
+	MethodAPIKey            Method = "api_key"
+	MethodBearer            Method = "bearer"
+	MethodBasic             Method = "basic"
+	MethodClientCredentials Method = "oauth2_client_credentials"
+	MethodRefreshToken      Method = "oauth2_refresh_token"
+)
+
+// discriminator is the property telling the methods apart
+const discriminator = "auth_type"
+
+type APIKeyConfig struct {
+	AuthType string `json:"auth_type" const:"api_key" airbyte_hidden:"true"`
+	APIKey   string `json:"api_key" title:"API Key" airbyte_secret:"true" order:"0"`
+}
+
+type BearerConfig struct {
+	AuthType string `json:"auth_type" const:"bearer" airbyte_hidden:"true"`
+	Token    string `json:"token" title:"Access Token" airbyte_secret:"true" order:"0"`
+}
+
+type BasicConfig struct {
+	AuthType string `json:"auth_type" const:"basic" airbyte_hidden:"true"`
+	Username string `json:"username" title:"Username" order:"0"`
+	Password string `json:"password" title:"Password" airbyte_secret:"true" order:"1"`
+}
+
+type ClientCredentialsConfig struct {
+	AuthType     string `json:"auth_type" const:"oauth2_client_credentials" airbyte_hidden:"true"`
+	ClientID     string `json:"client_id" title:"Client ID" order:"0"`
+	ClientSecret string `json:"client_secret" title:"Client Secret" airbyte_secret:"true" order:"1"`
+}
+
+type RefreshTokenConfig struct {
+	AuthType     string `json:"auth_type" const:"oauth2_refresh_token" airbyte_hidden:"true"`
+	ClientID     string `json:"client_id" title:"Client ID" order:"0"`
+	ClientSecret string `json:"client_secret" title:"Client Secret" airbyte_secret:"true" order:"1"`
+	RefreshToken string `json:"refresh_token" title:"Refresh Token" airbyte_secret:"true" order:"2"`
+	// AccessToken and TokenExpiry are filled in by config updates, so the next sync reuses the access token
+	AccessToken string `json:"access_token,omitempty" title:"Access Token" airbyte_secret:"true" airbyte_hidden:"true"`
+	TokenExpiry string `json:"token_expiry,omitempty" title:"Token Expiry" format:"date-time" airbyte_hidden:"true"`
+}
+
+func (c APIKeyConfig) Authenticator(opts Options) (Authenticator, error) {
+	return APIKey{
+		Key:    c.APIKey,
+		Header: opts.APIKeyHeader,
+		Param:  opts.APIKeyParam,
+		Prefix: opts.APIKeyPrefix,
+	}, nil
+}
+
+func (c BearerConfig) Authenticator(opts Options) (Authenticator, error) {
+	return Bearer{Token: c.Token}, nil
+}
+
+func (c BasicConfig) Authenticator(opts Options) (Authenticator, error) {
+	return Basic{Username: c.Username, Password: c.Password}, nil
+}
+
+func (c ClientCredentialsConfig) Authenticator(opts Options) (Authenticator, error) {
+	if opts.TokenURL == "" {
+		return nil, errors.New("auth: oauth2 client credentials need a token url")
+	}
+	return &ClientCredentials{
+		TokenURL:     opts.TokenURL,
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Scopes:       opts.Scopes,
+		Client:       opts.Client,
+	}, nil
+}
+
+func (c RefreshTokenConfig) Authenticator(opts Options) (Authenticator, error) {
+	if opts.TokenURL == "" {
+		return nil, errors.New("auth: oauth2 refresh token needs a token url")
+	}
+
+	t := Token{
+		AccessToken:  c.AccessToken,
+		RefreshToken: c.RefreshToken,
+	}
+	if c.TokenExpiry != "" {
+		exp, err := time.Parse(time.RFC3339, c.TokenExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("auth: token_expiry: %w", err)
+		}
+		t.Expiry = exp
+	} else {
+		// without an expiry there is no telling whether the access token still works
+		t.AccessToken = ""
+	}
+
+	return &RefreshToken{
+		TokenURL:     opts.TokenURL,
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Token:        t,
+		Client:       opts.Client,
+		OnRotate: func(t Token) error {
+			if opts.OnRotate == nil {
+				return nil
+			}
+			rotated := c
+			rotated.RefreshToken = t.RefreshToken
+			rotated.AccessToken = t.AccessToken
+			rotated.TokenExpiry = ""
+			if !t.Expiry.IsZero() {
+				rotated.TokenExpiry = t.Expiry.UTC().Format(time.RFC3339)
+			}
+			return opts.OnRotate(rotated)
+		},
+	}, nil
+}
+
+var variants = []struct {
+	method  Method
+	variant schema.Variant
+}{
+	{MethodAPIKey, schema.Variant{Title: "API Key", Type: reflect.TypeOf(APIKeyConfig{})}},
+	{MethodBearer, schema.Variant{Title: "Access Token", Type: reflect.TypeOf(BearerConfig{})}},
+	{MethodBasic, schema.Variant{Title: "Username and Password", Type: reflect.TypeOf(BasicConfig{})}},
+	{MethodClientCredentials, schema.Variant{Title: "OAuth2 Client Credentials", Type: reflect.TypeOf(ClientCredentialsConfig{})}},
+	{MethodRefreshToken, schema.Variant{Title: "OAuth2", Type: reflect.TypeOf(RefreshTokenConfig{})}},
+}
+
+var credentialsType = reflect.TypeOf((*Credentials)(nil)).Elem()
+
+func init() {
+	var all []schema.Variant
+	for _, v := range variants {
+		all = append(all, v.variant)
+	}
+	if err := schema.RegisterOneOf(credentialsType, discriminator, all...); err != nil {
+		panic(err)
+	}
+}
+
+// ParseCredentials decodes the json of a credentials config into the chosen method
+func ParseCredentials(b []byte) (Credentials, error) {
+	var c Credentials
+	if err := schema.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("auth: credentials: %w", err)
+	}
+	if c == nil {
+		return nil, errors.New("auth: credentials are missing")
+	}
+	return c, nil
+}
+
+// Spec returns the spec of a credentials property offering the given methods in order, e.g.
+//
+//	creds, err := auth.Spec("Authentication", auth.MethodRefreshToken, auth.MethodBearer)
+//	spec.ConnectionSpecification.Properties.Properties["credentials"] = creds
+func Spec(title string, methods ...Method) (airbyte.PropertySpec, error) {
+	if len(methods) == 0 {
+		return airbyte.PropertySpec{}, errors.New("auth: spec needs at least one method")
+	}
+
+	s := &schema.Schema{
+		Type:  []string{schema.TypeObject},
+		Title: title,
+	}
+	for _, m := range methods {
+		found := false
+		for _, v := range variants {
+			if v.method != m {
+				continue
+			}
+			vs, err := schema.GenerateWithOptions(v.variant.Type, schema.Options{Nullability: schema.NullableExplicit}, nil)
+			if err != nil {
+				return airbyte.PropertySpec{}, err
+			}
+			vs.Title = v.variant.Title
+			s.OneOf = append(s.OneOf, vs)
+			found = true
+		}
+		if !found {
+			return airbyte.PropertySpec{}, fmt.Errorf("auth: unknown method %q", m)
+		}
+	}
+
+	return airbyte.PropertySpecFromSchema(s)
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExpiryLeeway is how long before its expiry a token is refreshed, so it doesn't expire on its way to the api
+const ExpiryLeeway = time.Minute
+
+// Token is an oauth2 token
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	// Expiry is when the access token expires, the zero time means it doesn't
+	Expiry time.Time
+}
+
+// valid reports whether the access token can still be used at now
+func (t Token) valid(now time.Time) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(ExpiryLeeway).Before(t.Expiry))
+}
+
+// ClientCredentials authenticates with an access token of the oauth2 client credentials grant
+// The token is requested on first use and requested again shortly before it expires
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Client sends the token requests, http.DefaultClient if nil
+	Client *http.Client
+
+	mu    sync.Mutex
+	token Token
+}
+
+func (c *ClientCredentials) Authenticate(req *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.token.valid(time.Now()) {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {c.ClientID},
+			"client_secret": {c.ClientSecret},
+		}
+		if len(c.Scopes) > 0 {
+			form.Set("scope", strings.Join(c.Scopes, " "))
+		}
+		t, err := fetchToken(c.Client, c.TokenURL, form)
+		if err != nil {
+			return err
+		}
+		c.token = t
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
+	return nil
+}
+
+// RefreshToken authenticates with an access token of the oauth2 refresh token grant
+// The access token is refreshed when it's missing or about to expire. Many apis rotate the refresh token
+// on every refresh and revoke the old one, so OnRotate has to persist the new token
+type RefreshToken struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Token holds the refresh token and, if there is one, the last access token
+	Token Token
+	// Client sends the token requests, http.DefaultClient if nil
+	Client *http.Client
+	// OnRotate is called with the new token after every refresh
+	OnRotate func(Token) error
+
+	mu sync.Mutex
+}
+
+func (r *RefreshToken) Authenticate(req *http.Request) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.Token.valid(time.Now()) {
+		t, err := fetchToken(r.Client, r.TokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {r.Token.RefreshToken},
+			"client_id":     {r.ClientID},
+			"client_secret": {r.ClientSecret},
+		})
+		if err != nil {
+			return err
+		}
+		if t.RefreshToken == "" {
+			// the refresh token didn't rotate
+			t.RefreshToken = r.Token.RefreshToken
+		}
+		r.Token = t
+
+		if r.OnRotate != nil {
+			if err := r.OnRotate(t); err != nil {
+				return fmt.Errorf("auth: persist rotated token: %w", err)
+			}
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+r.Token.AccessToken)
+	return nil
+}
+
+// fetchToken requests a token from an oauth2 token endpoint, the client authenticates in the form body
+func fetchToken(client *http.Client, tokenURL string, form url.Values) (Token, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("auth: token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Token{}, fmt.Errorf("auth: token request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Token{}, fmt.Errorf("auth: token request: %s: %.1024s", resp.Status, b)
+	}
+
+	var body struct {
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+		Error        string      `json:"error"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return Token{}, fmt.Errorf("auth: token response: %w", err)
+	}
+	if body.Error != "" || body.AccessToken == "" {
+		return Token{}, fmt.Errorf("auth: token response without access token: %s", orDefault(body.Error, "no error given"))
+	}
+
+	t := Token{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+	}
+	if body.ExpiresIn != "" {
+		// some apis send expires_in as a string
+		secs, err := strconv.ParseFloat(string(body.ExpiresIn), 64)
+		if err != nil {
+			return Token{}, fmt.Errorf("auth: token response expires_in %q: %w", body.ExpiresIn, err)
+		}
+		t.Expiry = time.Now().Add(time.Duration(secs * float64(time.Second)))
+	}
+	return t, nil
+}
+
+func orDefault(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
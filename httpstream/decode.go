@@ -0,0 +1,495 @@
+package httpstream
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decoder decodes the records out of a response body
+type Decoder interface {
+	// Decode hands every record of body to fn as it's read
+	// rest is what's left of the body without the records, paginators select their tokens out of it
+	Decode(body io.Reader, fn func(record interface{}) error) (rest interface{}, err error)
+}
+
+// selectorDecoder decodes the whole json body and selects the records with a RecordSelector
+type selectorDecoder struct {
+	selector string
+}
+
+func (s selectorDecoder) Decode(body io.Reader, fn func(record interface{}) error) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	selected, err := selectPath(v, s.selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records(selected) {
+		if err := fn(r); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// JSONDecoder streams the records out of a json body, only a single record is held in memory at a time
+// so huge arrays can be read with flat memory
+type JSONDecoder struct {
+	// RecordPath is the path to the records, e.g. "data.items[*]"
+	// Segments are separated by dots and "[*]" selects every element of an array or value of an object,
+	// e.g. "[*]" for a body which is an array of records. An array at the end of the path is selected like "[*]"
+	// The empty path selects the whole body
+	RecordPath string
+}
+
+// jsonStep is a segment of a RecordPath, either an object key or [*]
+type jsonStep struct {
+	key  string
+	each bool
+}
+
+func (j JSONDecoder) Decode(body io.Reader, fn func(record interface{}) error) (interface{}, error) {
+	steps, err := parseRecordPath(j.RecordPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return walkJSON(dec, tok, steps, fn)
+}
+
+// parseRecordPath splits a RecordPath like "$.data.items[*]" into its steps
+func parseRecordPath(path string) ([]jsonStep, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	var steps []jsonStep
+	for path != "" {
+		if strings.HasPrefix(path, "[*]") {
+			steps = append(steps, jsonStep{each: true})
+			path = strings.TrimPrefix(path[3:], ".")
+			continue
+		}
+		end := strings.IndexAny(path, ".[")
+		if end == -1 {
+			end = len(path)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("record path %q: only [*] is supported in brackets", path)
+		}
+		steps = append(steps, jsonStep{key: path[:end]})
+		path = strings.TrimPrefix(path[end:], ".")
+	}
+	return steps, nil
+}
+
+// walkJSON follows steps into the value starting with tok and hands the records at their end to fn
+// It returns the value without the records, nil if the whole value was records
+func walkJSON(dec *json.Decoder, tok json.Token, steps []jsonStep, fn func(record interface{}) error) (interface{}, error) {
+	if len(steps) == 0 {
+		if tok != json.Delim('[') {
+			v, err := buildJSON(dec, tok)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			return nil, fn(v)
+		}
+		steps = []jsonStep{{each: true}}
+	}
+
+	step := steps[0]
+	switch {
+	case step.each && (tok == json.Delim('[') || tok == json.Delim('{')):
+		for dec.More() {
+			if tok == json.Delim('{') {
+				// the keys of an object are skipped, its values are the elements
+				if _, err := dec.Token(); err != nil {
+					return nil, err
+				}
+			}
+			elem, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			if len(steps) == 1 {
+				v, err := buildJSON(dec, elem)
+				if err != nil {
+					return nil, err
+				}
+				if err := fn(v); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, err := walkJSON(dec, elem, steps[1:], fn); err != nil {
+				return nil, err
+			}
+		}
+		_, err := dec.Token()
+		return nil, err
+
+	case !step.each && tok == json.Delim('{'):
+		rest := map[string]interface{}{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := k.(string)
+			vt, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var v interface{}
+			if key == step.key {
+				v, err = walkJSON(dec, vt, steps[1:], fn)
+			} else {
+				v, err = buildJSON(dec, vt)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if key != step.key || v != nil {
+				rest[key] = v
+			}
+		}
+		_, err := dec.Token()
+		return rest, err
+	}
+
+	// the path doesn't go on in this value, so there are no records in it
+	return buildJSON(dec, tok)
+}
+
+// buildJSON decodes the value starting with tok
+func buildJSON(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	switch tok {
+	case json.Delim('{'):
+		m := map[string]interface{}{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			vt, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := k.(string)
+			if m[key], err = buildJSON(dec, vt); err != nil {
+				return nil, err
+			}
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			vt, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := buildJSON(dec, vt)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err := dec.Token()
+		return a, err
+	}
+	return tok, nil
+}
+
+// JSONLinesDecoder decodes a body with a json record per line, e.g. an export or bulk endpoint
+type JSONLinesDecoder struct{}
+
+func (JSONLinesDecoder) Decode(body io.Reader, fn func(record interface{}) error) (interface{}, error) {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	for line := 1; ; line++ {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+		if err := fn(v); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CSVDecoder decodes a csv body into a record per row, keyed by the column names
+// Values are strings, tag numeric fields of the RecordType with `json:",string"` to decode them into numbers
+type CSVDecoder struct {
+	// Comma is the field delimiter, ',' if 0
+	Comma rune
+	// Header names the columns of a body without a header row, the first row is the header if nil
+	Header []string
+	// Columns renames columns to record fields, e.g. {"Order ID": "order_id"}
+	// Columns which aren't renamed keep their name
+	Columns map[string]string
+}
+
+func (c CSVDecoder) Decode(body io.Reader, fn func(record interface{}) error) (interface{}, error) {
+	r := csv.NewReader(body)
+	if c.Comma != 0 {
+		r.Comma = c.Comma
+	}
+
+	header := c.Header
+	if header == nil {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the header row of files written by excel starts with a byte order mark
+		header = append([]string(nil), row...)
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		}
+	}
+	fields := make([]string, len(header))
+	for i, h := range header {
+		fields[i] = h
+		if f, ok := c.Columns[h]; ok {
+			fields[i] = f
+		}
+	}
+	r.FieldsPerRecord = len(fields)
+	r.ReuseRecord = true
+
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec := make(map[string]interface{}, len(row))
+		for i, v := range row {
+			rec[fields[i]] = v
+		}
+		if err := fn(rec); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// XMLDecoder decodes the elements at a path of an xml body into records, one element at a time
+// Child elements become fields, repeated children become arrays, attributes are prefixed with "@"
+// and the text of elements with attributes or children is kept as "#text"
+type XMLDecoder struct {
+	// ElementPath is the slash separated path from the root to the record elements, e.g. "rss/channel/item"
+	ElementPath string
+}
+
+func (x XMLDecoder) Decode(body io.Reader, fn func(record interface{}) error) (interface{}, error) {
+	path := strings.Split(strings.Trim(x.ElementPath, "/"), "/")
+	if x.ElementPath == "" {
+		return nil, errors.New("xml decoder: element path is required")
+	}
+
+	dec := xml.NewDecoder(body)
+	var stack []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			if !matchPath(stack, path) {
+				continue
+			}
+			v, err := buildXML(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			// buildXML consumed the end element
+			stack = stack[:len(stack)-1]
+			if err := fn(v); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func matchPath(stack []string, path []string) bool {
+	if len(stack) != len(path) {
+		return false
+	}
+	for i := range stack {
+		if stack[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// buildXML decodes the element of start up to and including its end element
+func buildXML(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := map[string]interface{}{}
+	for _, a := range start.Attr {
+		m["@"+a.Name.Local] = a.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := buildXML(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch prev := m[name].(type) {
+			case nil:
+				m[name] = child
+			case []interface{}:
+				m[name] = append(prev, child)
+			default:
+				m[name] = []interface{}{prev, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m["#text"] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// Decompressor wraps a compressed body in a reader which decompresses it
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[string]Decompressor{
+		"gzip":    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		"x-gzip":  func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		"deflate": inflate,
+		"zstd":    unzstd,
+	}
+)
+
+// RegisterDecompressor decompresses responses with the Content-Encoding encoding, gzip, deflate and zstd are
+// built in
+func RegisterDecompressor(encoding string, d Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	decompressors[strings.ToLower(encoding)] = d
+}
+
+// acceptEncoding is the Accept-Encoding header of requests, every registered encoding is accepted
+func acceptEncoding() string {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+	var encodings []string
+	for e := range decompressors {
+		if !strings.HasPrefix(e, "x-") {
+			encodings = append(encodings, e)
+		}
+	}
+	sort.Strings(encodings)
+	return strings.Join(encodings, ", ")
+}
+
+// unzstd decompresses a zstd body on the goroutine reading it
+func unzstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// decompress wraps body in the decompressors of the Content-Encoding header, encodings are applied in order
+// so they are removed in reverse
+func decompress(body io.ReadCloser, contentEncoding string) (io.ReadCloser, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		e := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if e == "" || e == "identity" {
+			continue
+		}
+		decompressorsMu.RLock()
+		d, ok := decompressors[e]
+		decompressorsMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unsupported content encoding %q", e)
+		}
+		r, err := d(body)
+		if err != nil {
+			return nil, fmt.Errorf("content encoding %s: %w", e, err)
+		}
+		body = r
+	}
+	return body, nil
+}
+
+// inflate decompresses deflate bodies, which should be zlib streams but are raw deflate streams on some servers
+func inflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case len(head) == 0:
+		return ioutil.NopCloser(br), nil
+	case len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0:
+		// a zlib header uses the deflate method and is a multiple of 31
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,220 @@
+package httpstream
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+	"github.com/klauspost/compress/zstd"
+)
+
+func decodeAll(t *testing.T, d Decoder, body string) ([]interface{}, interface{}) {
+	t.Helper()
+	var recs []interface{}
+	rest, err := d.Decode(strings.NewReader(body), func(r interface{}) error {
+		recs = append(recs, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recs, rest
+}
+
+func TestJSONDecoder(t *testing.T) {
+	body := `{"data": {"items": [{"id": 1}, {"id": 2}], "total": 2}, "meta": {"next": "abc"}}`
+	for path, want := range map[string][]interface{}{
+		"data.items[*]":    {map[string]interface{}{"id": json.Number("1")}, map[string]interface{}{"id": json.Number("2")}},
+		"$.data.items":     {map[string]interface{}{"id": json.Number("1")}, map[string]interface{}{"id": json.Number("2")}},
+		"data.items[*].id": {json.Number("1"), json.Number("2")},
+		"data[*]":          {[]interface{}{map[string]interface{}{"id": json.Number("1")}, map[string]interface{}{"id": json.Number("2")}}, json.Number("2")},
+		"missing":          nil,
+	} {
+		recs, rest := decodeAll(t, JSONDecoder{RecordPath: path}, body)
+		if !reflect.DeepEqual(recs, want) {
+			t.Errorf("%s: expected %v, got %v", path, want, recs)
+		}
+		if next, _, _ := selectString(rest, "meta.next"); next != "abc" {
+			t.Errorf("%s: expected the rest of the body to keep meta.next, got %v", path, rest)
+		}
+	}
+
+	// the records are left out of the rest
+	_, rest := decodeAll(t, JSONDecoder{RecordPath: "data.items"}, body)
+	if want := map[string]interface{}{"data": map[string]interface{}{"total": json.Number("2")},
+		"meta": map[string]interface{}{"next": "abc"}}; !reflect.DeepEqual(rest, want) {
+		t.Errorf("expected rest %v, got %v", want, rest)
+	}
+
+	recs, rest := decodeAll(t, JSONDecoder{RecordPath: "[*]"}, `[{"id": 1}, {"id": 2}]`)
+	if len(recs) != 2 || rest != nil {
+		t.Errorf("expected 2 records of a top level array, got %v and %v", recs, rest)
+	}
+	if _, err := (JSONDecoder{RecordPath: "items[0]"}).Decode(strings.NewReader(`{}`), nil); err == nil {
+		t.Error("expected an error for an index")
+	}
+}
+
+// countingReader serves a json array of n records without ever holding it in memory
+type countingReader struct {
+	n, i int
+	buf  bytes.Buffer
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	for c.buf.Len() < len(p) && c.i <= c.n {
+		switch {
+		case c.i == 0:
+			c.buf.WriteString(`{"items": [`)
+		case c.i == c.n:
+			c.buf.WriteString(`{"id": "last"}]}`)
+		default:
+			c.buf.WriteString(`{"id": "` + strings.Repeat("x", 100) + `"},`)
+		}
+		c.i++
+	}
+	if c.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.buf.Read(p)
+}
+
+func TestJSONDecoderStreams(t *testing.T) {
+	n := 0
+	var last interface{}
+	_, err := JSONDecoder{RecordPath: "items[*]"}.Decode(&countingReader{n: 100000}, func(r interface{}) error {
+		n++
+		last = r
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 100000 || last.(map[string]interface{})["id"] != "last" {
+		t.Fatalf("expected 100000 records ending in the last one, got %d and %v", n, last)
+	}
+}
+
+func TestJSONLinesDecoder(t *testing.T) {
+	recs, _ := decodeAll(t, JSONLinesDecoder{}, "{\"id\": 1}\n\n{\"id\": 2}\n")
+	if len(recs) != 2 || recs[1].(map[string]interface{})["id"] != json.Number("2") {
+		t.Fatalf("expected 2 records, got %v", recs)
+	}
+	_, err := JSONLinesDecoder{}.Decode(strings.NewReader("{\"id\": 1}\n{\"id\":"), func(interface{}) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Fatalf("expected an error for record 2, got %v", err)
+	}
+}
+
+func TestCSVDecoder(t *testing.T) {
+	recs, _ := decodeAll(t, CSVDecoder{Columns: map[string]string{"Order ID": "order_id"}},
+		"\ufeffOrder ID,amount\n1,\"1,000\"\n2,20\n")
+	want := []interface{}{
+		map[string]interface{}{"order_id": "1", "amount": "1,000"},
+		map[string]interface{}{"order_id": "2", "amount": "20"},
+	}
+	if !reflect.DeepEqual(recs, want) {
+		t.Fatalf("expected %v, got %v", want, recs)
+	}
+
+	recs, _ = decodeAll(t, CSVDecoder{Comma: ';', Header: []string{"id", "name"}}, "1;a\n2;b\n")
+	if len(recs) != 2 || recs[1].(map[string]interface{})["name"] != "b" {
+		t.Fatalf("expected 2 records without a header row, got %v", recs)
+	}
+}
+
+func TestXMLDecoder(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<rss><channel><title>feed</title>
+	<item id="1"><title>first</title><tag>a</tag><tag>b</tag></item>
+	<item id="2"><title lang="en">second</title></item>
+</channel></rss>`
+	recs, _ := decodeAll(t, XMLDecoder{ElementPath: "rss/channel/item"}, body)
+	want := []interface{}{
+		map[string]interface{}{"@id": "1", "title": "first", "tag": []interface{}{"a", "b"}},
+		map[string]interface{}{"@id": "2", "title": map[string]interface{}{"@lang": "en", "#text": "second"}},
+	}
+	if !reflect.DeepEqual(recs, want) {
+		t.Fatalf("expected %v, got %v", want, recs)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	body := `{"items": [{"id": 1}]}`
+	var gz, zl, raw, zs bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(body))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(body))
+	zw.Close()
+	fw, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	fw.Write([]byte(body))
+	fw.Close()
+	sw, _ := zstd.NewWriter(&zs)
+	sw.Write([]byte(body))
+	sw.Close()
+
+	for encoding, b := range map[string][]byte{"gzip": gz.Bytes(), "deflate": zl.Bytes(), "Deflate": raw.Bytes(), "zstd": zs.Bytes(), "": []byte(body)} {
+		r, err := decompress(ioutil.NopCloser(bytes.NewReader(b)), encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil || string(got) != body {
+			t.Errorf("%s: expected %s, got %s %v", encoding, body, got, err)
+		}
+	}
+	if _, err := decompress(ioutil.NopCloser(strings.NewReader(body)), "br"); err == nil {
+		t.Error("expected an error for an unsupported encoding")
+	}
+}
+
+func TestDecoderRead(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("expected gzip to be accepted, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		defer gw.Close()
+		if r.URL.Query().Get("cursor") == "" {
+			gw.Write([]byte(`{"data": {"items": [{"id": 1}, {"id": 2}]}, "meta": {"next": "p2"}}`))
+			return
+		}
+		gw.Write([]byte(`{"data": {"items": [{"id": 3}]}, "meta": {}}`))
+	}))
+	defer api.Close()
+
+	src := Source{
+		BaseURL: api.URL,
+		Streams: []Stream{{
+			Name:      "items",
+			Path:      "/items",
+			Decoder:   JSONDecoder{RecordPath: "data.items[*]"},
+			Paginator: CursorPaginator{CursorSelector: "meta.next"},
+		}},
+	}
+	disc, err := airbytetest.Discover(src, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := airbytetest.Read(src, map[string]string{}, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["items"]) != 3 {
+		t.Fatalf("expected 3 records over 2 pages, got %d", len(out.Records["items"]))
+	}
+}
@@ -0,0 +1,80 @@
+package httpstream_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+	"github.com/bitstrapped/airbyte/httpstream"
+)
+
+type User struct {
+	UserID int64  `json:"userid"`
+	Name   string `json:"name"`
+}
+
+func ExampleSource() {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apiKey") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/users":
+			fmt.Fprint(w, `{"data": [{"userid": 1, "name": "ada", "email": "ada@example.com"}, {"userid": 2, "name": "alan"}]}`)
+		case "/payments":
+			fmt.Fprint(w, `[{"userid": 1, "paymentAmount": 20}]`)
+		}
+	}))
+	defer api.Close()
+
+	params := map[string]string{"apiKey": "{{ .config.apiKey }}"}
+	src := httpstream.Source{
+		BaseURL: api.URL,
+		Streams: []httpstream.Stream{
+			{
+				Name:           "users",
+				Path:           "/users",
+				Params:         params,
+				RecordSelector: "data",
+				RecordType:     User{},
+				PrimaryKey:     [][]string{{"userid"}},
+			},
+			{
+				// without a RecordType, records are emitted as they are
+				Name:   "payments",
+				Path:   "/payments",
+				Params: params,
+			},
+		},
+	}
+
+	config := map[string]string{"apiKey": "secret"}
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := airbytetest.Read(src, config, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, stream := range []string{"users", "payments"} {
+		for _, r := range out.Records[stream] {
+			fmt.Println(stream, string(r.Data))
+		}
+	}
+
+	check, err := airbytetest.Check(src, map[string]string{"apiKey": "wrong"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(check.ConnectionStatus)
+	// Output:
+	// users {"userid":1,"name":"ada"}
+	// users {"userid":2,"name":"alan"}
+	// payments {"paymentAmount":20,"userid":1}
+	// FAILED
+}
@@ -0,0 +1,234 @@
+package httpstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// CursorUnix is a CursorFormat for unix timestamps in seconds
+	CursorUnix = "unix"
+	// CursorUnixMilli is a CursorFormat for unix timestamps in milliseconds
+	CursorUnixMilli = "unix_ms"
+)
+
+// Incremental lets a stream sync incrementally: the largest cursor of the emitted records is saved in the state
+// and the next sync only requests the records after it
+//
+// Example usage, one request per day since the last sync, re-reading the last hour for late updates
+//
+//	Incremental: &httpstream.Incremental{
+//		CursorField:    "updated_at",
+//		CursorFormat:   time.RFC3339,
+//		StartDate:      "{{ .config.start_date }}",
+//		StartParam:     "updated_since",
+//		EndParam:       "updated_before",
+//		LookbackWindow: time.Hour,
+//		Step:           24 * time.Hour,
+//	}
+type Incremental struct {
+	// CursorField is the top level record field holding the cursor, e.g. "updated_at"
+	CursorField string
+	// CursorFormat is the time layout of the cursor, e.g. time.RFC3339, CursorUnix or CursorUnixMilli
+	// Cursors without a format are compared as numbers if they are numbers and as strings otherwise,
+	// LookbackWindow and Step need a format
+	CursorFormat string
+	// ParamFormat is the time layout of StartParam and EndParam, CursorFormat if empty
+	ParamFormat string
+	// StartDate is the cursor the first sync starts from, a template like "{{ .config.start_date }}"
+	// The first sync reads everything if empty
+	StartDate string
+	// StartParam is the query param the start of the range is sent in, e.g. "updated_since"
+	// The range is also available in templates as {{ .stream_slice.start }} and {{ .stream_slice.end }}
+	StartParam string
+	// EndParam is the query param the end of the range is sent in, it's left out if empty
+	EndParam string
+	// LookbackWindow moves the start of the range back from the saved cursor, so records which were
+	// updated late are read again
+	LookbackWindow time.Duration
+	// Step slices the range into slices of this length, e.g. 24h reads one day per request
+	// The cursor is checkpointed after every slice. The whole range is read at once if 0
+	Step time.Duration
+}
+
+// Slice is the range of cursors a request reads, formatted like the cursor
+type Slice struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+func (inc *Incremental) validate() error {
+	if inc.CursorField == "" {
+		return errors.New("incremental stream needs a cursor field")
+	}
+	if inc.CursorFormat == "" && (inc.LookbackWindow != 0 || inc.Step != 0) {
+		return errors.New("incremental stream needs a cursor format for a lookback window or a step")
+	}
+	if inc.Step < 0 || inc.LookbackWindow < 0 {
+		return errors.New("incremental stream step and lookback window can't be negative")
+	}
+	return nil
+}
+
+func (inc *Incremental) isTime() bool {
+	return inc.CursorFormat != ""
+}
+
+// slices splits the range from start to now, start is the saved cursor or the start date
+func (inc *Incremental) slices(start string, lookback bool, now time.Time) ([]Slice, error) {
+	if !inc.isTime() {
+		return []Slice{{Start: start}}, nil
+	}
+	if start == "" {
+		if inc.Step != 0 {
+			return nil, errors.New("incremental stream needs a start date to be sliced")
+		}
+		return []Slice{{End: inc.format(now, inc.CursorFormat)}}, nil
+	}
+
+	from, err := inc.parse(start)
+	if err != nil {
+		return nil, err
+	}
+	if lookback {
+		from = from.Add(-inc.LookbackWindow)
+	}
+
+	var slices []Slice
+	for {
+		to := now
+		if inc.Step != 0 && from.Add(inc.Step).Before(now) {
+			to = from.Add(inc.Step)
+		}
+		slices = append(slices, Slice{Start: inc.format(from, inc.CursorFormat), End: inc.format(to, inc.CursorFormat)})
+		if !to.Before(now) {
+			return slices, nil
+		}
+		from = to
+	}
+}
+
+// params returns the slice formatted for the request params
+func (inc *Incremental) params(sl Slice) (map[string]interface{}, error) {
+	p := map[string]interface{}{"start": sl.Start, "end": sl.End}
+	if !inc.isTime() || inc.ParamFormat == "" {
+		return p, nil
+	}
+	for k, v := range p {
+		if v == "" {
+			continue
+		}
+		t, err := inc.parse(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		p[k] = inc.format(t, inc.ParamFormat)
+	}
+	return p, nil
+}
+
+// cursor returns the cursor of a record, ok is false if it doesn't have one
+func (inc *Incremental) cursor(record interface{}) (string, bool, error) {
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return "", false, nil
+	}
+	switch v := m[inc.CursorField].(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		if inc.isTime() {
+			// normalize, so cursors compare and save the same way no matter how the api formats them
+			t, err := inc.parse(v)
+			if err != nil {
+				return "", false, fmt.Errorf("cursor %s: %w", inc.CursorField, err)
+			}
+			return inc.format(t, inc.CursorFormat), true, nil
+		}
+		return v, true, nil
+	case json.Number:
+		return v.String(), true, nil
+	default:
+		return "", false, fmt.Errorf("cursor %s is a %T", inc.CursorField, v)
+	}
+}
+
+// advance returns the cursor after a slice was read completely, read is the largest cursor of its records
+// Sliced ranges move the cursor to the end of the slice even if it had no records
+func (inc *Incremental) advance(cursor string, read string, sl Slice) (string, error) {
+	cursor, err := inc.max(cursor, read)
+	if err != nil || inc.Step == 0 {
+		return cursor, err
+	}
+	return inc.max(cursor, sl.End)
+}
+
+// max returns the larger cursor, empty cursors are smaller than any other
+func (inc *Incremental) max(a string, b string) (string, error) {
+	if a == "" {
+		return b, nil
+	}
+	if b == "" {
+		return a, nil
+	}
+
+	if inc.isTime() {
+		ta, err := inc.parse(a)
+		if err != nil {
+			return "", err
+		}
+		tb, err := inc.parse(b)
+		if err != nil {
+			return "", err
+		}
+		if tb.After(ta) {
+			return b, nil
+		}
+		return a, nil
+	}
+
+	na, errA := strconv.ParseFloat(a, 64)
+	nb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		if nb > na {
+			return b, nil
+		}
+		return a, nil
+	}
+	if b > a {
+		return b, nil
+	}
+	return a, nil
+}
+
+func (inc *Incremental) parse(s string) (time.Time, error) {
+	switch inc.CursorFormat {
+	case CursorUnix, CursorUnixMilli:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cursor %q is not a unix timestamp", s)
+		}
+		if inc.CursorFormat == CursorUnixMilli {
+			return time.UnixMilli(int64(n)).UTC(), nil
+		}
+		return time.Unix(int64(n), 0).UTC(), nil
+	}
+	t, err := time.Parse(inc.CursorFormat, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cursor %q: %w", s, err)
+	}
+	return t, nil
+}
+
+func (inc *Incremental) format(t time.Time, layout string) string {
+	switch layout {
+	case CursorUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case CursorUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.UTC().Format(layout)
+}
@@ -0,0 +1,181 @@
+package httpstream
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+// eventsAPI serves events filtered by updated_since and updated_before, and records the ranges it was asked for
+type eventsAPI struct {
+	mu     sync.Mutex
+	events []map[string]interface{}
+	ranges [][2]string
+}
+
+func (e *eventsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	since, before := r.URL.Query().Get("updated_since"), r.URL.Query().Get("updated_before")
+	e.ranges = append(e.ranges, [2]string{since, before})
+	var page []map[string]interface{}
+	for _, ev := range e.events {
+		at := ev["updated_at"].(string)
+		if (since == "" || at >= since) && (before == "" || at < before) {
+			page = append(page, ev)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": page})
+}
+
+func TestIncrementalSlices(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Now().UTC().Truncate(day).Add(-3 * day)
+	api := &eventsAPI{}
+	for i := 0; i < 3; i++ {
+		api.events = append(api.events, map[string]interface{}{
+			"id":         i,
+			"updated_at": start.Add(time.Duration(i)*day + time.Hour).Format(time.RFC3339),
+		})
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	src := Source{
+		BaseURL: srv.URL,
+		Streams: []Stream{{
+			Name:           "events",
+			Path:           "/events",
+			RecordSelector: "events",
+			Incremental: &Incremental{
+				CursorField:    "updated_at",
+				CursorFormat:   time.RFC3339,
+				StartDate:      "{{ .config.start_date }}",
+				StartParam:     "updated_since",
+				EndParam:       "updated_before",
+				LookbackWindow: 2 * time.Hour,
+				Step:           day,
+			},
+		}},
+	}
+	config := map[string]string{"start_date": start.Format(time.RFC3339)}
+
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := disc.Catalog.Streams[0]
+	if len(stream.SupportedSyncModes) != 2 || !stream.SourceDefinedCursor || stream.DefaultCursorField[0] != "updated_at" {
+		t.Fatalf("expected an incremental stream, got %+v", stream)
+	}
+	catalog := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental)
+
+	out, err := airbytetest.Read(src, config, catalog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["events"]) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(out.Records["events"]))
+	}
+	// one request per day, the last one ends now, with a checkpoint after every slice
+	if len(api.ranges) != 4 || len(out.States) != 4 {
+		t.Fatalf("expected 4 slices and states, got %v and %d states", api.ranges, len(out.States))
+	}
+	if api.ranges[0][0] != start.Format(time.RFC3339) || api.ranges[0][1] != start.Add(day).Format(time.RFC3339) {
+		t.Errorf("unexpected first slice %v", api.ranges[0])
+	}
+	var first State
+	if err := json.Unmarshal(out.States[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first["events"].Cursor != start.Add(day).Format(time.RFC3339) {
+		t.Errorf("expected the first checkpoint at the end of the first slice, got %q", first["events"].Cursor)
+	}
+
+	var st State
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := time.Parse(time.RFC3339, st["events"].Cursor)
+	if err != nil || time.Since(cursor) > time.Minute {
+		t.Fatalf("expected the cursor to end at the last slice, got %q", st["events"].Cursor)
+	}
+
+	// the next sync starts at the cursor minus the lookback window, a late update within it is picked up
+	api.events = append(api.events, map[string]interface{}{
+		"id":         3,
+		"updated_at": cursor.Add(-time.Hour).Format(time.RFC3339),
+	})
+	api.ranges = nil
+	out, err = airbytetest.Read(src, config, catalog, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(api.ranges) != 1 || api.ranges[0][0] != cursor.Add(-2*time.Hour).Format(time.RFC3339) {
+		t.Fatalf("expected a single slice from the lookback window, got %v", api.ranges)
+	}
+	if len(out.Records["events"]) != 1 || string(out.Records["events"][0].Data) != `{"id":3,"updated_at":"`+cursor.Add(-time.Hour).Format(time.RFC3339)+`"}` {
+		t.Errorf("expected only the late event, got %v", out.Records["events"])
+	}
+
+	// full refresh ignores the cursor
+	api.ranges = nil
+	out, err = airbytetest.Read(src, config, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["events"]) != 4 || api.ranges[0][0] != start.Format(time.RFC3339) {
+		t.Errorf("expected full refresh to start at the start date, got %d records from %v", len(out.Records["events"]), api.ranges)
+	}
+}
+
+func TestIncrementalNumericCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, _ := strconv.Atoi(r.URL.Query().Get("since_id"))
+		var page []map[string]int
+		for id := since + 1; id <= 12; id++ {
+			page = append(page, map[string]int{"id": id})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	src := Source{
+		BaseURL: srv.URL,
+		Streams: []Stream{{
+			Name:        "items",
+			Incremental: &Incremental{CursorField: "id", StartParam: "since_id"},
+		}},
+	}
+	disc, err := airbytetest.Discover(src, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental)
+
+	// ids are compared as numbers, 12 is larger than 9
+	out, err := airbytetest.Read(src, map[string]string{}, catalog, State{"items": {Cursor: "8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st State
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["items"]) != 4 || st["items"].Cursor != "12" {
+		t.Errorf("expected ids 9 to 12 and cursor 12, got %d records and cursor %q", len(out.Records["items"]), st["items"].Cursor)
+	}
+
+	src.Streams[0].Incremental.Step = time.Hour
+	if _, err := airbytetest.Discover(src, map[string]string{}); err == nil {
+		t.Error("expected a step without a cursor format to fail")
+	}
+}
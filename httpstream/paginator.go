@@ -0,0 +1,382 @@
+package httpstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Page is a response handed to a Paginator to work out the next page
+type Page struct {
+	// Token is the token this page was requested with, empty for the first page
+	Token string
+	// URL is the url the page was requested from
+	URL *url.URL
+	// Header holds the response headers
+	Header http.Header
+	// Body is the decoded response body, streaming decoders leave the records out of it
+	Body interface{}
+	// Records is the number of records selected out of the page
+	Records int
+}
+
+// Paginator requests the pages of a stream one after the other
+// Tokens are strings so they can be checkpointed in state and used in templates as {{ .next_page_token }}
+type Paginator interface {
+	// Apply changes req to request the page of token, the first page is requested with an empty token
+	Apply(req *http.Request, token string) error
+	// Next returns the token of the page after p, more is false once p is the last page
+	Next(p Page) (token string, more bool, err error)
+}
+
+// OffsetPaginator requests pages with a limit and an offset, e.g. ?limit=100&offset=200
+// It stops after a page with less than PageSize records, or once the total is reached if TotalSelector is set
+type OffsetPaginator struct {
+	// PageSize is sent as the limit, it's required
+	PageSize int
+	// LimitParam is the limit query param, "limit" if empty
+	LimitParam string
+	// OffsetParam is the offset query param, "offset" if empty
+	OffsetParam string
+	// TotalSelector optionally selects the total number of records out of the response body
+	TotalSelector string
+}
+
+func (o OffsetPaginator) Apply(req *http.Request, token string) error {
+	if o.PageSize <= 0 {
+		return fmt.Errorf("offset paginator: page size %d must be positive", o.PageSize)
+	}
+	offset := token
+	if offset == "" {
+		offset = "0"
+	}
+	setParams(req, map[string]string{
+		orDefault(o.LimitParam, "limit"):   strconv.Itoa(o.PageSize),
+		orDefault(o.OffsetParam, "offset"): offset,
+	})
+	return nil
+}
+
+func (o OffsetPaginator) Next(p Page) (string, bool, error) {
+	offset, err := tokenInt(p.Token)
+	if err != nil {
+		return "", false, err
+	}
+	next := offset + int64(p.Records)
+
+	if o.TotalSelector != "" {
+		total, ok, err := selectInt(p.Body, o.TotalSelector)
+		if err != nil {
+			return "", false, err
+		}
+		if ok && next >= total {
+			return "", false, nil
+		}
+	}
+	if p.Records < o.PageSize {
+		return "", false, nil
+	}
+	return strconv.FormatInt(next, 10), true, nil
+}
+
+// PageNumberPaginator requests numbered pages, e.g. ?page=3&per_page=100
+// It stops after an empty page or a page with less than PageSize records,
+// or after the last page if TotalPagesSelector is set
+type PageNumberPaginator struct {
+	// PageSize is sent as SizeParam unless it's 0, in which case only empty pages stop the pagination
+	PageSize int
+	// PageParam is the page number query param, "page" if empty
+	PageParam string
+	// SizeParam is the page size query param, "page_size" if empty
+	SizeParam string
+	// ZeroBased numbers the first page 0 instead of 1
+	ZeroBased bool
+	// TotalPagesSelector optionally selects the number of pages out of the response body
+	TotalPagesSelector string
+}
+
+func (pn PageNumberPaginator) Apply(req *http.Request, token string) error {
+	page := token
+	if page == "" {
+		page = strconv.FormatInt(pn.first(), 10)
+	}
+	params := map[string]string{
+		orDefault(pn.PageParam, "page"): page,
+	}
+	if pn.PageSize > 0 {
+		params[orDefault(pn.SizeParam, "page_size")] = strconv.Itoa(pn.PageSize)
+	}
+	setParams(req, params)
+	return nil
+}
+
+func (pn PageNumberPaginator) Next(p Page) (string, bool, error) {
+	page := pn.first()
+	if p.Token != "" {
+		var err error
+		if page, err = tokenInt(p.Token); err != nil {
+			return "", false, err
+		}
+	}
+
+	if pn.TotalPagesSelector != "" {
+		total, ok, err := selectInt(p.Body, pn.TotalPagesSelector)
+		if err != nil {
+			return "", false, err
+		}
+		// the page number of the last page is total or total-1
+		if ok && page+1-pn.first() >= total {
+			return "", false, nil
+		}
+	}
+	if p.Records == 0 || (pn.PageSize > 0 && p.Records < pn.PageSize) {
+		return "", false, nil
+	}
+	return strconv.FormatInt(page+1, 10), true, nil
+}
+
+func (pn PageNumberPaginator) first() int64 {
+	if pn.ZeroBased {
+		return 0
+	}
+	return 1
+}
+
+// CursorPaginator sends the cursor returned in the response body to request the next page, e.g. ?cursor=abc
+// It stops once the cursor is missing or empty, or once HasMoreSelector selects false
+type CursorPaginator struct {
+	// CursorSelector selects the cursor of the next page out of the response body, e.g. "meta.next_cursor"
+	CursorSelector string
+	// Param is the cursor query param, "cursor" if empty and Header isn't set
+	Param string
+	// Header sends the cursor in a header instead of a query param
+	Header string
+	// HasMoreSelector optionally selects a boolean telling whether there are more pages, e.g. "has_more"
+	HasMoreSelector string
+	// PageSize is sent as SizeParam unless it's 0
+	PageSize int
+	// SizeParam is the page size query param, "limit" if empty
+	SizeParam string
+}
+
+func (c CursorPaginator) Apply(req *http.Request, token string) error {
+	params := map[string]string{}
+	if c.PageSize > 0 {
+		params[orDefault(c.SizeParam, "limit")] = strconv.Itoa(c.PageSize)
+	}
+	if token != "" {
+		if c.Header != "" {
+			req.Header.Set(c.Header, token)
+		} else {
+			params[orDefault(c.Param, "cursor")] = token
+		}
+	}
+	setParams(req, params)
+	return nil
+}
+
+func (c CursorPaginator) Next(p Page) (string, bool, error) {
+	if c.HasMoreSelector != "" {
+		v, ok, err := selectOne(p.Body, c.HasMoreSelector)
+		if err != nil {
+			return "", false, err
+		}
+		if more, isBool := v.(bool); ok && isBool && !more {
+			return "", false, nil
+		}
+	}
+
+	cursor, ok, err := selectString(p.Body, c.CursorSelector)
+	if err != nil || !ok || cursor == "" {
+		return "", false, err
+	}
+	return cursor, true, nil
+}
+
+// LinkHeaderPaginator follows the Link header (RFC 5988) of the response, like the github api uses
+// It stops once there is no link with the relation
+// The linked url is requested as is, so it has to carry every query param
+type LinkHeaderPaginator struct {
+	// Rel is the link relation of the next page, "next" if empty
+	Rel string
+}
+
+func (l LinkHeaderPaginator) Apply(req *http.Request, token string) error {
+	return setURL(req, token)
+}
+
+func (l LinkHeaderPaginator) Next(p Page) (string, bool, error) {
+	rel := orDefault(l.Rel, "next")
+	for _, h := range p.Header.Values("Link") {
+		for _, lk := range parseLinks(h) {
+			for _, r := range lk.rels {
+				if strings.EqualFold(r, rel) {
+					return resolve(p.URL, lk.target)
+				}
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// NextURLPaginator follows the url of the next page returned in the response body, e.g. {"next": "https://..."}
+// It stops once the url is missing or empty. The url is requested as is, relative urls are resolved
+// against the url of the current page
+type NextURLPaginator struct {
+	// NextURLSelector selects the url out of the response body, e.g. "links.next"
+	NextURLSelector string
+}
+
+func (n NextURLPaginator) Apply(req *http.Request, token string) error {
+	return setURL(req, token)
+}
+
+func (n NextURLPaginator) Next(p Page) (string, bool, error) {
+	next, ok, err := selectString(p.Body, n.NextURLSelector)
+	if err != nil || !ok || next == "" {
+		return "", false, err
+	}
+	return resolve(p.URL, next)
+}
+
+type link struct {
+	target string
+	rels   []string
+}
+
+// parseLinks parses a Link header value into the targets and their relations
+// e.g. `<https://api.github.com/user/repos?page=3>; rel="next", <...?page=50>; rel="last"`
+func parseLinks(h string) []link {
+	var links []link
+	for _, value := range splitOutsideQuotes(h, ',') {
+		parts := splitOutsideQuotes(value, ';')
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		lk := link{target: target[1 : len(target)-1]}
+
+		for _, param := range parts[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "rel") {
+				continue
+			}
+			// rel can hold several space separated relations
+			lk.rels = append(lk.rels, strings.Fields(strings.Trim(strings.TrimSpace(kv[1]), `"`))...)
+		}
+		links = append(links, lk)
+	}
+	return links
+}
+
+// splitOutsideQuotes splits s at sep, leaving quoted strings and <urls> intact
+func splitOutsideQuotes(s string, sep rune) []string {
+	var parts []string
+	quoted, inURL, start := false, false, 0
+	for i, r := range s {
+		switch {
+		case r == '"' && !inURL:
+			quoted = !quoted
+		case r == '<' && !quoted:
+			inURL = true
+		case r == '>' && !quoted:
+			inURL = false
+		case r == sep && !quoted && !inURL:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func setParams(req *http.Request, params map[string]string) {
+	q := req.URL.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+}
+
+// setURL points req at the url of a token, the first page keeps the url of the stream
+func setURL(req *http.Request, token string) error {
+	if token == "" {
+		return nil
+	}
+	u, err := url.Parse(token)
+	if err != nil {
+		return fmt.Errorf("page url %q: %w", token, err)
+	}
+	req.URL = u
+	req.Host = u.Host
+	return nil
+}
+
+func resolve(base *url.URL, ref string) (string, bool, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", false, fmt.Errorf("page url %q: %w", ref, err)
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u.String(), true, nil
+}
+
+func tokenInt(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("page token %q is not a number", token)
+	}
+	return n, nil
+}
+
+// selectOne selects a single value, ok is false if the selector selects nothing or null
+func selectOne(body interface{}, selector string) (interface{}, bool, error) {
+	selected, err := selectPath(body, selector)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(selected) == 0 || selected[0] == nil {
+		return nil, false, nil
+	}
+	return selected[0], true, nil
+}
+
+func selectString(body interface{}, selector string) (string, bool, error) {
+	v, ok, err := selectOne(body, selector)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	switch tv := v.(type) {
+	case string:
+		return tv, true, nil
+	case json.Number:
+		return tv.String(), true, nil
+	}
+	return "", false, fmt.Errorf("selector %q selected %T instead of a string", selector, v)
+}
+
+func selectInt(body interface{}, selector string) (int64, bool, error) {
+	s, ok, err := selectString(body, selector)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("selector %q selected %q instead of an integer", selector, s)
+	}
+	return n, true, nil
+}
+
+func orDefault(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
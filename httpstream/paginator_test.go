@@ -0,0 +1,219 @@
+package httpstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+// items serves 7 items, the handler of every test pages them in its own way
+const items = 7
+
+func item(i int) map[string]int { return map[string]int{"id": i} }
+
+func TestPaginators(t *testing.T) {
+	tests := []struct {
+		name      string
+		paginator Paginator
+		selector  string
+		handler   func(w http.ResponseWriter, r *http.Request)
+	}{
+		{
+			name:      "offset",
+			paginator: OffsetPaginator{PageSize: 3},
+			selector:  "results",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+				var page []map[string]int
+				for i := offset; i < offset+limit && i < items; i++ {
+					page = append(page, item(i))
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"results": page})
+			},
+		},
+		{
+			name:      "offset with total",
+			paginator: OffsetPaginator{PageSize: 1, LimitParam: "take", OffsetParam: "skip", TotalSelector: "total"},
+			selector:  "results",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+				json.NewEncoder(w).Encode(map[string]interface{}{"results": []interface{}{item(skip)}, "total": items})
+			},
+		},
+		{
+			name:      "page number",
+			paginator: PageNumberPaginator{PageSize: 2, SizeParam: "per_page"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				size, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+				var recs []map[string]int
+				for i := (page - 1) * size; i < page*size && i < items; i++ {
+					recs = append(recs, item(i))
+				}
+				json.NewEncoder(w).Encode(recs)
+			},
+		},
+		{
+			name:      "zero based page number with total pages",
+			paginator: PageNumberPaginator{ZeroBased: true, TotalPagesSelector: "pages"},
+			selector:  "data",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{item(page)}, "pages": items})
+			},
+		},
+		{
+			name:      "cursor",
+			paginator: CursorPaginator{CursorSelector: "meta.next", HasMoreSelector: "has_more"},
+			selector:  "data",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				i, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"data":     []interface{}{item(i)},
+					"has_more": i+1 < items,
+					"meta":     map[string]string{"next": strconv.Itoa(i + 1)},
+				})
+			},
+		},
+		{
+			name:      "cursor in header",
+			paginator: CursorPaginator{CursorSelector: "next", Header: "X-Cursor"},
+			selector:  "data",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				i, _ := strconv.Atoi(r.Header.Get("X-Cursor"))
+				next := ""
+				if i+1 < items {
+					next = strconv.Itoa(i + 1)
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{item(i)}, "next": next})
+			},
+		},
+		{
+			name:      "link header",
+			paginator: LinkHeaderPaginator{},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				i, _ := strconv.Atoi(r.URL.Query().Get("since"))
+				if i+1 < items {
+					w.Header().Add("Link", fmt.Sprintf(`<http://%s/items?since=%d>; rel="next", <http://%s/items?since=0>; rel="first"`, r.Host, i+1, r.Host))
+				}
+				json.NewEncoder(w).Encode([]interface{}{item(i)})
+			},
+		},
+		{
+			name:      "next url in body",
+			paginator: NextURLPaginator{NextURLSelector: "links.next"},
+			selector:  "data",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				i, _ := strconv.Atoi(r.URL.Query().Get("from"))
+				var next interface{}
+				if i+1 < items {
+					next = fmt.Sprintf("/items?from=%d", i+1)
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{item(i)}, "links": map[string]interface{}{"next": next}})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := httptest.NewServer(http.HandlerFunc(tt.handler))
+			defer api.Close()
+
+			src := Source{
+				BaseURL: api.URL,
+				Streams: []Stream{{
+					Name:            "items",
+					Path:            "/items",
+					RecordSelector:  tt.selector,
+					Paginator:       tt.paginator,
+					CheckpointPages: true,
+				}},
+			}
+
+			out := read(t, src, nil)
+			recs := out.Records["items"]
+			if len(recs) != items {
+				t.Fatalf("expected %d records, got %d", items, len(recs))
+			}
+			for i, r := range recs {
+				if string(r.Data) != fmt.Sprintf(`{"id":%d}`, i) {
+					t.Errorf("record %d is %s", i, r.Data)
+				}
+			}
+
+			// one checkpoint per page, the last one clears the token
+			var st State
+			if err := out.LastState(&st); err != nil {
+				t.Fatal(err)
+			}
+			if st["items"].PageToken != "" {
+				t.Errorf("expected the last state to clear the page token, got %q", st["items"].PageToken)
+			}
+			var first State
+			if err := json.Unmarshal(out.States[0], &first); err != nil {
+				t.Fatal(err)
+			}
+			if first["items"].PageToken == "" {
+				t.Fatal("expected the first state to hold the token of the second page")
+			}
+
+			// resuming from the first checkpoint skips the first page
+			resumed := read(t, src, out.States[0])
+			if n := len(resumed.Records["items"]); n == 0 || n >= items {
+				t.Errorf("expected the resumed read to skip the first page, got %d records", n)
+			}
+		})
+	}
+}
+
+func TestPaginatorRepeatingToken(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": [{"id": 1}], "next": "same"}`)
+	}))
+	defer api.Close()
+
+	src := Source{
+		BaseURL: api.URL,
+		Streams: []Stream{{Name: "items", RecordSelector: "data", Paginator: CursorPaginator{CursorSelector: "next"}}},
+	}
+	disc, err := airbytetest.Discover(src, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := airbytetest.Read(src, map[string]string{}, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil); err == nil {
+		t.Fatal("expected a paginator returning the same token twice to fail instead of looping forever")
+	}
+}
+
+func TestParseLinks(t *testing.T) {
+	links := parseLinks(`<https://api.github.com/user/repos?page=3&per_page=100>; rel="next", <https://x/?a=1,2>; rel="last prev"; title="a;b"`)
+	if len(links) != 2 {
+		t.Fatalf("expected 2 links, got %+v", links)
+	}
+	if links[0].target != "https://api.github.com/user/repos?page=3&per_page=100" || links[0].rels[0] != "next" {
+		t.Errorf("unexpected first link %+v", links[0])
+	}
+	if links[1].target != "https://x/?a=1,2" || len(links[1].rels) != 2 || links[1].rels[1] != "prev" {
+		t.Errorf("unexpected second link %+v", links[1])
+	}
+}
+
+func read(t *testing.T, src Source, state interface{}) *airbytetest.Output {
+	t.Helper()
+	disc, err := airbytetest.Discover(src, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := airbytetest.Read(src, map[string]string{}, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
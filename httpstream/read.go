@@ -0,0 +1,223 @@
+package httpstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// emitFunc receives the raw records of a stream as they are read
+type emitFunc func(record interface{}) error
+
+// checkpointFunc is called after every page with the token of the next page, more is false after the last page
+type checkpointFunc func(sl Slice, next string, more bool) error
+
+// read reads a configured stream, state is updated and emitted as slices, pages and parents are checkpointed
+func (s Source) read(ctx context.Context, client *http.Client, st Stream, cs airbyte.ConfiguredStream, cfg map[string]interface{},
+	cp *airbyte.Checkpointer, tracker airbyte.MessageTracker) error {
+	if st.Parent != nil {
+		return s.readSubstream(ctx, client, st, cs, cfg, cp, tracker)
+	}
+	emit := func(raw interface{}) error {
+		r, err := st.decode(raw)
+		if err != nil {
+			return err
+		}
+		return tracker.Record(r, cs.Stream.Name, cs.Stream.Namespace)
+	}
+
+	ss, _ := cp.Get(st.Name).(StreamState)
+	token := ss.PageToken
+	if token != "" {
+		if err := tracker.Log(airbyte.LogLevelInfo, fmt.Sprintf("resuming stream %s from page %s", st.Name, token)); err != nil {
+			return err
+		}
+	}
+
+	slices, err := s.slices(st, cs, cfg, ss)
+	if err != nil {
+		return err
+	}
+
+	var checkpoint checkpointFunc
+	if st.CheckpointPages {
+		checkpoint = func(sl Slice, next string, more bool) error {
+			if !more && st.Incremental != nil {
+				// incremental streams checkpoint the end of a slice along with the cursor
+				return nil
+			}
+			ss, _ := cp.Get(st.Name).(StreamState)
+			ss.PageToken = s.checkpointToken(next)
+			ss.Slice = nil
+			if more && st.Incremental != nil {
+				ss.Slice = &sl
+			}
+			return cp.Set(st.Name, ss)
+		}
+	}
+
+	for i, sl := range slices {
+		if i > 0 {
+			token = ""
+		}
+		ss, _ = cp.Get(st.Name).(StreamState)
+		data := readData(cfg, ss.Cursor, nil)
+		cursor, err := s.readSlice(ctx, client, st, data, sl, token, emit, checkpoint)
+		if err != nil {
+			return err
+		}
+		if st.Incremental == nil {
+			continue
+		}
+
+		// every record of the slice was read, it's safe to move the cursor
+		ss, _ = cp.Get(st.Name).(StreamState)
+		if ss.Cursor, err = st.Incremental.advance(ss.Cursor, cursor, sl); err != nil {
+			return err
+		}
+		ss.PageToken = ""
+		ss.Slice = nil
+		if err := cp.Set(st.Name, ss); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkpointToken removes the api key query param of Auth from a page token which is a url, like the tokens of
+// LinkHeaderPaginator and NextURLPaginator, so the key isn't written to the state. The request of the page is
+// authenticated again when a sync resumes from it
+func (s Source) checkpointToken(token string) string {
+	if s.Auth == nil || s.Auth.APIKeyHeader != "" || s.Auth.APIKeyParam == "" {
+		return token
+	}
+	u, err := url.Parse(token)
+	if err != nil || u.RawQuery == "" {
+		return token
+	}
+	q := u.Query()
+	if _, ok := q[s.Auth.APIKeyParam]; !ok {
+		return token
+	}
+	q.Del(s.Auth.APIKeyParam)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// slices returns the slices a read of the stream requests, streams which aren't incremental have a single empty slice
+func (s Source) slices(st Stream, cs airbyte.ConfiguredStream, cfg map[string]interface{}, ss StreamState) ([]Slice, error) {
+	inc := st.Incremental
+	if inc == nil {
+		return []Slice{{}}, nil
+	}
+	if err := inc.validate(); err != nil {
+		return nil, err
+	}
+
+	if ss.PageToken != "" && ss.Slice != nil {
+		// finish the slice the page token belongs to, then carry on from its end
+		slices := []Slice{*ss.Slice}
+		if inc.isTime() && ss.Slice.End != "" {
+			rest, err := inc.slices(ss.Slice.End, false, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			slices = append(slices, rest...)
+		}
+		return slices, nil
+	}
+
+	if cs.SyncMode == airbyte.SyncModeIncremental && ss.Cursor != "" {
+		return inc.slices(ss.Cursor, true, time.Now())
+	}
+	start, err := render(inc.StartDate, templateData(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return inc.slices(start, false, time.Now())
+}
+
+// readData returns the template data of a read, parent is the parent record of a substream
+func readData(cfg map[string]interface{}, cursor string, parent interface{}) map[string]interface{} {
+	data := templateData(cfg)
+	data["stream_state"] = map[string]interface{}{"cursor": cursor}
+	if parent != nil {
+		data["parent"] = parent
+	}
+	return data
+}
+
+// readSlice reads every page of a slice and returns the largest cursor of its records, checkpoint may be nil
+func (s Source) readSlice(ctx context.Context, client *http.Client, st Stream, data map[string]interface{}, sl Slice,
+	token string, emit emitFunc, checkpoint checkpointFunc) (string, error) {
+	if st.Incremental != nil {
+		params, err := st.Incremental.params(sl)
+		if err != nil {
+			return "", err
+		}
+		data["stream_slice"] = params
+	}
+
+	var maxCursor string
+	for {
+		req, err := st.request(ctx, s.BaseURL, token, data)
+		if err != nil {
+			return "", err
+		}
+		n := 0
+		resp, err := do(client, req, st.decoder(), func(raw interface{}) error {
+			n++
+			if st.Incremental != nil {
+				c, ok, err := st.Incremental.cursor(raw)
+				if err != nil {
+					return err
+				}
+				if ok {
+					if maxCursor, err = st.Incremental.max(maxCursor, c); err != nil {
+						return err
+					}
+				}
+			}
+			return emit(raw)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if st.Paginator == nil {
+			return maxCursor, nil
+		}
+		next, more, err := st.Paginator.Next(Page{
+			Token:   token,
+			URL:     resp.url,
+			Header:  resp.header,
+			Body:    resp.body,
+			Records: n,
+		})
+		if err != nil {
+			return "", err
+		}
+		if more && next == token {
+			return "", fmt.Errorf("paginator returned the token %q of the current page again", token)
+		}
+		if !more {
+			next = ""
+		}
+
+		if checkpoint != nil {
+			if err := checkpoint(sl, next, more); err != nil {
+				return "", err
+			}
+		}
+
+		if !more {
+			return maxCursor, nil
+		}
+		token = next
+	}
+}
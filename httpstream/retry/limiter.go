@@ -0,0 +1,61 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket, it lets Burst requests through at once and refills Rate requests per second
+// Share one Limiter between every client of an api, the limit is per Limiter
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a full bucket allowing rate requests per second with bursts of up to burst requests
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Wait blocks until a request may be sent and returns how long it waited
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	d := l.reserve(time.Now())
+	if d <= 0 {
+		return 0, nil
+	}
+	return d, sleep(ctx, d)
+}
+
+// reserve takes a token, the bucket goes negative if it's empty and the returned wait is how long
+// it takes to refill to zero, so concurrent waiters queue up instead of racing for the next token
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
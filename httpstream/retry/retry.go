@@ -0,0 +1,301 @@
+// Package retry makes the requests of http sources resilient: failed requests are retried with exponential
+// backoff and jitter, rate limit headers are honored and a token bucket throttles requests on the client side
+package retry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+const (
+	DefaultMaxRetries    = 5
+	DefaultMinBackoff    = time.Second
+	DefaultMaxBackoff    = time.Minute
+	DefaultMaxRetryAfter = 10 * time.Minute
+)
+
+// Options configure the retries and throttling of a connector
+type Options struct {
+	// MaxRetries is how often a request is retried after the first attempt, DefaultMaxRetries if 0, no retries if negative
+	MaxRetries int
+	// MinBackoff is the backoff before the first retry, it doubles with every retry. DefaultMinBackoff if 0
+	MinBackoff time.Duration
+	// MaxBackoff caps the backoff, DefaultMaxBackoff if 0
+	MaxBackoff time.Duration
+	// MaxRetryAfter is the longest Retry-After or rate limit reset the transport waits for, longer waits fail
+	// the request instead. DefaultMaxRetryAfter if 0
+	MaxRetryAfter time.Duration
+	// Retryable decides which statuses are retried, 429 and 5xx except 501 if nil
+	Retryable func(statusCode int) bool
+	// Limiter throttles requests on the client side, unlimited if nil
+	Limiter *Limiter
+}
+
+// Transport retries failed requests and throttles the ones it sends
+//
+// Example usage
+//
+//	client := &http.Client{Transport: &retry.Transport{
+//		Options: retry.Options{Limiter: retry.NewLimiter(10, 5)},
+//		Log:     tracker.Log,
+//	}}
+type Transport struct {
+	Options
+	// Base sends the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+	// Log receives a line for every retry and throttled request, nothing is logged if nil
+	Log airbyte.LogWriter
+
+	mu sync.Mutex
+	// pausedUntil is set when the api reports the rate limit is used up
+	pausedUntil time.Time
+}
+
+// sleep waits for d unless ctx is done first, tests swap it out to skip the waiting
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	maxRetries := t.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// the body can't be sent twice
+		maxRetries = -1
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.throttle(ctx, req); err != nil {
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := t.base().RoundTrip(r)
+		if err == nil {
+			t.observe(resp)
+		}
+
+		wait, reason, retryable := t.classify(resp, err, attempt)
+		if !retryable || attempt >= maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		if wait > t.maxRetryAfter() {
+			// don't block a sync for hours, the next sync can pick it up
+			return resp, err
+		}
+
+		if resp != nil {
+			// drain so the connection can be reused
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+			resp.Body.Close()
+		}
+		t.log(airbyte.LogLevelWarn, fmt.Sprintf("%s %s: %s, retrying in %v (retry %d of %d)",
+			req.Method, redact(req.URL), reason, wait.Round(time.Millisecond), attempt+1, maxRetries))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// classify decides whether a response or error is retried and how long to wait before retrying
+func (t *Transport) classify(resp *http.Response, err error, attempt int) (time.Duration, string, bool) {
+	if err != nil {
+		return t.backoff(attempt), err.Error(), true
+	}
+
+	retryable := t.Retryable
+	if retryable == nil {
+		retryable = defaultRetryable
+	}
+	if !retryable(resp.StatusCode) {
+		return 0, "", false
+	}
+
+	if d, ok := retryAfter(resp.Header, time.Now()); ok {
+		return d, resp.Status, true
+	}
+	if d, ok := rateLimitReset(resp.Header, time.Now()); ok && resp.StatusCode == http.StatusTooManyRequests {
+		return d, resp.Status, true
+	}
+	return t.backoff(attempt), resp.Status, true
+}
+
+// backoff is the exponential backoff of a retry with equal jitter, half of it is random
+func (t *Transport) backoff(attempt int) time.Duration {
+	min, max := t.MinBackoff, t.MaxBackoff
+	if min <= 0 {
+		min = DefaultMinBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// throttle waits for the limiter and for a used up rate limit to reset
+func (t *Transport) throttle(ctx context.Context, req *http.Request) error {
+	t.mu.Lock()
+	pause := time.Until(t.pausedUntil)
+	t.mu.Unlock()
+	if pause > 0 {
+		if pause > t.maxRetryAfter() {
+			return fmt.Errorf("retry: rate limit of %s resets in %v", req.URL.Host, pause.Round(time.Second))
+		}
+		t.log(airbyte.LogLevelInfo, fmt.Sprintf("rate limit of %s is used up, waiting %v", req.URL.Host, pause.Round(time.Millisecond)))
+		if err := sleep(ctx, pause); err != nil {
+			return err
+		}
+	}
+
+	if t.Limiter != nil {
+		waited, err := t.Limiter.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		if waited >= time.Second {
+			t.log(airbyte.LogLevelDebug, fmt.Sprintf("throttled %s %s for %v", req.Method, redact(req.URL), waited.Round(time.Millisecond)))
+		}
+	}
+	return nil
+}
+
+// observe pauses all requests once a response reports the rate limit is used up
+func (t *Transport) observe(resp *http.Response) {
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		return
+	}
+	n, err := strconv.ParseFloat(remaining, 64)
+	if err != nil || n > 0 {
+		return
+	}
+	if d, ok := rateLimitReset(resp.Header, time.Now()); ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.pausedUntil = time.Now().Add(d)
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Transport) maxRetryAfter() time.Duration {
+	if t.MaxRetryAfter <= 0 {
+		return DefaultMaxRetryAfter
+	}
+	return t.MaxRetryAfter
+}
+
+func (t *Transport) log(level airbyte.LogLevel, s string) {
+	if t.Log != nil {
+		// a failing log must not fail the request
+		_ = t.Log(level, s)
+	}
+}
+
+// Client returns a copy of base which sends its requests through a Transport, a nil base copies http.DefaultClient
+func Client(opts Options, log airbyte.LogWriter, base *http.Client) *http.Client {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	c := *base
+	c.Transport = &Transport{
+		Options: opts,
+		Base:    base.Transport,
+		Log:     log,
+	}
+	return &c
+}
+
+func defaultRetryable(code int) bool {
+	return code == http.StatusTooManyRequests || (code >= 500 && code != http.StatusNotImplemented)
+}
+
+// retryAfter parses the Retry-After header, which holds either seconds or a http date
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		return nonNegative(time.Duration(secs * float64(time.Second))), true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return nonNegative(at.Sub(now)), true
+	}
+	return 0, false
+}
+
+// rateLimitReset parses X-RateLimit-Reset, apis either send a unix timestamp or the seconds left
+func rateLimitReset(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("X-RateLimit-Reset")
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false
+	}
+	// no api waits 30 years, so large values are timestamps
+	if n > 1e9 {
+		return nonNegative(time.Unix(int64(n), 0).Sub(now)), true
+	}
+	return nonNegative(time.Duration(n * float64(time.Second))), true
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// redact drops the query, which may hold an api key, so urls can be logged
+func redact(u *url.URL) string {
+	r := *u
+	r.User = nil
+	r.RawQuery = ""
+	return r.String()
+}
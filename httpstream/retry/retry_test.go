@@ -0,0 +1,267 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// recordSleeps replaces sleep for the duration of the test and returns the waits it was asked for
+func recordSleeps(t *testing.T) *[]time.Duration {
+	var mu sync.Mutex
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+type logs struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logs) writer(level airbyte.LogLevel, s string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, string(level)+" "+s)
+	return nil
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		// responses are served in order, the last one over and over
+		responses []func(w http.ResponseWriter)
+		opts      Options
+		status    int
+		requests  int
+		waits     func(waits []time.Duration) bool
+	}{
+		{
+			name: "5xx with backoff",
+			responses: []func(w http.ResponseWriter){
+				status(http.StatusServiceUnavailable), status(http.StatusBadGateway), status(http.StatusOK),
+			},
+			opts:     Options{MinBackoff: time.Second, MaxBackoff: 3 * time.Second},
+			status:   http.StatusOK,
+			requests: 3,
+			waits: func(w []time.Duration) bool {
+				// equal jitter keeps at least half the backoff
+				return len(w) == 2 && w[0] >= 500*time.Millisecond && w[0] <= time.Second &&
+					w[1] >= time.Second && w[1] <= 2*time.Second
+			},
+		},
+		{
+			name: "retry after seconds",
+			responses: []func(w http.ResponseWriter){
+				header(http.StatusTooManyRequests, "Retry-After", "7"), status(http.StatusOK),
+			},
+			status:   http.StatusOK,
+			requests: 2,
+			waits:    func(w []time.Duration) bool { return len(w) == 1 && w[0] == 7*time.Second },
+		},
+		{
+			name: "retry after date",
+			responses: []func(w http.ResponseWriter){
+				header(http.StatusServiceUnavailable, "Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)), status(http.StatusOK),
+			},
+			status:   http.StatusOK,
+			requests: 2,
+			waits:    func(w []time.Duration) bool { return len(w) == 1 && w[0] > 59*time.Minute && w[0] <= time.Hour },
+			opts:     Options{MaxRetryAfter: 2 * time.Hour},
+		},
+		{
+			name: "rate limit reset on 429",
+			responses: []func(w http.ResponseWriter){
+				header(http.StatusTooManyRequests, "X-RateLimit-Reset", "30"), status(http.StatusOK),
+			},
+			status:   http.StatusOK,
+			requests: 2,
+			waits:    func(w []time.Duration) bool { return len(w) == 1 && w[0] == 30*time.Second },
+		},
+		{
+			name: "retry after beyond the max fails",
+			responses: []func(w http.ResponseWriter){
+				header(http.StatusTooManyRequests, "Retry-After", "3600"),
+			},
+			status:   http.StatusTooManyRequests,
+			requests: 1,
+			waits:    func(w []time.Duration) bool { return len(w) == 0 },
+		},
+		{
+			name:      "gives up after max retries",
+			responses: []func(w http.ResponseWriter){status(http.StatusInternalServerError)},
+			opts:      Options{MaxRetries: 2, MinBackoff: time.Millisecond},
+			status:    http.StatusInternalServerError,
+			requests:  3,
+			waits:     func(w []time.Duration) bool { return len(w) == 2 },
+		},
+		{
+			name:      "4xx is not retried",
+			responses: []func(w http.ResponseWriter){status(http.StatusNotFound)},
+			status:    http.StatusNotFound,
+			requests:  1,
+			waits:     func(w []time.Duration) bool { return len(w) == 0 },
+		},
+		{
+			name:      "disabled retries",
+			responses: []func(w http.ResponseWriter){status(http.StatusServiceUnavailable)},
+			opts:      Options{MaxRetries: -1},
+			status:    http.StatusServiceUnavailable,
+			requests:  1,
+			waits:     func(w []time.Duration) bool { return len(w) == 0 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := recordSleeps(t)
+
+			var mu sync.Mutex
+			var requests int
+			var bodies []string
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				b, _ := ioutil.ReadAll(r.Body)
+				bodies = append(bodies, string(b))
+				i := requests
+				if i >= len(tt.responses) {
+					i = len(tt.responses) - 1
+				}
+				requests++
+				tt.responses[i](w)
+			}))
+			defer api.Close()
+
+			var l logs
+			req, _ := http.NewRequest(http.MethodPost, api.URL+"/items?api_key=secret", strings.NewReader("payload"))
+			resp, err := Client(tt.opts, l.writer, nil).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status || requests != tt.requests {
+				t.Errorf("expected status %d after %d requests, got %d after %d", tt.status, tt.requests, resp.StatusCode, requests)
+			}
+			if !tt.waits(*waits) {
+				t.Errorf("unexpected waits %v", *waits)
+			}
+			for _, b := range bodies {
+				if b != "payload" {
+					t.Errorf("retry sent body %q", b)
+				}
+			}
+			if len(l.lines) != len(*waits) {
+				t.Errorf("expected a log line per retry, got %q", l.lines)
+			}
+			for _, line := range l.lines {
+				if strings.Contains(line, "secret") || !strings.HasPrefix(line, "WARN") {
+					t.Errorf("unexpected log line %q", line)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkErrorsAreRetried(t *testing.T) {
+	waits := recordSleeps(t)
+
+	attempts := 0
+	tr := &Transport{
+		Options: Options{MinBackoff: time.Millisecond},
+		Base: roundTripper(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, fmt.Errorf("connection reset by peer")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: http.Header{}}, nil
+		}),
+	}
+	resp, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, "http://api/", nil))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the third attempt to succeed, got %v", err)
+	}
+	if len(*waits) != 2 {
+		t.Errorf("expected 2 backoffs, got %v", *waits)
+	}
+}
+
+func TestUsedUpRateLimitPauses(t *testing.T) {
+	waits := recordSleeps(t)
+
+	reset := time.Now().Add(45 * time.Second).Unix()
+	tr := &Transport{
+		Base: roundTripper(func(r *http.Request) (*http.Response, error) {
+			h := http.Header{}
+			h.Set("X-RateLimit-Remaining", "0")
+			h.Set("X-RateLimit-Reset", fmt.Sprint(reset))
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: h}, nil
+		}),
+	}
+	var l logs
+	tr.Log = l.writer
+	for i := 0; i < 2; i++ {
+		if _, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, "http://api/", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*waits) != 1 || (*waits)[0] < 40*time.Second || (*waits)[0] > 45*time.Second {
+		t.Errorf("expected the second request to wait for the reset, got %v", *waits)
+	}
+	if len(l.lines) != 1 || !strings.HasPrefix(l.lines[0], "INFO") {
+		t.Errorf("expected the pause to be logged, got %q", l.lines)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(10, 2)
+	now := time.Now()
+	var waits []time.Duration
+	for i := 0; i < 4; i++ {
+		waits = append(waits, l.reserve(now))
+	}
+	// the burst goes through, then requests queue up 100ms apart
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i := range want {
+		if d := waits[i] - want[i]; d < -time.Millisecond || d > time.Millisecond {
+			t.Fatalf("expected waits %v, got %v", want, waits)
+		}
+	}
+
+	// later on the bucket is full again, but not fuller than the burst
+	later := now.Add(10 * time.Second)
+	if l.reserve(later) != 0 || l.reserve(later) != 0 || l.reserve(later) == 0 {
+		t.Error("expected the bucket to refill up to the burst")
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func status(code int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) { w.WriteHeader(code) }
+}
+
+func header(code int, k string, v string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set(k, v)
+		w.WriteHeader(code)
+	}
+}
@@ -0,0 +1,80 @@
+package httpstream
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// selectPath walks a decoded json value along a record selector
+// A selector is a dot separated path, optionally starting with "$", e.g. "data.items" or "$.results.0.rows"
+// Numeric segments index arrays and "*" selects every element of an array or value of an object
+// The empty selector selects the whole value
+func selectPath(v interface{}, selector string) ([]interface{}, error) {
+	selector = strings.TrimPrefix(strings.TrimPrefix(selector, "$"), ".")
+	if selector == "" {
+		return []interface{}{v}, nil
+	}
+
+	values := []interface{}{v}
+	for _, seg := range strings.Split(selector, ".") {
+		var next []interface{}
+		for _, cur := range values {
+			switch c := cur.(type) {
+			case nil:
+				// missing parts select nothing
+			case map[string]interface{}:
+				if seg == "*" {
+					keys := make([]string, 0, len(c))
+					for k := range c {
+						keys = append(keys, k)
+					}
+					// keep the order stable between syncs
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, c[k])
+					}
+				} else if e, ok := c[seg]; ok {
+					next = append(next, e)
+				}
+			case []interface{}:
+				if seg == "*" {
+					next = append(next, c...)
+					continue
+				}
+				i, err := strconv.Atoi(seg)
+				if err != nil {
+					return nil, fmt.Errorf("selector %q: %q is not an index of an array", selector, seg)
+				}
+				if i >= 0 && i < len(c) {
+					next = append(next, c[i])
+				}
+			default:
+				return nil, fmt.Errorf("selector %q: can't select %q from %T", selector, seg, cur)
+			}
+		}
+		values = next
+	}
+
+	return values, nil
+}
+
+// records flattens the selected values into records, arrays hold one record per element and nulls none
+func records(selected []interface{}) []interface{} {
+	var recs []interface{}
+	for _, v := range selected {
+		switch tv := v.(type) {
+		case nil:
+		case []interface{}:
+			for _, e := range tv {
+				if e != nil {
+					recs = append(recs, e)
+				}
+			}
+		default:
+			recs = append(recs, tv)
+		}
+	}
+	return recs
+}
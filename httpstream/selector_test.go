@@ -0,0 +1,46 @@
+package httpstream
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSelectPath(t *testing.T) {
+	body := `{"data": {"items": [{"id": 1}, {"id": 2}], "next": null}, "groups": {"b": [{"id": 4}], "a": [{"id": 3}]}}`
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"data.items", `[{"id":1},{"id":2}]`},
+		{"$.data.items", `[{"id":1},{"id":2}]`},
+		{"data.items.1", `[{"id":2}]`},
+		{"data.items.*.id", `[1,2]`},
+		{"groups.*", `[{"id":3},{"id":4}]`},
+		{"data.next", `null`},
+		{"data.missing", `null`},
+		{"", `[` + body + `]`},
+	}
+	for _, tt := range tests {
+		selected, err := selectPath(v, tt.selector)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.selector, err)
+		}
+		var want []interface{}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if got := records(selected); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", tt.selector, got, want)
+		}
+	}
+
+	if _, err := selectPath(v, "data.items.id"); err == nil {
+		t.Error("expected selecting a key out of an array to fail")
+	}
+}
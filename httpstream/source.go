@@ -0,0 +1,281 @@
+// Package httpstream builds airbyte sources for REST APIs out of stream declarations
+// instead of hand written requests, the Source runs the requests, selects the records
+// out of the responses and emits them for every configured stream
+package httpstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/httpstream/auth"
+	"github.com/bitstrapped/airbyte/httpstream/retry"
+)
+
+// Source is an airbyte.Source for a REST API
+//
+// Example usage
+//
+//	src := httpstream.Source{
+//		BaseURL:       "https://api.example.com/v1",
+//		Specification: spec,
+//		Streams: []httpstream.Stream{{
+//			Name:           "users",
+//			Path:           "/users",
+//			Params:         map[string]string{"api_key": "{{ .config.api_key }}"},
+//			RecordSelector: "data",
+//			RecordType:     User{},
+//			PrimaryKey:     [][]string{{"id"}},
+//		}},
+//	}
+//	err := airbyte.NewSourceRunner(src, os.Stdout).Start()
+type Source struct {
+	// BaseURL is prepended to the path of every stream, it's a template like the stream paths
+	BaseURL string
+	// Streams are the streams offered by Discover
+	Streams []Stream
+	// Client sends all requests, nil uses http.DefaultClient
+	Client *http.Client
+	// Specification is returned by Spec
+	Specification *airbyte.ConnectorSpecification
+	// CheckStream is the stream whose first request Check sends to validate the config, the first stream if empty
+	CheckStream string
+	// Auth authenticates every request with the auth.Credentials found under CredentialsKey in the config,
+	// requests are sent as they are if nil. Refreshed oauth2 tokens are emitted as config updates
+	Auth *auth.Options
+	// CredentialsKey is the config key of the credentials, "credentials" if empty
+	CredentialsKey string
+	// Retry retries failed requests and throttles requests, retries and throttling are logged.
+	// Requests are sent once if nil
+	Retry *retry.Options
+	// Workers is the number of streams read at once, streams are read one after the other in catalog order if 0
+	Workers int
+	// IsolateFailures keeps reading the other streams after a stream failed, see airbyte.StreamScheduler
+	IsolateFailures bool
+}
+
+var _ airbyte.Source = Source{}
+
+// Spec returns the Specification
+func (s Source) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecification, error) {
+	if s.Specification == nil {
+		return nil, errors.New("httpstream: source has no specification")
+	}
+	return s.Specification, nil
+}
+
+// Check sends the first request of the CheckStream and fails unless it succeeds
+func (s Source) Check(srcCfgPath string, logTracker airbyte.LogTracker) error {
+	cfg, err := loadConfig(srcCfgPath)
+	if err != nil {
+		return err
+	}
+
+	name := s.CheckStream
+	if name == "" {
+		if len(s.Streams) == 0 {
+			return errors.New("httpstream: source has no streams")
+		}
+		name = s.Streams[0].Name
+	}
+	st, ok := s.stream(name)
+	if !ok {
+		return fmt.Errorf("httpstream: check stream %s is not declared", name)
+	}
+
+	if err := logTracker.Log(airbyte.LogLevelDebug, fmt.Sprintf("checking connection with stream %s", name)); err != nil {
+		return err
+	}
+	client, err := s.httpClient(cfg, logTracker.Log, logTracker.Config)
+	if err != nil {
+		return err
+	}
+	req, err := st.request(context.Background(), s.BaseURL, "", templateData(cfg))
+	if err != nil {
+		return err
+	}
+	_, err = do(client, req, st.decoder(), nil)
+	return err
+}
+
+// Discover returns every declared stream
+func (s Source) Discover(srcCfgPath string, logTracker airbyte.LogTracker) (*airbyte.Catalog, error) {
+	if _, err := loadConfig(srcCfgPath); err != nil {
+		return nil, err
+	}
+
+	cat := &airbyte.Catalog{}
+	for _, st := range s.Streams {
+		as, err := st.discover(logTracker)
+		if err != nil {
+			return nil, err
+		}
+		cat.Streams = append(cat.Streams, as)
+	}
+	return cat, nil
+}
+
+// Read reads every configured stream, in catalog order unless Workers is set
+func (s Source) Read(sourceCfgPath string, prevStatePath string, configuredCat *airbyte.ConfiguredCatalog,
+	tracker airbyte.MessageTracker) error {
+	cfg, err := loadConfig(sourceCfgPath)
+	if err != nil {
+		return err
+	}
+
+	state := State{}
+	if prevStatePath != "" {
+		if err := airbyte.UnmarshalFromPath(prevStatePath, &state); err != nil {
+			return err
+		}
+		if state == nil {
+			state = State{}
+		}
+	}
+
+	client, err := s.httpClient(cfg, tracker.Log, tracker.Config)
+	if err != nil {
+		return err
+	}
+
+	for _, cs := range configuredCat.Streams {
+		if _, ok := s.stream(cs.Stream.Name); !ok {
+			return fmt.Errorf("httpstream: stream %s is not declared", cs.Stream.Name)
+		}
+	}
+
+	states := make(map[string]interface{}, len(state))
+	for name, ss := range state {
+		states[name] = ss
+	}
+	cp := airbyte.NewCheckpointer(states, tracker.State)
+	sched := airbyte.StreamScheduler{
+		Workers:         s.Workers,
+		IsolateFailures: s.IsolateFailures,
+		Log:             tracker.Log,
+	}
+	return sched.Run(context.Background(), configuredCat.Streams, func(ctx context.Context, cs airbyte.ConfiguredStream) error {
+		st, _ := s.stream(cs.Stream.Name)
+		return s.read(ctx, client, st, cs, cfg, cp, tracker)
+	})
+}
+
+func (s Source) stream(name string) (Stream, bool) {
+	for _, st := range s.Streams {
+		if st.Name == name {
+			return st, true
+		}
+	}
+	return Stream{}, false
+}
+
+// httpClient returns the client requests are sent with, authenticated with the credentials of the config
+// and retrying failed requests. Rotated credentials are emitted with update
+func (s Source) httpClient(cfg map[string]interface{}, log airbyte.LogWriter, update airbyte.ConfigWriter) (*http.Client, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if s.Auth != nil {
+		a, err := s.authenticator(client, cfg, update)
+		if err != nil {
+			return nil, err
+		}
+		// auth goes below retries, so every retry is authenticated again with a fresh token
+		client = auth.Client(a, client)
+	}
+	if s.Retry != nil {
+		client = retry.Client(*s.Retry, log, client)
+	}
+	return client, nil
+}
+
+func (s Source) authenticator(client *http.Client, cfg map[string]interface{}, update airbyte.ConfigWriter) (auth.Authenticator, error) {
+	key := s.CredentialsKey
+	if key == "" {
+		key = "credentials"
+	}
+	b, err := json.Marshal(cfg[key])
+	if err != nil {
+		return nil, err
+	}
+	creds, err := auth.ParseCredentials(b)
+	if err != nil {
+		return nil, fmt.Errorf("httpstream: config %s: %w", key, err)
+	}
+
+	opts := *s.Auth
+	if opts.Client == nil {
+		opts.Client = client
+	}
+	onRotate := opts.OnRotate
+	opts.OnRotate = func(rotated auth.Credentials) error {
+		if onRotate != nil {
+			if err := onRotate(rotated); err != nil {
+				return err
+			}
+		}
+		if update == nil {
+			return nil
+		}
+		// streams render their templates with cfg concurrently, so the update goes into a copy
+		updated := make(map[string]interface{}, len(cfg))
+		for k, v := range cfg {
+			updated[k] = v
+		}
+		updated[key] = rotated
+		return update(updated)
+	}
+
+	return creds.Authenticator(opts)
+}
+
+// State is the state of a Source, the state of every stream is kept under the stream name
+type State map[string]StreamState
+
+// StreamState is the state of a single stream
+type StreamState struct {
+	// Cursor is the largest cursor read by an incremental stream
+	Cursor string `json:"cursor,omitempty"`
+	// PageToken is the token of the next page to read, set while a stream with CheckpointPages is in progress
+	PageToken string `json:"page_token,omitempty"`
+	// Slice is the slice PageToken belongs to
+	Slice *Slice `json:"slice,omitempty"`
+	// ParentsDone is the number of parents a substream is done with in the order they are read, and LastParent the
+	// key of the last of them, set while a substream is in progress
+	ParentsDone int    `json:"parents_done,omitempty"`
+	LastParent  string `json:"last_parent,omitempty"`
+}
+
+// loadConfig reads the source config as a plain json object, so any config key can be used in templates
+// Numbers are kept as written instead of turning into floats
+func loadConfig(path string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("httpstream: config: %w", err)
+	}
+	return cfg, nil
+}
+
+// templateData is what the templates of a stream are rendered with
+// The stream state and slice are empty until a read fills them in
+func templateData(cfg map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"config":       cfg,
+		"stream_state": map[string]interface{}{"cursor": ""},
+		"stream_slice": map[string]interface{}{"start": "", "end": ""},
+	}
+}
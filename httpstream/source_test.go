@@ -0,0 +1,220 @@
+package httpstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+	"github.com/bitstrapped/airbyte/httpstream/auth"
+)
+
+func TestAuthRotationEmitsConfig(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("refresh_token") != "old" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "fresh", "refresh_token": "new", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[{"id": 1}]`)
+	})
+	api := httptest.NewServer(mux)
+	defer api.Close()
+
+	src := Source{
+		BaseURL: api.URL,
+		Streams: []Stream{{Name: "items", Path: "/items"}},
+		Auth:    &auth.Options{TokenURL: api.URL + "/token"},
+	}
+	config := map[string]interface{}{
+		"region": "eu",
+		"credentials": map[string]string{
+			"auth_type":     "oauth2_refresh_token",
+			"client_id":     "id",
+			"client_secret": "secret",
+			"refresh_token": "old",
+		},
+	}
+
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := airbytetest.Read(src, config, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["items"]) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(out.Records["items"]))
+	}
+
+	if len(out.Configs) != 1 {
+		t.Fatalf("expected the rotated token to be emitted once, got %d config updates", len(out.Configs))
+	}
+	var updated struct {
+		Region      string                  `json:"region"`
+		Credentials auth.RefreshTokenConfig `json:"credentials"`
+	}
+	if err := json.Unmarshal(out.Configs[0], &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Region != "eu" || updated.Credentials.RefreshToken != "new" || updated.Credentials.AccessToken != "fresh" {
+		t.Errorf("unexpected config update %s", out.Configs[0])
+	}
+
+	// the updated config reuses the access token instead of refreshing again
+	out, err = airbytetest.Read(src, out.Configs[0], airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Configs) != 0 || len(out.Records["items"]) != 1 {
+		t.Errorf("expected a read without refresh, got %d config updates and %d records", len(out.Configs), len(out.Records["items"]))
+	}
+}
+
+func TestStatusErrorHidesQuery(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "bad key")
+	}))
+	defer api.Close()
+
+	src := Source{
+		BaseURL: api.URL,
+		Streams: []Stream{{Name: "items", Path: "/items"}},
+		Auth:    &auth.Options{APIKeyParam: "api_key"},
+	}
+	client, err := src.httpClient(map[string]interface{}{
+		"credentials": map[string]string{"auth_type": "api_key", "api_key": "hunter2"},
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := src.Streams[0].request(context.Background(), src.BaseURL, "", templateData(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = do(client, req, src.Streams[0].decoder(), nil)
+	se, ok := err.(*StatusError)
+	if !ok || se.StatusCode != http.StatusForbidden || se.Body != "bad key" {
+		t.Fatalf("expected a status error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("error leaks the api key: %v", err)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		if page == "3" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		fmt.Fprintf(w, `[{"id": "%s%s"}]`, r.URL.Path, page)
+	}))
+	defer api.Close()
+
+	var streams []Stream
+	for _, name := range []string{"a", "b", "broken", "c"} {
+		streams = append(streams, Stream{
+			Name:            name,
+			Path:            "/" + name,
+			Paginator:       PageNumberPaginator{},
+			CheckpointPages: true,
+		})
+	}
+	src := Source{BaseURL: api.URL, Streams: streams, Workers: 3, IsolateFailures: true}
+
+	disc, err := airbytetest.Discover(src, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := airbytetest.Read(src, map[string]string{}, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh), nil)
+	var errs airbyte.StreamErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Stream != "broken" {
+		t.Fatalf("expected only the broken stream to fail, got %v", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if len(out.Records[name]) != 2 {
+			t.Errorf("expected 2 records of %s, got %d", name, len(out.Records[name]))
+		}
+	}
+	// every checkpoint holds the state of the other streams as well
+	var st State
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if len(st) != 3 {
+		t.Fatalf("expected the state of 3 streams, got %v", st)
+	}
+}
+
+func TestCheckpointPagesHidesKey(t *testing.T) {
+	failed := false
+	var api *httptest.Server
+	api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api_key") != "hunter2" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("page") != "2" {
+			// the link echoes the key like many apis do
+			w.Header().Set("Link", fmt.Sprintf(`<%s/items?api_key=hunter2&page=2>; rel="next"`, api.URL))
+			fmt.Fprint(w, `[{"id": 1}]`)
+			return
+		}
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `[{"id": 2}]`)
+	}))
+	defer api.Close()
+
+	src := Source{
+		BaseURL: api.URL,
+		Streams: []Stream{{Name: "items", Path: "/items", Paginator: LinkHeaderPaginator{}, CheckpointPages: true}},
+		Auth:    &auth.Options{APIKeyParam: "api_key"},
+	}
+	config := map[string]interface{}{"credentials": map[string]string{"auth_type": "api_key", "api_key": "hunter2"}}
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh)
+	out, err := airbytetest.Read(src, config, cat, nil)
+	if err == nil || len(out.States) != 1 {
+		t.Fatalf("expected the second page to fail after a checkpoint, got %v and %d states", err, len(out.States))
+	}
+	if strings.Contains(string(out.States[0]), "hunter2") {
+		t.Fatalf("state leaks the api key: %s", out.States[0])
+	}
+
+	// the page is authenticated again when the sync resumes
+	out, err = airbytetest.Read(src, config, cat, out.States[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["items"]) != 1 {
+		t.Fatalf("expected to resume with the second page, got %d records", len(out.Records["items"]))
+	}
+}
@@ -0,0 +1,285 @@
+package httpstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// Stream declares a single REST endpoint and how to turn its responses into records
+// Path, Params, Headers and Body are text/templates, "{{ .config.api_key }}" is replaced with the api_key of the source config
+type Stream struct {
+	// Name is the stream name shown in the catalog
+	Name string
+	// Namespace is the stream namespace shown in the catalog
+	Namespace string
+	// Method is the http method, GET if empty
+	Method string
+	// Path is appended to the BaseURL of the source
+	Path string
+	// Params are added to the query string, params which render to an empty string are left out
+	// so optional config values don't send empty params
+	Params map[string]string
+	// Headers are set on every request
+	Headers map[string]string
+	// Body is sent as the request body, e.g. for graphql or search endpoints which only accept POST
+	Body string
+	// RecordSelector is the path to the records in the response body, e.g. "data.items"
+	// The whole body is selected if empty, see selectPath for the syntax
+	RecordSelector string
+	// Decoder decodes the records out of the response body, e.g. a JSONDecoder to stream huge arrays,
+	// or a JSONLinesDecoder, CSVDecoder or XMLDecoder. It takes precedence over the RecordSelector
+	// gzip and deflate bodies are decompressed, see RegisterDecompressor for other encodings
+	Decoder Decoder
+	// RecordType is a zero value of the record struct, e.g. User{}
+	// Records are decoded into it, which drops undeclared fields, and the stream schema is inferred from it
+	// Records are emitted as they were received if nil
+	RecordType interface{}
+	// Schema is the stream json schema, it takes precedence over the schema inferred from RecordType
+	Schema *airbyte.Properties
+	// PrimaryKey is the source defined primary key, e.g. [][]string{{"id"}}
+	PrimaryKey [][]string
+	// Paginator requests the pages after the first one, only the first page is read if nil
+	// The token of the next page is available in templates as {{ .next_page_token }}
+	Paginator Paginator
+	// CheckpointPages emits the state with the token of the next page after every page,
+	// so a failed sync resumes from the page it failed on instead of the first one
+	// Tokens which are urls are checkpointed without the api key param of Source.Auth, api keys a Source.Client
+	// sends in the query itself end up in the state
+	CheckpointPages bool
+	// Incremental lets the stream sync incrementally, it only supports full refresh if nil
+	Incremental *Incremental
+	// Parent makes the stream a substream of another stream, e.g. the payments of every user
+	Parent *Parent
+}
+
+// StatusError is returned for responses without a 2xx status code
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// URL is the request url without its query, which may hold an api key
+	URL string
+	// Body is the start of the response body, which usually explains what went wrong
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.URL, e.Status, e.Body)
+}
+
+// maxErrorBody is how much of an error response body ends up in a StatusError
+const maxErrorBody = 1024
+
+// response is a decoded response
+type response struct {
+	url    *url.URL
+	header http.Header
+	body   interface{}
+}
+
+func (st Stream) discover(logTracker airbyte.LogTracker) (airbyte.Stream, error) {
+	var props airbyte.Properties
+	switch {
+	case st.Schema != nil:
+		props = *st.Schema
+	case st.RecordType != nil:
+		var err error
+		props, err = airbyte.InferSchemaFromStruct(st.RecordType, logTracker)
+		if err != nil {
+			return airbyte.Stream{}, fmt.Errorf("stream %s: %w", st.Name, err)
+		}
+	}
+
+	as := airbyte.Stream{
+		Name:                    st.Name,
+		Namespace:               st.Namespace,
+		JSONSchema:              props,
+		SupportedSyncModes:      []airbyte.SyncMode{airbyte.SyncModeFullRefresh},
+		SourceDefinedPrimaryKey: st.PrimaryKey,
+	}
+	if st.Incremental != nil {
+		if err := st.Incremental.validate(); err != nil {
+			return airbyte.Stream{}, fmt.Errorf("stream %s: %w", st.Name, err)
+		}
+		as.SupportedSyncModes = append(as.SupportedSyncModes, airbyte.SyncModeIncremental)
+		as.SourceDefinedCursor = true
+		as.DefaultCursorField = []string{st.Incremental.CursorField}
+	}
+	if st.Parent != nil && (st.Parent.Stream == "" || st.Parent.Key == "") {
+		return airbyte.Stream{}, fmt.Errorf("stream %s: parent stream and key are required", st.Name)
+	}
+	return as, nil
+}
+
+// request builds the request of a stream for the page of token, data is what the templates are rendered with
+func (st Stream) request(ctx context.Context, baseURL string, token string, data map[string]interface{}) (*http.Request, error) {
+	data["next_page_token"] = token
+
+	base, err := render(baseURL, data)
+	if err != nil {
+		return nil, err
+	}
+	path, err := render(st.Path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + path)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	for k, v := range st.Params {
+		rv, err := render(v, data)
+		if err != nil {
+			return nil, err
+		}
+		if rv != "" {
+			q.Set(k, rv)
+		}
+	}
+	if inc := st.Incremental; inc != nil {
+		sl, _ := data["stream_slice"].(map[string]interface{})
+		for param, key := range map[string]string{inc.StartParam: "start", inc.EndParam: "end"} {
+			if v, _ := sl[key].(string); param != "" && v != "" {
+				q.Set(param, v)
+			}
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	method := st.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if st.Body != "" {
+		b, err := render(st.Body, data)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding())
+	for k, v := range st.Headers {
+		rv, err := render(v, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, rv)
+	}
+
+	if st.Paginator != nil {
+		if err := st.Paginator.Apply(req, token); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// do sends the request and decodes the response, every record is handed to fn
+func do(client *http.Client, req *http.Request, dec Decoder, fn emitFunc) (*response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			URL:        redact(req.URL),
+			Body:       string(b),
+		}
+	}
+
+	body, err := decompress(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", redact(req.URL), err)
+	}
+	if fn == nil {
+		fn = func(interface{}) error { return nil }
+	}
+	rest, err := dec.Decode(body, func(record interface{}) error {
+		if err := fn(record); err != nil {
+			return &emitError{err}
+		}
+		return nil
+	})
+	var ee *emitError
+	if errors.As(err, &ee) {
+		return nil, ee.err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: decode response: %w", redact(req.URL), err)
+	}
+
+	return &response{
+		url:    req.URL,
+		header: resp.Header,
+		body:   rest,
+	}, nil
+}
+
+// emitError tells the errors of a record apart from the errors of the decoder
+type emitError struct {
+	err error
+}
+
+func (e *emitError) Error() string {
+	return e.err.Error()
+}
+
+// redact drops the query and user info of u, either may hold credentials which must not end up in logs
+func redact(u *url.URL) string {
+	r := *u
+	r.User = nil
+	r.RawQuery = ""
+	return r.String()
+}
+
+// decoder returns the Decoder of the stream
+func (st Stream) decoder() Decoder {
+	if st.Decoder != nil {
+		return st.Decoder
+	}
+	return selectorDecoder{selector: st.RecordSelector}
+}
+
+// decode decodes a record into the RecordType
+func (st Stream) decode(r interface{}) (interface{}, error) {
+	if st.RecordType == nil {
+		return r, nil
+	}
+
+	t := reflect.TypeOf(st.RecordType)
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal(b, v.Interface()); err != nil {
+		return nil, fmt.Errorf("decode record into %v: %w", t, err)
+	}
+	return v.Interface(), nil
+}
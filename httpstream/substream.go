@@ -0,0 +1,189 @@
+package httpstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// Parent turns a stream into a substream, which is read once for every record of its parent stream,
+// e.g. the payments of every user. The parent record is available in templates as {{ .parent.id }}
+type Parent struct {
+	// Stream is the name of the parent stream, it is read in full whether or not it is selected in the catalog
+	// Parents can be substreams themselves
+	Stream string
+	// Key is the field which identifies a parent record, e.g. "id", see selectPath for the syntax
+	// The last parent which is done is checkpointed, so a failed sync resumes with the parent after it
+	Key string
+	// RecordField is the field of the child records the parent key is added to, e.g. "user_id"
+	// The key isn't added if empty. Declare the field on the RecordType, undeclared fields are dropped
+	RecordField string
+}
+
+// maxParentDepth stops parent streams which are their own ancestors
+const maxParentDepth = 8
+
+// errParentsMoved is returned when the last parent a substream checkpointed isn't at its position anymore
+var errParentsMoved = errors.New("the parents moved since the checkpoint")
+
+// readSubstream reads the child records of every parent after the last one which is done and checkpoints the parent
+// after its children were emitted. The cursor of an incremental substream moves once every parent is done
+// A sync starts over with the first parent if the parents were reordered since the checkpoint, so no parent is missed
+func (s Source) readSubstream(ctx context.Context, client *http.Client, st Stream, cs airbyte.ConfiguredStream,
+	cfg map[string]interface{}, cp *airbyte.Checkpointer, tracker airbyte.MessageTracker) error {
+	ss, _ := cp.Get(st.Name).(StreamState)
+	if ss.ParentsDone > 0 {
+		if err := tracker.Log(airbyte.LogLevelInfo,
+			fmt.Sprintf("resuming stream %s after parent %s, %d parents are done", st.Name, ss.LastParent, ss.ParentsDone)); err != nil {
+			return err
+		}
+	}
+
+	// page tokens aren't checkpointed, a substream resumes with the first parent which isn't done
+	ss.PageToken = ""
+	ss.Slice = nil
+	slices, err := s.slices(st, cs, cfg, ss)
+	if err != nil {
+		return err
+	}
+
+	var maxCursor string
+	read := func(resume int) error {
+		i := 0
+		err := s.each(ctx, client, st.Parent, cfg, 1, func(parent interface{}) error {
+			key, err := parentKey(st.Parent, parent)
+			if err != nil {
+				return err
+			}
+			i++
+			switch {
+			case i == resume && key != ss.LastParent:
+				return errParentsMoved
+			case i <= resume:
+				return nil
+			}
+
+			cursor, err := s.readChildren(ctx, client, st, cfg, ss.Cursor, slices, parent, func(raw interface{}) error {
+				r, err := st.decode(raw)
+				if err != nil {
+					return err
+				}
+				return tracker.Record(r, cs.Stream.Name, cs.Stream.Namespace)
+			})
+			if err != nil {
+				return fmt.Errorf("parent %s: %w", key, err)
+			}
+			if st.Incremental != nil {
+				if maxCursor, err = st.Incremental.max(maxCursor, cursor); err != nil {
+					return err
+				}
+			}
+
+			ss.ParentsDone = i
+			ss.LastParent = key
+			return cp.Set(st.Name, ss)
+		})
+		if err == nil && i < resume {
+			return errParentsMoved
+		}
+		return err
+	}
+	err = read(ss.ParentsDone)
+	if errors.Is(err, errParentsMoved) {
+		msg := fmt.Sprintf("stream %s: parent %d isn't %s anymore, starting over with the first parent",
+			st.Name, ss.ParentsDone, ss.LastParent)
+		if err := tracker.Log(airbyte.LogLevelWarn, msg); err != nil {
+			return err
+		}
+		ss.ParentsDone, ss.LastParent = 0, ""
+		err = read(0)
+	}
+	if err != nil {
+		return err
+	}
+
+	// every parent is done, the next sync starts over with the first parent
+	ss.ParentsDone, ss.LastParent = 0, ""
+	if st.Incremental != nil && len(slices) > 0 {
+		if ss.Cursor, err = st.Incremental.advance(ss.Cursor, maxCursor, slices[len(slices)-1]); err != nil {
+			return err
+		}
+	}
+	return cp.Set(st.Name, ss)
+}
+
+// each reads the records of a parent stream in full and hands them to fn
+func (s Source) each(ctx context.Context, client *http.Client, p *Parent, cfg map[string]interface{}, depth int,
+	fn emitFunc) error {
+	if depth > maxParentDepth {
+		return fmt.Errorf("parent stream %s: more than %d levels of parents, do the parents form a cycle?",
+			p.Stream, maxParentDepth)
+	}
+	st, ok := s.stream(p.Stream)
+	if !ok {
+		return fmt.Errorf("parent stream %s is not declared", p.Stream)
+	}
+
+	slices, err := s.slices(st, airbyte.ConfiguredStream{}, cfg, StreamState{})
+	if err != nil {
+		return fmt.Errorf("parent stream %s: %w", st.Name, err)
+	}
+	if st.Parent == nil {
+		_, err := s.readChildren(ctx, client, st, cfg, "", slices, nil, fn)
+		return err
+	}
+	return s.each(ctx, client, st.Parent, cfg, depth+1, func(parent interface{}) error {
+		_, err := s.readChildren(ctx, client, st, cfg, "", slices, parent, fn)
+		return err
+	})
+}
+
+// readChildren reads every slice of st for a single parent and returns the largest cursor of the records
+// The parent key is added to every record before it's handed to emit
+func (s Source) readChildren(ctx context.Context, client *http.Client, st Stream, cfg map[string]interface{},
+	cursor string, slices []Slice, parent interface{}, emit emitFunc) (string, error) {
+	if parent != nil && st.Parent.RecordField != "" {
+		key, _, err := selectOne(parent, st.Parent.Key)
+		if err != nil {
+			return "", err
+		}
+		next := emit
+		emit = func(raw interface{}) error {
+			m, ok := raw.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("can't add the parent key to a record of type %T", raw)
+			}
+			m[st.Parent.RecordField] = key
+			return next(m)
+		}
+	}
+
+	var maxCursor string
+	for _, sl := range slices {
+		c, err := s.readSlice(ctx, client, st, readData(cfg, cursor, parent), sl, "", emit, nil)
+		if err != nil {
+			return "", err
+		}
+		if st.Incremental != nil {
+			if maxCursor, err = st.Incremental.max(maxCursor, c); err != nil {
+				return "", err
+			}
+		}
+	}
+	return maxCursor, nil
+}
+
+// parentKey returns the key a parent is checkpointed with
+func parentKey(p *Parent, parent interface{}) (string, error) {
+	key, ok, err := selectString(parent, p.Key)
+	if err != nil {
+		return "", fmt.Errorf("parent stream %s: %w", p.Stream, err)
+	}
+	if !ok || key == "" {
+		return "", fmt.Errorf("parent stream %s: record without a %s", p.Stream, p.Key)
+	}
+	return key, nil
+}
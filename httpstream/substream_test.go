@@ -0,0 +1,184 @@
+package httpstream
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+// usersAPI serves users and the payments of every user, payments of a user in failFor fail with a 500
+// The users are 1, 2 and 3 unless users is set
+type usersAPI struct {
+	mu       sync.Mutex
+	failFor  string
+	users    string
+	payments map[string]int
+}
+
+func (u *usersAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if r.URL.Path == "/users" {
+		users := u.users
+		if users == "" {
+			users = `[{"id": 1}, {"id": 2}, {"id": 3}]`
+		}
+		w.Write([]byte(`{"users": ` + users + `}`))
+		return
+	}
+	user := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/users/"), "/payments")
+	if user == u.failFor {
+		http.Error(w, "down", http.StatusInternalServerError)
+		return
+	}
+	u.payments[user]++
+	json.NewEncoder(w).Encode(map[string]interface{}{"payments": []map[string]interface{}{
+		{"id": user + "-a", "amount": 10},
+		{"id": user + "-b", "amount": 20},
+	}})
+}
+
+func TestSubstream(t *testing.T) {
+	api := &usersAPI{failFor: "2", payments: map[string]int{}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	type payment struct {
+		ID     string `json:"id"`
+		UserID int    `json:"user_id"`
+		Amount int    `json:"amount"`
+	}
+	src := Source{
+		BaseURL: srv.URL,
+		Streams: []Stream{{
+			Name:           "users",
+			Path:           "/users",
+			RecordSelector: "users",
+		}, {
+			Name:           "payments",
+			Path:           "/users/{{ .parent.id }}/payments",
+			RecordSelector: "payments",
+			RecordType:     payment{},
+			Parent:         &Parent{Stream: "users", Key: "id", RecordField: "user_id"},
+		}},
+	}
+
+	disc, err := airbytetest.Discover(src, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the substream is selected, the parent stream is read anyway
+	catalog := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh)
+	catalog.Streams = catalog.Streams[1:]
+
+	out, err := airbytetest.Read(src, map[string]string{}, catalog, nil)
+	if err == nil {
+		t.Fatal("expected the payments of user 2 to fail")
+	}
+	if len(out.Records["payments"]) != 2 || len(out.Records["users"]) != 0 {
+		t.Fatalf("expected the 2 payments of user 1, got %v", out.Records)
+	}
+	var st State
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if ss := st["payments"]; ss.ParentsDone != 1 || ss.LastParent != "1" {
+		t.Fatalf("expected user 1 to be checkpointed, got %+v", ss)
+	}
+
+	// the next sync skips user 1
+	api.failFor = ""
+	out, err = airbytetest.Read(src, map[string]string{}, catalog, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.payments["1"] != 1 || api.payments["2"] != 1 || api.payments["3"] != 1 {
+		t.Fatalf("expected every user to be read once, got %v", api.payments)
+	}
+	var payments []payment
+	for _, r := range out.Records["payments"] {
+		var p payment
+		if err := r.Unmarshal(&p); err != nil {
+			t.Fatal(err)
+		}
+		payments = append(payments, p)
+	}
+	if len(payments) != 4 || payments[0].UserID != 2 || payments[3].UserID != 3 || payments[3].ID != "3-b" {
+		t.Fatalf("expected the payments of users 2 and 3 with their user_id, got %+v", payments)
+	}
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if ss := st["payments"]; ss.ParentsDone != 0 || ss.LastParent != "" {
+		t.Fatalf("expected the parents to be reset after a complete sync, got %+v", ss)
+	}
+}
+
+func TestSubstreamCycle(t *testing.T) {
+	src := Source{
+		BaseURL: "http://localhost",
+		Streams: []Stream{
+			{Name: "a", Parent: &Parent{Stream: "b", Key: "id"}},
+			{Name: "b", Parent: &Parent{Stream: "a", Key: "id"}},
+		},
+	}
+	catalog := &airbyte.ConfiguredCatalog{Streams: []airbyte.ConfiguredStream{{
+		Stream:   airbyte.Stream{Name: "a"},
+		SyncMode: airbyte.SyncModeFullRefresh,
+	}}}
+	_, err := airbytetest.Read(src, map[string]string{}, catalog, nil)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
+
+func TestSubstreamReordered(t *testing.T) {
+	api := &usersAPI{failFor: "2", payments: map[string]int{}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	src := Source{
+		BaseURL: srv.URL,
+		Streams: []Stream{{
+			Name:           "users",
+			Path:           "/users",
+			RecordSelector: "users",
+		}, {
+			Name:           "payments",
+			Path:           "/users/{{ .parent.id }}/payments",
+			RecordSelector: "payments",
+			Parent:         &Parent{Stream: "users", Key: "id"},
+		}},
+	}
+	disc, err := airbytetest.Discover(src, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeFullRefresh)
+	catalog.Streams = catalog.Streams[1:]
+	out, err := airbytetest.Read(src, map[string]string{}, catalog, nil)
+	if err == nil {
+		t.Fatal("expected the payments of user 2 to fail")
+	}
+
+	// user 1 isn't the first parent anymore, so the sync starts over instead of skipping user 3
+	api.failFor = ""
+	api.users = `[{"id": 3}, {"id": 1}, {"id": 2}]`
+	out, err = airbytetest.Read(src, map[string]string{}, catalog, out.States[len(out.States)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.payments["1"] != 2 || api.payments["2"] != 1 || api.payments["3"] != 1 {
+		t.Fatalf("expected every user to be read again, got %v", api.payments)
+	}
+	if len(out.Records["payments"]) != 6 {
+		t.Fatalf("expected the payments of every user, got %d", len(out.Records["payments"]))
+	}
+}
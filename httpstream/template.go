@@ -0,0 +1,27 @@
+package httpstream
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// render executes text as a text/template with data, e.g. "/users/{{ .config.account_id }}"
+// Text without actions is returned as is. Missing keys are errors rather than "<no value>"
+func render(text string, data map[string]interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse %q: %w", text, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render %q: %w", text, err)
+	}
+	return buf.String(), nil
+}
@@ -1,33 +1,236 @@
 package airbyte
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/bitstrapped/airbyte/schema"
 	"reflect"
+
+	"github.com/bitstrapped/airbyte/schema"
 )
 
+// ErrUnsupportedSchema is returned when a schema uses a keyword which can't be represented by a PropertySpec
+var ErrUnsupportedSchema = errors.New("schema keyword can not be represented as a property spec")
+
 // Infer schema translates golang structs to JSONSchema format
-func InferSchemaFromStruct(i interface{}, logTracker LogTracker) Properties {
+func InferSchemaFromStruct(i interface{}, logTracker LogTracker) (Properties, error) {
+	return InferSchemaFromStructWithOptions(i, schema.Options{}, logTracker)
+}
+
+// InferSchemaFromStructWithOptions works like InferSchemaFromStruct, using opts to configure the generated schema
+// Use schema.NullableExplicit to only make pointers, omitempty fields and fields tagged nullable:"true" nullable,
+// slices and maps stay nullable as nil ones are encoded as null
+func InferSchemaFromStructWithOptions(i interface{}, opts schema.Options, logTracker LogTracker) (Properties, error) {
 	var prop Properties
 
-	s, err := schema.Generate(reflect.TypeOf(i))
+	s, err := schema.GenerateWithOptions(reflect.TypeOf(i), opts, nil)
 	if err != nil {
 		logTracker.Log(LogLevelError, fmt.Sprintf("generate schema error: %v", err))
-		return prop
+		return prop, err
 	}
 
-	b, err := json.Marshal(s)
+	spec, err := PropertySpecFromSchema(s)
 	if err != nil {
-		logTracker.Log(LogLevelError, fmt.Sprintf("json marshal schema error: %v", err))
-		return prop
+		logTracker.Log(LogLevelError, fmt.Sprintf("convert schema to propspec error: %v", err))
+		return prop, err
 	}
 
-	err = json.Unmarshal(b, &prop)
-	if err != nil {
-		logTracker.Log(LogLevelError, fmt.Sprintf("unmarshal schema to propspec error: %v", err))
-		return prop
+	prop.Properties = spec.Properties
+	prop.Required = spec.Required
+	return prop, nil
+}
+
+// PropertySpecFromSchema converts a generated schema into a PropertySpec
+// It returns ErrUnsupportedSchema if the schema uses keywords a PropertySpec can't hold (allOf, anyOf, not, $ref etc.)
+// instead of silently dropping them. A PropertySpec only has examples, so the OpenAPI example of a schema becomes
+// its first example
+func PropertySpecFromSchema(s *schema.Schema) (PropertySpec, error) {
+	var spec PropertySpec
+	if s == nil {
+		return spec, nil
+	}
+
+	switch {
+	case len(s.AllOf) > 0:
+		return spec, fmt.Errorf("%w: allOf", ErrUnsupportedSchema)
+	case len(s.AnyOf) > 0:
+		return spec, fmt.Errorf("%w: anyOf", ErrUnsupportedSchema)
+	case s.Not != nil:
+		return spec, fmt.Errorf("%w: not", ErrUnsupportedSchema)
+	case s.Ref != "":
+		return spec, fmt.Errorf("%w: $ref", ErrUnsupportedSchema)
+	case len(s.PatternProperties) > 0:
+		return spec, fmt.Errorf("%w: patternProperties", ErrUnsupportedSchema)
+	case s.ContentEncoding != "":
+		return spec, fmt.Errorf("%w: contentEncoding", ErrUnsupportedSchema)
+	}
+
+	for _, t := range s.Type {
+		spec.Type = append(spec.Type, PropType(t))
+	}
+	// nullable is the OpenAPI way of saying the type includes null
+	if s.Nullable && !hasPropType(spec.Type, Null) {
+		spec.Type = append(spec.Type, Null)
+	}
+	spec.AirbyteType = AirbytePropType(s.AirbyteType)
+
+	if s.Example != nil {
+		spec.Examples = append(spec.Examples, s.Example)
+	}
+	spec.Examples = append(spec.Examples, s.Examples...)
+
+	spec.Description = s.Description
+	spec.Title = s.Title
+	spec.Order = s.Order
+	spec.Group = s.Group
+	spec.IsSecret = s.AirbyteSecret
+	spec.IsHidden = s.AirbyteHidden
+	spec.AlwaysShow = s.AlwaysShow
+	spec.Multiline = s.Multiline
+	spec.Const = s.Const
+	spec.Format = FormatType(s.Format)
+	spec.Enum = s.Enum
+	spec.Default = s.Default
+	spec.Pattern = s.Pattern
+	spec.Minimum = s.Minimum
+	spec.ExclusiveMinimum = s.ExclusiveMinimum
+	spec.Maximum = s.Maximum
+	spec.ExclusiveMaximum = s.ExclusiveMaximum
+	spec.MultipleOf = s.MultipleOf
+	spec.MinLength = s.MinLength
+	spec.MaxLength = s.MaxLength
+	spec.MinItems = s.MinItems
+	spec.MaxItems = s.MaxItems
+	spec.UniqueItems = s.UniqueItems
+	spec.MinProperties = s.MinProperties
+	spec.MaxProperties = s.MaxProperties
+	spec.ReadOnly = s.ReadOnly
+	spec.WriteOnly = s.WriteOnly
+	spec.Deprecated = s.Deprecated
+
+	for _, r := range s.Required {
+		spec.Required = append(spec.Required, PropertyName(r))
+	}
+
+	if s.Items != nil {
+		items, err := PropertySpecFromSchema(s.Items)
+		if err != nil {
+			return spec, fmt.Errorf("items: %w", err)
+		}
+		spec.Items = &items
 	}
 
-	return prop
+	if len(s.Properties) > 0 {
+		spec.Properties = make(map[PropertyName]PropertySpec, len(s.Properties))
+		for name, ps := range s.Properties {
+			p, err := PropertySpecFromSchema(ps)
+			if err != nil {
+				return spec, fmt.Errorf("%s: %w", name, err)
+			}
+			spec.Properties[PropertyName(name)] = p
+		}
+	}
+
+	for i, o := range s.OneOf {
+		p, err := PropertySpecFromSchema(o)
+		if err != nil {
+			return spec, fmt.Errorf("oneOf[%d]: %w", i, err)
+		}
+		spec.OneOf = append(spec.OneOf, p)
+	}
+
+	switch ap := s.AdditionalProperties.(type) {
+	case nil:
+	case bool:
+		spec.AdditionalProperties = &AdditionalProperties{Allowed: ap}
+	case *schema.Schema:
+		p, err := PropertySpecFromSchema(ap)
+		if err != nil {
+			return spec, fmt.Errorf("additionalProperties: %w", err)
+		}
+		spec.AdditionalProperties = &AdditionalProperties{Allowed: true, Spec: &p}
+	default:
+		return spec, fmt.Errorf("%w: additionalProperties of type %T", ErrUnsupportedSchema, ap)
+	}
+
+	return spec, nil
+}
+
+// SchemaFromPropertySpec converts a PropertySpec back into a schema
+// Every PropertySpec keyword has a schema counterpart so nothing is lost on the way back
+func SchemaFromPropertySpec(spec PropertySpec) *schema.Schema {
+	s := &schema.Schema{
+		Description:      spec.Description,
+		AirbyteType:      string(spec.AirbyteType),
+		Title:            spec.Title,
+		Order:            spec.Order,
+		Group:            spec.Group,
+		AirbyteSecret:    spec.IsSecret,
+		AirbyteHidden:    spec.IsHidden,
+		AlwaysShow:       spec.AlwaysShow,
+		Multiline:        spec.Multiline,
+		Const:            spec.Const,
+		Format:           string(spec.Format),
+		Enum:             spec.Enum,
+		Default:          spec.Default,
+		Pattern:          spec.Pattern,
+		Minimum:          spec.Minimum,
+		ExclusiveMinimum: spec.ExclusiveMinimum,
+		Maximum:          spec.Maximum,
+		ExclusiveMaximum: spec.ExclusiveMaximum,
+		MultipleOf:       spec.MultipleOf,
+		MinLength:        spec.MinLength,
+		MaxLength:        spec.MaxLength,
+		MinItems:         spec.MinItems,
+		MaxItems:         spec.MaxItems,
+		UniqueItems:      spec.UniqueItems,
+		MinProperties:    spec.MinProperties,
+		MaxProperties:    spec.MaxProperties,
+		ReadOnly:         spec.ReadOnly,
+		WriteOnly:        spec.WriteOnly,
+		Deprecated:       spec.Deprecated,
+	}
+
+	for _, t := range spec.Type {
+		s.Type = append(s.Type, string(t))
+	}
+
+	s.Examples = spec.Examples
+
+	for _, r := range spec.Required {
+		s.Required = append(s.Required, string(r))
+	}
+
+	if spec.Items != nil {
+		s.Items = SchemaFromPropertySpec(*spec.Items)
+	}
+
+	if len(spec.Properties) > 0 {
+		s.Properties = make(map[string]*schema.Schema, len(spec.Properties))
+		for name, p := range spec.Properties {
+			s.Properties[string(name)] = SchemaFromPropertySpec(p)
+		}
+	}
+
+	for _, o := range spec.OneOf {
+		s.OneOf = append(s.OneOf, SchemaFromPropertySpec(o))
+	}
+
+	if ap := spec.AdditionalProperties; ap != nil {
+		if ap.Spec != nil {
+			s.AdditionalProperties = SchemaFromPropertySpec(*ap.Spec)
+		} else {
+			s.AdditionalProperties = ap.Allowed
+		}
+	}
+
+	return s
+}
+
+func hasPropType(types []PropType, t PropType) bool {
+	for _, pt := range types {
+		if pt == t {
+			return true
+		}
+	}
+	return false
 }
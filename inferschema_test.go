@@ -0,0 +1,102 @@
+package airbyte
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+func TestInferSchemaRequired(t *testing.T) {
+	type Config struct {
+		Host  string `json:"host"`
+		Port  *int   `json:"port"`
+		Token string `json:"token,omitempty"`
+		Note  string `json:"note" nullable:"true"`
+		Inner struct {
+			Name  string `json:"name"`
+			Label string `json:"label,omitempty"`
+		} `json:"inner"`
+	}
+
+	logTracker := LogTracker{Log: func(LogLevel, string) error { return nil }}
+	props, err := InferSchemaFromStructWithOptions(Config{}, schema.Options{Nullability: schema.NullableExplicit}, logTracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []PropertyName{"host", "inner"}; !reflect.DeepEqual(props.Required, want) {
+		t.Errorf("expected %v to be required, got %v", want, props.Required)
+	}
+	if want := []PropertyName{"name"}; !reflect.DeepEqual(props.Properties["inner"].Required, want) {
+		t.Errorf("expected %v to be required in inner, got %v", want, props.Properties["inner"].Required)
+	}
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	order, min, max, minLen := 2, 1.0, 10.0, uint64(3)
+	s := &schema.Schema{
+		Type:     []string{"object"},
+		Title:    "Config",
+		Required: []string{"name"},
+		Properties: map[string]*schema.Schema{
+			"name": {Type: []string{"string"}, Format: "email", Pattern: "^.+@.+$", MinLength: &minLen,
+				Examples: []interface{}{"a@b.c"}, Order: &order, AirbyteSecret: true},
+			"size":  {Type: []string{"integer"}, Minimum: &min, Maximum: &max, Default: 5.0, Enum: []interface{}{1.0, 5.0}, Examples: []interface{}{5.0}},
+			"tags":  {Type: []string{"array", "null"}, Items: &schema.Schema{Type: []string{"string"}}, UniqueItems: true},
+			"extra": {Type: []string{"object"}, AdditionalProperties: &schema.Schema{Type: []string{"string"}}},
+			"auth": {OneOf: []*schema.Schema{
+				{Type: []string{"object"}, Properties: map[string]*schema.Schema{"kind": {Const: "key"}}, AdditionalProperties: false},
+				{Type: []string{"object"}, Properties: map[string]*schema.Schema{"kind": {Const: "oauth"}}},
+			}},
+			"legacy": {Type: []string{"string"}, ReadOnly: true, Deprecated: true},
+		},
+	}
+
+	spec, err := PropertySpecFromSchema(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := SchemaFromPropertySpec(spec); !reflect.DeepEqual(got, s) {
+		t.Errorf("expected\n%+v\ngot\n%+v", s, got)
+	}
+}
+
+func TestSchemaUnsupported(t *testing.T) {
+	for name, s := range map[string]*schema.Schema{
+		"allOf":             {AllOf: []*schema.Schema{{}}},
+		"anyOf":             {AnyOf: []*schema.Schema{{}}},
+		"not":               {Not: &schema.Schema{}},
+		"$ref":              {Ref: "#/definitions/a"},
+		"patternProperties": {PatternProperties: map[string]*schema.Schema{"^a": {}}},
+		"contentEncoding":   {ContentEncoding: "base64"},
+		"items":             {Items: &schema.Schema{Not: &schema.Schema{}}},
+		"properties":        {Properties: map[string]*schema.Schema{"a": {Ref: "#/a"}}},
+		"oneOf":             {OneOf: []*schema.Schema{{AnyOf: []*schema.Schema{{}}}}},
+		"additional":        {AdditionalProperties: &schema.Schema{AllOf: []*schema.Schema{{}}}},
+		"additional type":   {AdditionalProperties: "yes"},
+	} {
+		if _, err := PropertySpecFromSchema(s); !errors.Is(err, ErrUnsupportedSchema) {
+			t.Errorf("%s: expected ErrUnsupportedSchema, got %v", name, err)
+		}
+	}
+}
+
+func TestInferSchemaAnnotations(t *testing.T) {
+	type Config struct {
+		Retries int    `json:"retries" example:"5"`
+		ID      string `json:"id" readOnly:"true"`
+		Old     string `json:"old,omitempty" deprecated:"true"`
+	}
+
+	props, err := InferSchemaFromStruct(Config{}, LogTracker{Log: func(LogLevel, string) error { return nil }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := props.Properties["retries"].Examples; !reflect.DeepEqual(got, []interface{}{5}) {
+		t.Errorf("expected the example as a number, got %v", got)
+	}
+	if !props.Properties["id"].ReadOnly || !props.Properties["old"].Deprecated {
+		t.Errorf("expected the annotations to be kept, got %+v and %+v", props.Properties["id"], props.Properties["old"])
+	}
+}
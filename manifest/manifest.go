@@ -0,0 +1,223 @@
+// Package manifest builds REST sources out of YAML or JSON manifests, so simple connectors don't need any Go
+// A manifest declares the spec, authentication and streams of an httpstream.Source
+//
+// Example manifest
+//
+//	version: "1"
+//	base_url: https://api.example.com/v1
+//	spec:
+//	  title: Example
+//	  required: [start_date]
+//	  properties:
+//	    start_date:
+//	      type: string
+//	      format: date-time
+//	auth:
+//	  methods: [api_key]
+//	  api_key_header: X-API-Key
+//	streams:
+//	  - name: users
+//	    path: /users
+//	    record_selector: data.items
+//	    primary_key: [id]
+//	    paginator:
+//	      type: cursor
+//	      cursor_selector: meta.next_cursor
+//	    incremental:
+//	      cursor_field: updated_at
+//	      cursor_format: rfc3339
+//	      start_date: "{{ .config.start_date }}"
+//	      start_param: updated_since
+//	    schema:
+//	      properties:
+//	        id: {type: integer}
+//	        updated_at: {type: string, format: date-time}
+package manifest
+
+import (
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest declares a REST source
+type Manifest struct {
+	// Version is the manifest version, "1" if empty
+	Version string `yaml:"version"`
+	// BaseURL is prepended to the path of every stream, it's a template like the stream paths
+	BaseURL string `yaml:"base_url"`
+	// Spec is the connector config the user fills in
+	Spec Spec `yaml:"spec"`
+	// Check names the stream whose first request validates the config, the first stream if empty
+	Check Check `yaml:"check"`
+	// Auth adds a credentials property to the spec and authenticates every request with it
+	Auth *Auth `yaml:"auth"`
+	// Retry retries failed requests, requests are sent once if nil
+	Retry *Retry `yaml:"retry"`
+	// Streams are the streams of the source
+	Streams []Stream `yaml:"streams"`
+}
+
+// Spec is the connection specification of the source
+type Spec struct {
+	DocumentationURL string   `yaml:"documentation_url"`
+	Title            string   `yaml:"title"`
+	Description      string   `yaml:"description"`
+	Required         []string `yaml:"required"`
+	// Properties are the json schemas of the config properties, e.g. {start_date: {type: string}}
+	Properties yaml.Node `yaml:"properties"`
+}
+
+// Check configures the check command
+type Check struct {
+	Stream string `yaml:"stream"`
+}
+
+// Auth configures authentication, see httpstream/auth
+type Auth struct {
+	// CredentialsKey is the config property of the credentials, "credentials" if empty
+	CredentialsKey string `yaml:"credentials_key"`
+	// Title is the title of the credentials property, "Authentication" if empty
+	Title string `yaml:"title"`
+	// Methods are the auth methods offered to the user, e.g. [api_key, oauth2_refresh_token], all of them if empty
+	Methods      []string `yaml:"methods"`
+	APIKeyHeader string   `yaml:"api_key_header"`
+	APIKeyParam  string   `yaml:"api_key_param"`
+	APIKeyPrefix string   `yaml:"api_key_prefix"`
+	TokenURL     string   `yaml:"token_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// Retry configures retries and client side rate limits, see httpstream/retry
+type Retry struct {
+	// MaxRetries is the number of retries, the retry package default if nil and no retries if 0
+	MaxRetries    *int          `yaml:"max_retries"`
+	MinBackoff    time.Duration `yaml:"min_backoff"`
+	MaxBackoff    time.Duration `yaml:"max_backoff"`
+	MaxRetryAfter time.Duration `yaml:"max_retry_after"`
+	// RequestsPerSecond throttles requests on the client side unless it's 0
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	// Burst is the number of requests sent at once before throttling kicks in, 1 if 0
+	Burst int `yaml:"burst"`
+}
+
+// Stream declares a stream, see httpstream.Stream
+type Stream struct {
+	Name           string            `yaml:"name"`
+	Namespace      string            `yaml:"namespace"`
+	Method         string            `yaml:"method"`
+	Path           string            `yaml:"path"`
+	Params         map[string]string `yaml:"params"`
+	Headers        map[string]string `yaml:"headers"`
+	Body           string            `yaml:"body"`
+	RecordSelector string            `yaml:"record_selector"`
+	Decoder        *Decoder          `yaml:"decoder"`
+	// PrimaryKey holds the fields of the primary key, nested fields are dotted paths, e.g. [id] or [account.id, id]
+	PrimaryKey      []string     `yaml:"primary_key"`
+	Paginator       *Paginator   `yaml:"paginator"`
+	CheckpointPages bool         `yaml:"checkpoint_pages"`
+	Incremental     *Incremental `yaml:"incremental"`
+	Parent          *Parent      `yaml:"parent"`
+	// Schema is the json schema of the records, records are emitted as they are received
+	Schema yaml.Node `yaml:"schema"`
+}
+
+// Decoder types
+const (
+	DecoderJSON      = "json"
+	DecoderJSONLines = "jsonl"
+	DecoderCSV       = "csv"
+	DecoderXML       = "xml"
+)
+
+// Decoder picks how response bodies are decoded
+type Decoder struct {
+	// Type is json, jsonl, csv or xml
+	Type string `yaml:"type"`
+	// RecordPath is the path to the records of a json body, e.g. "data.items[*]"
+	RecordPath string `yaml:"record_path"`
+	// Delimiter is the field delimiter of a csv body, "," if empty
+	Delimiter string            `yaml:"delimiter"`
+	Header    []string          `yaml:"header"`
+	Columns   map[string]string `yaml:"columns"`
+	// ElementPath is the path to the record elements of an xml body, e.g. "rss/channel/item"
+	ElementPath string `yaml:"element_path"`
+}
+
+// Paginator types
+const (
+	PaginatorOffset     = "offset"
+	PaginatorPageNumber = "page_number"
+	PaginatorCursor     = "cursor"
+	PaginatorLinkHeader = "link_header"
+	PaginatorNextURL    = "next_url"
+)
+
+// Paginator declares one of the httpstream paginators, only the fields of its type are used
+type Paginator struct {
+	// Type is offset, page_number, cursor, link_header or next_url
+	Type               string `yaml:"type"`
+	PageSize           int    `yaml:"page_size"`
+	LimitParam         string `yaml:"limit_param"`
+	OffsetParam        string `yaml:"offset_param"`
+	TotalSelector      string `yaml:"total_selector"`
+	PageParam          string `yaml:"page_param"`
+	SizeParam          string `yaml:"size_param"`
+	ZeroBased          bool   `yaml:"zero_based"`
+	TotalPagesSelector string `yaml:"total_pages_selector"`
+	CursorSelector     string `yaml:"cursor_selector"`
+	Param              string `yaml:"param"`
+	Header             string `yaml:"header"`
+	HasMoreSelector    string `yaml:"has_more_selector"`
+	Rel                string `yaml:"rel"`
+	NextURLSelector    string `yaml:"next_url_selector"`
+}
+
+// Incremental declares an incremental stream, see httpstream.Incremental
+type Incremental struct {
+	CursorField string `yaml:"cursor_field"`
+	// CursorFormat is rfc3339, date, unix, unix_ms or a Go time layout
+	CursorFormat   string        `yaml:"cursor_format"`
+	ParamFormat    string        `yaml:"param_format"`
+	StartDate      string        `yaml:"start_date"`
+	StartParam     string        `yaml:"start_param"`
+	EndParam       string        `yaml:"end_param"`
+	LookbackWindow time.Duration `yaml:"lookback_window"`
+	Step           time.Duration `yaml:"step"`
+}
+
+// Parent makes a stream a substream, see httpstream.Parent
+type Parent struct {
+	Stream      string `yaml:"stream"`
+	Key         string `yaml:"key"`
+	RecordField string `yaml:"record_field"`
+}
+
+// Parse decodes and validates a YAML or JSON manifest, the error lists every problem with its line
+func Parse(b []byte) (*Manifest, error) {
+	m, root, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if errs := m.validate(newLines(root)); len(errs) > 0 {
+		return nil, errs
+	}
+	return m, nil
+}
+
+// ParseFile parses the manifest at path
+func ParseFile(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Validate reports every problem of a manifest, nil if there is none
+// The error is an Errors, each Error holds the line of the problem
+func Validate(b []byte) error {
+	_, err := Parse(b)
+	return err
+}
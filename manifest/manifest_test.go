@@ -0,0 +1,187 @@
+package manifest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+)
+
+const testManifest = `
+version: "1"
+base_url: "{{ .config.base_url }}"
+spec:
+  title: Test
+  required: [base_url]
+  properties:
+    base_url: {type: string}
+auth:
+  methods: [api_key]
+  api_key_header: X-API-Key
+streams:
+  - name: users
+    path: /users
+    record_selector: data
+    primary_key: [id]
+    paginator:
+      type: page_number
+      page_size: 2
+    incremental:
+      cursor_field: updated_at
+      start_param: since
+    schema:
+      type: object
+      properties:
+        id: {type: integer}
+        updated_at: {type: string}
+  - name: orders
+    path: /users/{{ .parent.id }}/orders.csv
+    decoder:
+      type: csv
+      columns: {Order ID: order_id}
+    parent: {stream: users, key: id, record_field: user_id}
+`
+
+func TestSource(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, "bad key", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/users":
+			users := []map[string]interface{}{{"id": 1, "updated_at": "a"}, {"id": 2, "updated_at": "c"}}
+			if r.URL.Query().Get("page") == "2" {
+				users = []map[string]interface{}{{"id": 3, "updated_at": "b"}}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": users})
+		default:
+			w.Write([]byte("Order ID,amount\n" + strings.TrimPrefix(r.URL.Path, "/users/")[:1] + "0,5\n"))
+		}
+	}))
+	defer api.Close()
+
+	m, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := m.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := airbytetest.Spec(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := out.Spec.ConnectionSpecification
+	if !reflect.DeepEqual(conn.Required, []airbyte.PropertyName{"base_url", "credentials"}) ||
+		len(conn.Properties.Properties["credentials"].OneOf) != 1 {
+		t.Fatalf("expected a base_url and an api key credentials property, got %+v", conn)
+	}
+
+	config := map[string]interface{}{
+		"base_url":    api.URL,
+		"credentials": map[string]string{"auth_type": "api_key", "api_key": "secret"},
+	}
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := disc.Catalog.Streams[0]
+	if len(users.SupportedSyncModes) != 2 || users.JSONSchema.Properties["id"].Type[0] != airbyte.Integer ||
+		users.SourceDefinedPrimaryKey[0][0] != "id" {
+		t.Fatalf("unexpected users stream %+v", users)
+	}
+
+	out, err = airbytetest.Read(src, config, airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["users"]) != 3 {
+		t.Fatalf("expected 3 users over 2 pages, got %d", len(out.Records["users"]))
+	}
+	var order map[string]interface{}
+	if err := out.Records["orders"][2].Unmarshal(&order); err != nil {
+		t.Fatal(err)
+	}
+	if order["order_id"] != "30" || order["user_id"] != float64(3) {
+		t.Fatalf("expected the order of user 3, got %v", order)
+	}
+	var st map[string]map[string]interface{}
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st["users"]["cursor"] != "c" {
+		t.Fatalf("expected the users cursor at c, got %v", st)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate([]byte(`version: "2"
+base_url: https://api.example.com
+spec:
+  required: [missing]
+streams:
+  - name: users
+    path: /users/{{ .config.id
+    paginator:
+      type: offset
+  - name: users
+    parent: {stream: payments}
+    decoder: {type: protobuf}
+check: {stream: nope}
+`))
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+	// missing fields are reported at the line of their parent
+	want := []Error{
+		{Line: 1, Path: "version"},
+		{Line: 4, Path: "spec.required[0]"},
+		{Line: 7, Path: "streams[0].path"},
+		{Line: 8, Path: "streams[0].paginator.page_size"},
+		{Line: 10, Path: "streams[1].name"},
+		{Line: 11, Path: "streams[1].parent.key"},
+		{Line: 11, Path: "streams[1].parent.stream"},
+		{Line: 12, Path: "streams[1].decoder.type"},
+		{Line: 13, Path: "check.stream"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got\n%v", len(want), err)
+	}
+	for i, e := range errs {
+		if e.Line != want[i].Line || e.Path != want[i].Path {
+			t.Errorf("expected an error for %s at line %d, got %v", want[i].Path, want[i].Line, e)
+		}
+	}
+	if !strings.Contains(err.Error(), "line 11: streams[1].parent.stream: parent stream payments is not declared") {
+		t.Errorf("expected the undeclared parent, got\n%v", err)
+	}
+
+	// unknown fields and yaml errors have lines as well
+	err = Validate([]byte("base_url: x\nstreams:\n  - name: a\n    pagination: {}\n"))
+	if errs, ok := err.(Errors); !ok || errs[0].Line != 4 || !strings.Contains(errs[0].Message, "pagination") {
+		t.Errorf("expected the unknown field at line 4, got %v", err)
+	}
+	err = Validate([]byte("base_url: x\nstreams: [\n"))
+	if errs, ok := err.(Errors); !ok || errs[0].Line == 0 {
+		t.Errorf("expected a syntax error with a line, got %v", err)
+	}
+}
+
+func TestExampleManifest(t *testing.T) {
+	m, err := ParseFile("../examples/manifestsource/manifest.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Source(); err != nil {
+		t.Fatal(err)
+	}
+}
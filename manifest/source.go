@@ -0,0 +1,313 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/httpstream"
+	"github.com/bitstrapped/airbyte/httpstream/auth"
+	"github.com/bitstrapped/airbyte/httpstream/retry"
+	"gopkg.in/yaml.v3"
+)
+
+// cursorFormats are the names of common cursor formats, other formats are Go time layouts
+var cursorFormats = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05",
+}
+
+// Source builds the source the manifest declares, it plugs into airbyte.NewSourceRunner like any other source
+//
+// Example usage
+//
+//	m, err := manifest.ParseFile("manifest.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	src, err := m.Source()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = airbyte.NewSourceRunner(src, os.Stdout).Start()
+func (m *Manifest) Source() (httpstream.Source, error) {
+	props, err := properties(&m.Spec.Properties)
+	if err != nil {
+		return httpstream.Source{}, fmt.Errorf("spec.properties: %w", err)
+	}
+	if props == nil {
+		props = map[string]airbyte.PropertySpec{}
+	}
+
+	src := httpstream.Source{
+		BaseURL:     m.BaseURL,
+		CheckStream: m.Check.Stream,
+	}
+	required := append([]string(nil), m.Spec.Required...)
+
+	if a := m.Auth; a != nil {
+		methods := []auth.Method{auth.MethodAPIKey, auth.MethodBearer, auth.MethodBasic,
+			auth.MethodClientCredentials, auth.MethodRefreshToken}
+		if len(a.Methods) > 0 {
+			methods = methods[:0]
+			for _, method := range a.Methods {
+				methods = append(methods, auth.Method(method))
+			}
+		}
+		title := a.Title
+		if title == "" {
+			title = "Authentication"
+		}
+		creds, err := auth.Spec(title, methods...)
+		if err != nil {
+			return httpstream.Source{}, fmt.Errorf("auth: %w", err)
+		}
+		props[a.credentialsKey()] = creds
+		if !contains(required, a.credentialsKey()) {
+			required = append(required, a.credentialsKey())
+		}
+
+		src.CredentialsKey = a.credentialsKey()
+		src.Auth = &auth.Options{
+			APIKeyHeader: a.APIKeyHeader,
+			APIKeyParam:  a.APIKeyParam,
+			APIKeyPrefix: a.APIKeyPrefix,
+			TokenURL:     a.TokenURL,
+			Scopes:       a.Scopes,
+		}
+	}
+
+	if r := m.Retry; r != nil {
+		src.Retry = &retry.Options{
+			MinBackoff:    r.MinBackoff,
+			MaxBackoff:    r.MaxBackoff,
+			MaxRetryAfter: r.MaxRetryAfter,
+		}
+		if r.MaxRetries != nil {
+			src.Retry.MaxRetries = *r.MaxRetries
+			if *r.MaxRetries == 0 {
+				src.Retry.MaxRetries = -1
+			}
+		}
+		if r.RequestsPerSecond > 0 {
+			burst := r.Burst
+			if burst <= 0 {
+				burst = 1
+			}
+			src.Retry.Limiter = retry.NewLimiter(r.RequestsPerSecond, burst)
+		}
+	}
+
+	incremental := false
+	for i, st := range m.Streams {
+		hs, err := st.stream()
+		if err != nil {
+			return httpstream.Source{}, fmt.Errorf("streams[%d]: %w", i, err)
+		}
+		incremental = incremental || hs.Incremental != nil
+		src.Streams = append(src.Streams, hs)
+	}
+
+	spec := &airbyte.ConnectorSpecification{
+		DocumentationURL:    m.Spec.DocumentationURL,
+		SupportsIncremental: incremental,
+		SupportedDestinationSyncModes: []airbyte.DestinationSyncMode{
+			airbyte.DestinationSyncModeOverwrite,
+			airbyte.DestinationSyncModeAppend,
+		},
+		ConnectionSpecification: airbyte.ConnectionSpecification{
+			Title:       m.Spec.Title,
+			Description: m.Spec.Description,
+			Type:        "object",
+			Properties: airbyte.Properties{
+				Properties: map[airbyte.PropertyName]airbyte.PropertySpec{},
+			},
+		},
+	}
+	for name, p := range props {
+		spec.ConnectionSpecification.Properties.Properties[airbyte.PropertyName(name)] = p
+	}
+	for _, name := range required {
+		spec.ConnectionSpecification.Required = append(spec.ConnectionSpecification.Required, airbyte.PropertyName(name))
+	}
+	src.Specification = spec
+
+	return src, nil
+}
+
+func (a *Auth) credentialsKey() string {
+	if a.CredentialsKey == "" {
+		return "credentials"
+	}
+	return a.CredentialsKey
+}
+
+func (st Stream) stream() (httpstream.Stream, error) {
+	hs := httpstream.Stream{
+		Name:            st.Name,
+		Namespace:       st.Namespace,
+		Method:          strings.ToUpper(st.Method),
+		Path:            st.Path,
+		Params:          st.Params,
+		Headers:         st.Headers,
+		Body:            st.Body,
+		RecordSelector:  st.RecordSelector,
+		CheckpointPages: st.CheckpointPages,
+	}
+	for _, key := range st.PrimaryKey {
+		hs.PrimaryKey = append(hs.PrimaryKey, strings.Split(key, "."))
+	}
+
+	var err error
+	if hs.Schema, err = schema(&st.Schema); err != nil {
+		return hs, fmt.Errorf("schema: %w", err)
+	}
+
+	if d := st.Decoder; d != nil {
+		switch d.Type {
+		case DecoderJSON:
+			hs.Decoder = httpstream.JSONDecoder{RecordPath: d.RecordPath}
+		case DecoderJSONLines:
+			hs.Decoder = httpstream.JSONLinesDecoder{}
+		case DecoderCSV:
+			c := httpstream.CSVDecoder{Header: d.Header, Columns: d.Columns}
+			for _, r := range d.Delimiter {
+				c.Comma = r
+			}
+			hs.Decoder = c
+		case DecoderXML:
+			hs.Decoder = httpstream.XMLDecoder{ElementPath: d.ElementPath}
+		default:
+			return hs, fmt.Errorf("unknown decoder %q", d.Type)
+		}
+	}
+
+	if pg := st.Paginator; pg != nil {
+		switch pg.Type {
+		case PaginatorOffset:
+			hs.Paginator = httpstream.OffsetPaginator{
+				PageSize:      pg.PageSize,
+				LimitParam:    pg.LimitParam,
+				OffsetParam:   pg.OffsetParam,
+				TotalSelector: pg.TotalSelector,
+			}
+		case PaginatorPageNumber:
+			hs.Paginator = httpstream.PageNumberPaginator{
+				PageSize:           pg.PageSize,
+				PageParam:          pg.PageParam,
+				SizeParam:          pg.SizeParam,
+				ZeroBased:          pg.ZeroBased,
+				TotalPagesSelector: pg.TotalPagesSelector,
+			}
+		case PaginatorCursor:
+			hs.Paginator = httpstream.CursorPaginator{
+				CursorSelector:  pg.CursorSelector,
+				Param:           pg.Param,
+				Header:          pg.Header,
+				HasMoreSelector: pg.HasMoreSelector,
+				PageSize:        pg.PageSize,
+				SizeParam:       pg.SizeParam,
+			}
+		case PaginatorLinkHeader:
+			hs.Paginator = httpstream.LinkHeaderPaginator{Rel: pg.Rel}
+		case PaginatorNextURL:
+			hs.Paginator = httpstream.NextURLPaginator{NextURLSelector: pg.NextURLSelector}
+		default:
+			return hs, fmt.Errorf("unknown paginator %q", pg.Type)
+		}
+	}
+
+	if inc := st.Incremental; inc != nil {
+		hs.Incremental = &httpstream.Incremental{
+			CursorField:    inc.CursorField,
+			CursorFormat:   cursorFormat(inc.CursorFormat),
+			ParamFormat:    cursorFormat(inc.ParamFormat),
+			StartDate:      inc.StartDate,
+			StartParam:     inc.StartParam,
+			EndParam:       inc.EndParam,
+			LookbackWindow: inc.LookbackWindow,
+			Step:           inc.Step,
+		}
+	}
+
+	if p := st.Parent; p != nil {
+		hs.Parent = &httpstream.Parent{Stream: p.Stream, Key: p.Key, RecordField: p.RecordField}
+	}
+	return hs, nil
+}
+
+func cursorFormat(format string) string {
+	if layout, ok := cursorFormats[strings.ToLower(format)]; ok {
+		return layout
+	}
+	return format
+}
+
+// properties decodes the json schemas of config properties
+func properties(n *yaml.Node) (map[string]airbyte.PropertySpec, error) {
+	if n.Kind == 0 {
+		return nil, nil
+	}
+	var props map[string]airbyte.PropertySpec
+	return props, decodeSchema(n, &props)
+}
+
+// schema decodes the json schema of a stream, either an object schema or just its properties
+func schema(n *yaml.Node) (*airbyte.Properties, error) {
+	if n.Kind == 0 {
+		return nil, nil
+	}
+	var props airbyte.Properties
+	if err := decodeSchema(n, &props); err != nil {
+		return nil, err
+	}
+	if props.Properties == nil {
+		return nil, fmt.Errorf("schema has no properties")
+	}
+	return &props, nil
+}
+
+// decodeSchema decodes a json schema into v through json, so the json tags of the airbyte types apply
+// JSON Schema allows a single type instead of a list, which airbyte.PropertyType doesn't
+func decodeSchema(n *yaml.Node, v interface{}) error {
+	var raw interface{}
+	if err := n.Decode(&raw); err != nil {
+		return err
+	}
+	b, err := json.Marshal(typeLists(raw))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// typeLists turns every "type: string" of a schema into "type: [string]"
+func typeLists(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for k, e := range tv {
+			if s, ok := e.(string); ok && k == "type" {
+				tv[k] = []interface{}{s}
+				continue
+			}
+			tv[k] = typeLists(e)
+		}
+	case []interface{}:
+		for i, e := range tv {
+			tv[i] = typeLists(e)
+		}
+	}
+	return v
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,333 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/bitstrapped/airbyte/httpstream/auth"
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a single problem of a manifest
+type Error struct {
+	// Line is the line of the problem, 0 if it's unknown
+	Line int
+	// Path is the path of the manifest field, e.g. "streams[1].paginator.type"
+	Path    string
+	Message string
+}
+
+func (e Error) Error() string {
+	s := e.Message
+	if e.Path != "" {
+		s = e.Path + ": " + s
+	}
+	if e.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", e.Line, s)
+	}
+	return s
+}
+
+// Errors holds every problem of a manifest ordered by line
+type Errors []Error
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// yamlLine matches the line yaml puts in front of its errors, e.g. "yaml: line 3: did not find expected key"
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// decode decodes the manifest, unknown fields are errors so typos don't go unnoticed
+func decode(b []byte) (*Manifest, *yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, nil, yamlErrors(err)
+	}
+	if len(root.Content) == 0 {
+		return nil, nil, Errors{{Message: "manifest is empty"}}
+	}
+
+	var m Manifest
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil && err != io.EOF {
+		return nil, nil, yamlErrors(err)
+	}
+	return &m, &root, nil
+}
+
+// yamlErrors turns the errors of the yaml package into Errors
+func yamlErrors(err error) Errors {
+	msgs := []string{err.Error()}
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		msgs = te.Errors
+	}
+
+	errs := make(Errors, 0, len(msgs))
+	for _, msg := range msgs {
+		e := Error{Message: msg}
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Message = m[2]
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// lines maps the path of every manifest field to its line
+type lines map[string]int
+
+func newLines(root *yaml.Node) lines {
+	l := lines{"": 1}
+	l.walk(root, "")
+	return l
+}
+
+func (l lines) walk(n *yaml.Node, path string) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			l.walk(c, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			p := n.Content[i].Value
+			if path != "" {
+				p = path + "." + p
+			}
+			l[p] = n.Content[i].Line
+			l.walk(n.Content[i+1], p)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			l[p] = c.Line
+			l.walk(c, p)
+		}
+	case yaml.AliasNode:
+		if n.Alias != nil {
+			l.walk(n.Alias, path)
+		}
+	}
+}
+
+// line returns the line of path, or of its closest parent if path isn't in the manifest
+func (l lines) line(path string) int {
+	for {
+		if line, ok := l[path]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			return l[""]
+		}
+		path = path[:i]
+	}
+}
+
+// validator collects the problems of a manifest
+type validator struct {
+	lines lines
+	errs  Errors
+}
+
+func (v *validator) errorf(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, Error{Line: v.lines.line(path), Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// template reports templates which don't parse
+func (v *validator) template(path string, text string) {
+	if _, err := template.New("").Parse(text); err != nil {
+		v.errorf(path, "%v", err)
+	}
+}
+
+func (m *Manifest) validate(l lines) Errors {
+	v := &validator{lines: l}
+
+	if m.Version != "" && m.Version != "1" {
+		v.errorf("version", "unsupported version %q, only version 1 is supported", m.Version)
+	}
+	if m.BaseURL == "" {
+		v.errorf("base_url", "base_url is required")
+	}
+	v.template("base_url", m.BaseURL)
+
+	props, err := properties(&m.Spec.Properties)
+	if err != nil {
+		v.errorf("spec.properties", "%v", err)
+	}
+	if m.Auth != nil {
+		for i, method := range m.Auth.Methods {
+			if _, err := auth.Spec("", auth.Method(method)); err != nil {
+				v.errorf(fmt.Sprintf("auth.methods[%d]", i), "unknown auth method %q", method)
+			}
+		}
+		if m.Auth.APIKeyHeader != "" && m.Auth.APIKeyParam != "" {
+			v.errorf("auth", "set either api_key_header or api_key_param")
+		}
+	}
+	for i, name := range m.Spec.Required {
+		if _, ok := props[name]; !ok && (m.Auth == nil || name != m.Auth.credentialsKey()) {
+			v.errorf(fmt.Sprintf("spec.required[%d]", i), "required property %s is not declared", name)
+		}
+	}
+
+	if len(m.Streams) == 0 {
+		v.errorf("streams", "at least one stream is required")
+	}
+	index := map[string]int{}
+	for i, st := range m.Streams {
+		p := fmt.Sprintf("streams[%d]", i)
+		if st.Name == "" {
+			v.errorf(p, "name is required")
+		} else if j, ok := index[st.Name]; ok {
+			v.errorf(p+".name", "stream %s is declared twice, first in streams[%d]", st.Name, j)
+		} else {
+			index[st.Name] = i
+		}
+		st.validate(v, p)
+	}
+
+	if m.Check.Stream != "" {
+		if _, ok := index[m.Check.Stream]; !ok {
+			v.errorf("check.stream", "stream %s is not declared", m.Check.Stream)
+		}
+	}
+	for i, st := range m.Streams {
+		if st.Parent == nil || st.Parent.Stream == "" {
+			continue
+		}
+		p := fmt.Sprintf("streams[%d].parent.stream", i)
+		if _, ok := index[st.Parent.Stream]; !ok {
+			v.errorf(p, "parent stream %s is not declared", st.Parent.Stream)
+			continue
+		}
+		// follow the parents, there are only as many ancestors as there are streams unless there's a cycle
+		parent := st.Parent
+		for n := 0; parent != nil; n++ {
+			j, ok := index[parent.Stream]
+			if !ok {
+				break
+			}
+			if n == len(m.Streams) {
+				v.errorf(p, "parent streams form a cycle")
+				break
+			}
+			parent = m.Streams[j].Parent
+		}
+	}
+
+	// report the problems top down, like a compiler
+	sort.SliceStable(v.errs, func(i, j int) bool { return v.errs[i].Line < v.errs[j].Line })
+	return v.errs
+}
+
+func (st Stream) validate(v *validator, p string) {
+	v.template(p+".path", st.Path)
+	v.template(p+".body", st.Body)
+	for _, k := range sortedKeys(st.Params) {
+		v.template(p+".params."+k, st.Params[k])
+	}
+	for _, k := range sortedKeys(st.Headers) {
+		v.template(p+".headers."+k, st.Headers[k])
+	}
+	for i, key := range st.PrimaryKey {
+		if key == "" {
+			v.errorf(fmt.Sprintf("%s.primary_key[%d]", p, i), "primary key field can't be empty")
+		}
+	}
+	if _, err := schema(&st.Schema); err != nil {
+		v.errorf(p+".schema", "%v", err)
+	}
+
+	if d := st.Decoder; d != nil {
+		switch d.Type {
+		case DecoderJSON, DecoderJSONLines:
+		case DecoderCSV:
+			if utf8.RuneCountInString(d.Delimiter) > 1 {
+				v.errorf(p+".decoder.delimiter", "delimiter has to be a single character")
+			}
+		case DecoderXML:
+			if d.ElementPath == "" {
+				v.errorf(p+".decoder.element_path", "element_path is required for xml")
+			}
+		default:
+			v.errorf(p+".decoder.type", "unknown decoder %q, use json, jsonl, csv or xml", d.Type)
+		}
+		if st.RecordSelector != "" {
+			v.errorf(p+".record_selector", "record_selector is ignored with a decoder, set the record_path of the decoder")
+		}
+	}
+
+	if pg := st.Paginator; pg != nil {
+		switch pg.Type {
+		case PaginatorOffset:
+			if pg.PageSize <= 0 {
+				v.errorf(p+".paginator.page_size", "page_size is required for offset pagination")
+			}
+		case PaginatorCursor:
+			if pg.CursorSelector == "" {
+				v.errorf(p+".paginator.cursor_selector", "cursor_selector is required for cursor pagination")
+			}
+		case PaginatorNextURL:
+			if pg.NextURLSelector == "" {
+				v.errorf(p+".paginator.next_url_selector", "next_url_selector is required for next_url pagination")
+			}
+		case PaginatorPageNumber, PaginatorLinkHeader:
+		default:
+			v.errorf(p+".paginator.type", "unknown paginator %q, use offset, page_number, cursor, link_header or next_url", pg.Type)
+		}
+	}
+
+	if inc := st.Incremental; inc != nil {
+		if inc.CursorField == "" {
+			v.errorf(p+".incremental.cursor_field", "cursor_field is required")
+		}
+		if inc.CursorFormat == "" && (inc.LookbackWindow != 0 || inc.Step != 0) {
+			v.errorf(p+".incremental.cursor_format", "cursor_format is required for a lookback_window or a step")
+		}
+		if inc.LookbackWindow < 0 {
+			v.errorf(p+".incremental.lookback_window", "lookback_window can't be negative")
+		}
+		if inc.Step < 0 {
+			v.errorf(p+".incremental.step", "step can't be negative")
+		}
+		v.template(p+".incremental.start_date", inc.StartDate)
+	}
+
+	if pr := st.Parent; pr != nil {
+		if pr.Stream == "" {
+			v.errorf(p+".parent.stream", "parent stream is required")
+		} else if pr.Stream == st.Name {
+			v.errorf(p+".parent.stream", "stream can't be its own parent")
+		}
+		if pr.Key == "" {
+			v.errorf(p+".parent.key", "parent key is required")
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package airbyte
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MessageHandler receives the messages decoded by ReadMessages, one func per message type
+// A nil func skips messages of that type
+type MessageHandler struct {
+	// Record receives the raw record data along with its stream
+	Record func(data json.RawMessage, streamName string, namespace string, emittedAt int64) error
+	// State receives the raw state data
+	State func(data json.RawMessage) error
+	// Log receives log messages
+	Log func(level LogLevel, s string) error
+	// ConnectionStatus receives the outcome of a check
+	ConnectionStatus func(succeeded bool) error
+	// Catalog receives the discovered catalog
+	Catalog func(catalog *Catalog) error
+	// Spec receives the connector specification
+	Spec func(spec *ConnectorSpecification) error
+	// Config receives the raw connector config of config updates
+	Config func(config json.RawMessage) error
+}
+
+// ReadMessages reads the newline delimited airbyte messages a connector writes, e.g. the output of a SourceRunner,
+// and passes every message to the matching handler func
+// It stops at the first invalid message or handler error
+func ReadMessages(r io.Reader, h MessageHandler) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if len(bytes.TrimSpace(b)) > 0 {
+			var m message
+			if jerr := json.Unmarshal(b, &m); jerr != nil {
+				return fmt.Errorf("message on line %d: %w", line, jerr)
+			}
+			if herr := h.handle(&m); herr != nil {
+				return herr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (h MessageHandler) handle(m *message) error {
+	switch m.Type {
+	case msgTypeRecord:
+		if h.Record != nil {
+			data, _ := m.record.Data.(json.RawMessage)
+			return h.Record(data, m.record.Stream, m.record.Namespace, m.record.EmittedAt)
+		}
+	case msgTypeState:
+		if h.State != nil {
+			data, _ := m.state.Data.(json.RawMessage)
+			return h.State(data)
+		}
+	case msgTypeLog:
+		if h.Log != nil {
+			return h.Log(m.logMessage.Level, m.logMessage.Message)
+		}
+	case msgTypeConnectionStat:
+		if h.ConnectionStatus != nil && m.connectionStatus != nil {
+			return h.ConnectionStatus(m.connectionStatus.Status == checkStatusSuccess)
+		}
+	case msgTypeCatalog:
+		if h.Catalog != nil && m.Catalog != nil {
+			return h.Catalog(m.Catalog)
+		}
+	case msgTypeSpec:
+		if h.Spec != nil && m.ConnectorSpecification != nil {
+			return h.Spec(m.ConnectorSpecification)
+		}
+	case msgTypeControl:
+		if h.Config != nil {
+			config, _ := m.control.ConnectorConfig.Config.(json.RawMessage)
+			return h.Config(config)
+		}
+	}
+
+	return nil
+}
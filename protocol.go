@@ -16,6 +16,8 @@ const (
 	cmdCheck    cmd = "check"
 	cmdDiscover cmd = "discover"
 	cmdRead     cmd = "read"
+	cmdBench    cmd = "bench"
+	cmdWrite    cmd = "write"
 )
 
 type msgType string
@@ -27,6 +29,7 @@ const (
 	msgTypeConnectionStat msgType = "CONNECTION_STATUS"
 	msgTypeCatalog        msgType = "CATALOG"
 	msgTypeSpec           msgType = "SPEC"
+	msgTypeControl        msgType = "CONTROL"
 )
 
 var errInvalidTypePayload = errors.New("message type and payload are invalid")
@@ -39,39 +42,116 @@ type message struct {
 	*ConnectorSpecification `json:"spec,omitempty"`
 	*connectionStatus       `json:"connectionStatus,omitempty"`
 	*Catalog                `json:"catalog,omitempty"`
+	*control                `json:"control,omitempty"`
 }
 
 // message MarshalJSON is a custom marshaller which validates the messageType with the sub-struct
 func (m *message) MarshalJSON() ([]byte, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	type m2 message
+	return json.Marshal(m2(*m))
+}
+
+// message UnmarshalJSON is the counterpart of MarshalJSON, record and state data are kept as json.RawMessage
+func (m *message) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Type   msgType `json:"type"`
+		Record *struct {
+			EmittedAt int64           `json:"emitted_at"`
+			Namespace string          `json:"namespace"`
+			Data      json.RawMessage `json:"data"`
+			Stream    string          `json:"stream"`
+		} `json:"record"`
+		State *struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"state"`
+		Log              *logMessage             `json:"log"`
+		Spec             *ConnectorSpecification `json:"spec"`
+		ConnectionStatus *connectionStatus       `json:"connectionStatus"`
+		Catalog          *Catalog                `json:"catalog"`
+		Control          *struct {
+			Type            controlType `json:"type"`
+			EmittedAt       float64     `json:"emitted_at"`
+			ConnectorConfig *struct {
+				Config json.RawMessage `json:"config"`
+			} `json:"connectorConfig"`
+		} `json:"control"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*m = message{
+		Type:                   raw.Type,
+		logMessage:             raw.Log,
+		ConnectorSpecification: raw.Spec,
+		connectionStatus:       raw.ConnectionStatus,
+		Catalog:                raw.Catalog,
+	}
+	if raw.Record != nil {
+		m.record = &record{
+			EmittedAt: raw.Record.EmittedAt,
+			Namespace: raw.Record.Namespace,
+			Data:      raw.Record.Data,
+			Stream:    raw.Record.Stream,
+		}
+	}
+	if raw.State != nil {
+		m.state = &state{
+			Data: raw.State.Data,
+		}
+	}
+	if raw.Control != nil {
+		m.control = &control{
+			Type:      raw.Control.Type,
+			EmittedAt: raw.Control.EmittedAt,
+		}
+		if raw.Control.ConnectorConfig != nil {
+			m.control.ConnectorConfig = &connectorConfig{
+				Config: raw.Control.ConnectorConfig.Config,
+			}
+		}
+	}
+
+	return m.validate()
+}
+
+// validate checks that the message carries exactly the payload of its type
+func (m *message) validate() error {
+	var hasPayload bool
 	switch m.Type {
 	case msgTypeRecord:
-		if m.record == nil ||
-			m.state != nil ||
-			m.logMessage != nil ||
-			m.connectionStatus != nil ||
-			m.Catalog != nil {
-			return nil, errInvalidTypePayload
-		}
+		hasPayload = m.record != nil
 	case msgTypeState:
-		if m.state == nil ||
-			m.record != nil ||
-			m.logMessage != nil ||
-			m.connectionStatus != nil ||
-			m.Catalog != nil {
-			return nil, errInvalidTypePayload
-		}
+		hasPayload = m.state != nil
 	case msgTypeLog:
-		if m.logMessage == nil ||
-			m.record != nil ||
-			m.state != nil ||
-			m.connectionStatus != nil ||
-			m.Catalog != nil {
-			return nil, errInvalidTypePayload
+		hasPayload = m.logMessage != nil
+	case msgTypeSpec:
+		hasPayload = m.ConnectorSpecification != nil
+	case msgTypeConnectionStat:
+		hasPayload = m.connectionStatus != nil
+	case msgTypeCatalog:
+		hasPayload = m.Catalog != nil
+	case msgTypeControl:
+		// connector config is the only control message so far
+		hasPayload = m.control != nil && m.control.Type == controlTypeConnectorConfig && m.control.ConnectorConfig != nil
+	}
+
+	payloads := 0
+	for _, set := range []bool{m.record != nil, m.state != nil, m.logMessage != nil,
+		m.ConnectorSpecification != nil, m.connectionStatus != nil, m.Catalog != nil, m.control != nil} {
+		if set {
+			payloads++
 		}
 	}
 
-	type m2 message
-	return json.Marshal(m2(*m))
+	if !hasPayload || payloads != 1 {
+		return errInvalidTypePayload
+	}
+	return nil
 }
 
 // write emits data outbound from your src/destination to airbyte workers
@@ -92,6 +172,23 @@ type state struct {
 	Data interface{} `json:"data"`
 }
 
+type controlType string
+
+const (
+	controlTypeConnectorConfig controlType = "CONNECTOR_CONFIG"
+)
+
+// control tells the platform to change something about the connection, like persisting refreshed credentials
+type control struct {
+	Type            controlType      `json:"type"`
+	EmittedAt       float64          `json:"emitted_at"`
+	ConnectorConfig *connectorConfig `json:"connectorConfig,omitempty"`
+}
+
+type connectorConfig struct {
+	Config interface{} `json:"config"`
+}
+
 // LogLevel defines the log levels that can be emitted with airbyte logs
 type LogLevel string
 
@@ -170,6 +267,8 @@ var (
 	DestinationSyncModeAppend DestinationSyncMode = "append"
 	// DestinationSyncModeOverwrite is used to indicate the destination should overwrite data
 	DestinationSyncModeOverwrite DestinationSyncMode = "overwrite"
+	// DestinationSyncModeAppendDedup is used to indicate the destination should keep the latest record per primary key
+	DestinationSyncModeAppendDedup DestinationSyncMode = "append_dedup"
 )
 
 // ConnectorSpecification is used to define the connector wide settings. Every connection using your connector will comply to these settings
@@ -186,8 +285,10 @@ type ConnectorSpecification struct {
 // https://json-schema.org/learn/getting-started-step-by-step.html
 
 // Properties defines the property map which is used to define any single "field name" along with its specification
+// Required lists the properties which have to be given, a ConnectionSpecification has its own Required
 type Properties struct {
 	Properties map[PropertyName]PropertySpec `json:"properties"`
+	Required   []PropertyName                `json:"required,omitempty"`
 }
 
 // PropertyName is a alias for a string to make it clear to the user that the "key" in the map is the name of the property
@@ -211,6 +312,7 @@ const (
 	Integer PropType = "integer"
 	Object  PropType = "object"
 	Array   PropType = "array"
+	Boolean PropType = "boolean"
 	Null    PropType = "null"
 )
 
@@ -236,13 +338,78 @@ type PropertyType struct {
 	Type        []PropType      `json:"type,omitempty"`
 	AirbyteType AirbytePropType `json:"airbyte_type,omitempty"`
 }
+
+// PropertySpec is the JSON Schema of a single property
 type PropertySpec struct {
 	Description  string `json:"description"`
 	PropertyType `json:",omitempty"`
-	Examples     []string                      `json:"examples,omitempty"`
-	Items        map[string]interface{}        `json:"items,omitempty"`
+	Examples     []interface{}                 `json:"examples,omitempty"`
+	Items        *PropertySpec                 `json:"items,omitempty"`
 	Properties   map[PropertyName]PropertySpec `json:"properties,omitempty"`
 	IsSecret     bool                          `json:"airbyte_secret,omitempty"`
+	// The following fields are airbyte spec keywords the UI uses to render the connector form
+	Title      string      `json:"title,omitempty"`
+	Order      *int        `json:"order,omitempty"`
+	Group      string      `json:"group,omitempty"`
+	IsHidden   bool        `json:"airbyte_hidden,omitempty"`
+	AlwaysShow bool        `json:"always_show,omitempty"`
+	Multiline  bool        `json:"multiline,omitempty"`
+	Const      interface{} `json:"const,omitempty"`
+	// The following fields are JSON Schema validation keywords
+	Format               FormatType            `json:"format,omitempty"`
+	Enum                 []interface{}         `json:"enum,omitempty"`
+	Default              interface{}           `json:"default,omitempty"`
+	Required             []PropertyName        `json:"required,omitempty"`
+	Pattern              string                `json:"pattern,omitempty"`
+	Minimum              *float64              `json:"minimum,omitempty"`
+	ExclusiveMinimum     *bool                 `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64              `json:"maximum,omitempty"`
+	ExclusiveMaximum     *bool                 `json:"exclusiveMaximum,omitempty"`
+	MultipleOf           float64               `json:"multipleOf,omitempty"`
+	MinLength            *uint64               `json:"minLength,omitempty"`
+	MaxLength            *uint64               `json:"maxLength,omitempty"`
+	MinItems             *uint64               `json:"minItems,omitempty"`
+	MaxItems             *uint64               `json:"maxItems,omitempty"`
+	UniqueItems          bool                  `json:"uniqueItems,omitempty"`
+	MinProperties        *uint64               `json:"minProperties,omitempty"`
+	MaxProperties        *uint64               `json:"maxProperties,omitempty"`
+	OneOf                []PropertySpec        `json:"oneOf,omitempty"`
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties,omitempty"`
+	// The following fields are JSON Schema annotations
+	ReadOnly   bool `json:"readOnly,omitempty"`
+	WriteOnly  bool `json:"writeOnly,omitempty"`
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// AdditionalProperties is either a boolean which allows or forbids unknown properties of an object,
+// or the spec every unknown property has to match (this is how maps are described)
+type AdditionalProperties struct {
+	Allowed bool
+	Spec    *PropertySpec
+}
+
+// MarshalJSON writes the spec if it is set, otherwise the boolean
+func (a AdditionalProperties) MarshalJSON() ([]byte, error) {
+	if a.Spec != nil {
+		return json.Marshal(a.Spec)
+	}
+	return json.Marshal(a.Allowed)
+}
+
+// UnmarshalJSON accepts either a boolean or a spec
+func (a *AdditionalProperties) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.Allowed); err == nil {
+		a.Spec = nil
+		return nil
+	}
+
+	var spec PropertySpec
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return err
+	}
+	a.Allowed = true
+	a.Spec = &spec
+	return nil
 }
 
 // LogWriter is exported for documentation purposes - only use this through LogTracker or MessageTracker
@@ -255,39 +422,73 @@ type StateWriter func(v interface{}) error
 // RecordWriter is exported for documentation purposes - only use this through MessageTracker
 type RecordWriter func(v interface{}, streamName string, namespace string) error
 
+// ConfigWriter is exported for documentation purposes - only use this through LogTracker or MessageTracker
+type ConfigWriter func(config interface{}) error
+
 func newLogWriter(w io.Writer) LogWriter {
 	return func(lvl LogLevel, s string) error {
-		return write(w, &message{
-			Type: msgTypeLog,
-			logMessage: &logMessage{
-				Level:   lvl,
-				Message: s,
-			},
-		})
+		return write(w, newLogMessage(lvl, s))
 	}
-
 }
+
 func newStateWriter(w io.Writer) StateWriter {
 	return func(s interface{}) error {
-		return write(w, &message{
-			Type: msgTypeState,
-			state: &state{
-				Data: s,
-			},
-		})
+		return write(w, newStateMessage(s))
 	}
 }
 
 func newRecordWriter(w io.Writer) RecordWriter {
 	return func(s interface{}, stream string, namespace string) error {
-		return write(w, &message{
-			Type: msgTypeRecord,
-			record: &record{
-				EmittedAt: time.Now().UnixMilli(),
-				Data:      s,
-				Namespace: namespace,
-				Stream:    stream,
+		return write(w, newRecordMessage(s, stream, namespace))
+	}
+}
+
+func newConfigWriter(w io.Writer) ConfigWriter {
+	return func(config interface{}) error {
+		return write(w, newConfigMessage(config))
+	}
+}
+
+func newConfigMessage(config interface{}) *message {
+	return &message{
+		Type: msgTypeControl,
+		control: &control{
+			Type:      controlTypeConnectorConfig,
+			EmittedAt: float64(time.Now().UnixMilli()),
+			ConnectorConfig: &connectorConfig{
+				Config: config,
 			},
-		})
+		},
+	}
+}
+
+func newLogMessage(lvl LogLevel, s string) *message {
+	return &message{
+		Type: msgTypeLog,
+		logMessage: &logMessage{
+			Level:   lvl,
+			Message: s,
+		},
+	}
+}
+
+func newStateMessage(s interface{}) *message {
+	return &message{
+		Type: msgTypeState,
+		state: &state{
+			Data: s,
+		},
+	}
+}
+
+func newRecordMessage(s interface{}, stream string, namespace string) *message {
+	return &message{
+		Type: msgTypeRecord,
+		record: &record{
+			EmittedAt: time.Now().UnixMilli(),
+			Data:      s,
+			Namespace: namespace,
+			Stream:    stream,
+		},
 	}
 }
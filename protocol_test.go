@@ -0,0 +1,123 @@
+package airbyte
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+// FuzzMessageRoundTrip writes messages through the real writers and checks the decoder hands back what was written
+func FuzzMessageRoundTrip(f *testing.F) {
+	f.Add(uint8(0), "users", "public", "payload", int64(1))
+	f.Add(uint8(1), "", "", "{\"cursor\": 1}", int64(-1))
+	f.Add(uint8(2), "INFO", "", "hello\nworld", int64(0))
+
+	f.Fuzz(func(t *testing.T, kind uint8, a string, b string, payload string, n int64) {
+		if !utf8.ValidString(a) || !utf8.ValidString(b) || !utf8.ValidString(payload) {
+			// json replaces invalid utf-8 with U+FFFD, there is no way to round trip it
+			t.Skip()
+		}
+		data := map[string]interface{}{"s": payload, "n": float64(n % (1 << 53)), "a": []interface{}{a, b}}
+
+		var buf bytes.Buffer
+		var err error
+		switch kind % 4 {
+		case 0:
+			err = newRecordWriter(&buf)(data, a, b)
+		case 1:
+			err = newStateWriter(&buf)(data)
+		case 2:
+			err = newLogWriter(&buf)(LogLevel(a), payload)
+		case 3:
+			err = newConfigWriter(&buf)(data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []interface{}
+		err = ReadMessages(&buf, MessageHandler{
+			Record: func(raw json.RawMessage, streamName string, namespace string, emittedAt int64) error {
+				if streamName != a || namespace != b {
+					t.Errorf("stream %q/%q decoded as %q/%q", a, b, streamName, namespace)
+				}
+				var v interface{}
+				got = append(got, &v)
+				return json.Unmarshal(raw, &v)
+			},
+			State: func(raw json.RawMessage) error {
+				var v interface{}
+				got = append(got, &v)
+				return json.Unmarshal(raw, &v)
+			},
+			Log: func(level LogLevel, s string) error {
+				if level != LogLevel(a) || s != payload {
+					t.Errorf("log %q/%q decoded as %q/%q", a, payload, level, s)
+				}
+				got = append(got, nil)
+				return nil
+			},
+			Config: func(raw json.RawMessage) error {
+				var v interface{}
+				got = append(got, &v)
+				return json.Unmarshal(raw, &v)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("expected 1 message, got %d", len(got))
+		}
+		if v, ok := got[0].(*interface{}); ok && !reflect.DeepEqual(*v, interface{}(data)) {
+			t.Fatalf("wrote %v, read %v", data, *v)
+		}
+	})
+}
+
+// FuzzMessageUnmarshal feeds arbitrary input into the decoder, whatever it accepts has to be a consistent message
+// which marshals back to itself
+func FuzzMessageUnmarshal(f *testing.F) {
+	f.Add([]byte(`{"type":"RECORD","record":{"emitted_at":1,"namespace":"","data":{"a":1},"stream":"s"}}`))
+	f.Add([]byte(`{"type":"STATE","state":{"data":{"cursor":"2022-01-01"}}}`))
+	f.Add([]byte(`{"type":"LOG","log":{"level":"INFO","message":"hi"}}`))
+	f.Add([]byte(`{"type":"CONNECTION_STATUS","connectionStatus":{"status":"SUCCEEDED"}}`))
+	f.Add([]byte(`{"type":"CATALOG","catalog":{"streams":[]}}`))
+	f.Add([]byte(`{"type":"CONTROL","control":{"type":"CONNECTOR_CONFIG","emitted_at":1,"connectorConfig":{"config":{"a":1}}}}`))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var m message
+		if err := json.Unmarshal(b, &m); err != nil {
+			return
+		}
+
+		payloads := 0
+		for _, set := range []bool{m.record != nil, m.state != nil, m.logMessage != nil,
+			m.ConnectorSpecification != nil, m.connectionStatus != nil, m.Catalog != nil, m.control != nil} {
+			if set {
+				payloads++
+			}
+		}
+		if payloads != 1 {
+			t.Fatalf("decoded %s message with %d payloads", m.Type, payloads)
+		}
+
+		first, err := json.Marshal(&m)
+		if err != nil {
+			t.Fatalf("decoded message does not marshal: %v", err)
+		}
+		var m2 message
+		if err := json.Unmarshal(first, &m2); err != nil {
+			t.Fatalf("marshalled message does not decode: %v", err)
+		}
+		second, err := json.Marshal(&m2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, second) {
+			t.Fatalf("round trip changed the message\n%s\n%s", first, second)
+		}
+	})
+}
@@ -3,11 +3,14 @@ package airbyte
 import (
 	"io"
 	"sync"
+	"time"
 )
 
 type safeWriter struct {
 	w  io.Writer
 	mu sync.Mutex
+	// lockWait, when set, is called with the time every write waited for the lock
+	lockWait func(time.Duration)
 }
 
 func newSafeWriter(w io.Writer) io.Writer {
@@ -17,7 +20,13 @@ func newSafeWriter(w io.Writer) io.Writer {
 }
 
 func (sw *safeWriter) Write(p []byte) (int, error) {
-	sw.mu.Lock()
+	if sw.lockWait != nil {
+		start := time.Now()
+		sw.mu.Lock()
+		sw.lockWait(time.Since(start))
+	} else {
+		sw.mu.Lock()
+	}
 	defer sw.mu.Unlock()
 	return sw.w.Write(p)
 }
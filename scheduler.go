@@ -0,0 +1,197 @@
+package airbyte
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StreamReadFunc reads a single configured stream, it should return once ctx is done
+type StreamReadFunc func(ctx context.Context, stream ConfiguredStream) error
+
+// StreamScheduler reads the streams of a catalog concurrently with a bounded number of workers,
+// so connectors don't need their own WaitGroups and error plumbing
+//
+// Example usage
+//
+//	sched := airbyte.StreamScheduler{Workers: 4, Log: tracker.Log}
+//	return sched.Run(context.Background(), configuredCat.Streams, func(ctx context.Context, s airbyte.ConfiguredStream) error {
+//		return readStream(ctx, s, tracker)
+//	})
+//
+// MessageTracker is thread-safe, the state shared by the streams can be kept in a Checkpointer
+type StreamScheduler struct {
+	// Workers is the number of streams read at once, 1 if 0
+	Workers int
+	// IsolateFailures keeps reading the other streams after a stream failed and returns every failure at the end
+	// Otherwise the first failure cancels the streams which are running and skips the ones which haven't started
+	IsolateFailures bool
+	// Log logs when a stream starts, finishes or fails and sums up the failed streams once every stream is done,
+	// nothing is logged if nil
+	Log LogWriter
+}
+
+// Checkpointer guards the state of the streams read by a StreamScheduler, the state of every stream is kept under
+// a key like its name and the states of all streams are emitted as one state message
+//
+// Example usage
+//
+//	cp := airbyte.NewCheckpointer(states, tracker.State)
+//	ss, _ := cp.Get(stream.Name).(StreamState)
+//	ss.Cursor = cursor
+//	return cp.Set(stream.Name, ss)
+type Checkpointer struct {
+	mu     sync.Mutex
+	states map[string]interface{}
+	write  StateWriter
+}
+
+// NewCheckpointer returns a Checkpointer starting at the states of the last sync, which may be nil, and emitting
+// the states with write
+func NewCheckpointer(states map[string]interface{}, write StateWriter) *Checkpointer {
+	c := &Checkpointer{states: make(map[string]interface{}, len(states)), write: write}
+	for key, state := range states {
+		c.states[key] = state
+	}
+	return c
+}
+
+// Get returns the state under key, nil if there is none
+func (c *Checkpointer) Get(key string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.states[key]
+}
+
+// Set updates the state under key and emits the states of every stream
+func (c *Checkpointer) Set(key string, state interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.states[key] = state
+	return c.write(c.states)
+}
+
+// StreamError is the failure of a single stream
+type StreamError struct {
+	Stream    string
+	Namespace string
+	Err       error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("stream %s: %v", e.Stream, e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// StreamErrors holds the failures of a StreamScheduler run in catalog order
+type StreamErrors []*StreamError
+
+func (e StreamErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d streams failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Run reads every stream with read and waits for all of them, the error is a StreamErrors if any stream failed
+// and is logged as the last message of the run. Streams which were canceled because another stream failed
+// aren't failures themselves
+func (s StreamScheduler) Run(ctx context.Context, streams []ConfiguredStream, read StreamReadFunc) error {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failed   = make([]*StreamError, len(streams))
+		canceled bool
+		sem      = make(chan struct{}, workers)
+	)
+
+	for i, stream := range streams {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, stream ConfiguredStream) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := s.read(ctx, stream, read)
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if canceled && errors.Is(err, context.Canceled) {
+				// stopped because another stream failed
+				return
+			}
+			failed[i] = &StreamError{Stream: stream.Stream.Name, Namespace: stream.Stream.Namespace, Err: err}
+			if !s.IsolateFailures {
+				canceled = true
+				cancel()
+			}
+		}(i, stream)
+	}
+	wg.Wait()
+
+	var errs StreamErrors
+	for _, err := range failed {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		s.log(LogLevelFatal, fmt.Sprintf("%d of %d streams failed: %v", len(errs), len(streams), errs))
+		return errs
+	}
+	// the caller canceled ctx before every stream was started
+	return ctx.Err()
+}
+
+// read reads a single stream, a panic fails the stream instead of the connector
+func (s StreamScheduler) read(ctx context.Context, stream ConfiguredStream, read StreamReadFunc) (err error) {
+	name := stream.Stream.Name
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			s.log(LogLevelError, fmt.Sprintf("stream %s failed: %v", name, err))
+		}
+	}()
+
+	s.log(LogLevelInfo, fmt.Sprintf("reading stream %s", name))
+	if err := read(ctx, stream); err != nil {
+		return err
+	}
+	s.log(LogLevelInfo, fmt.Sprintf("finished stream %s", name))
+	return nil
+}
+
+func (s StreamScheduler) log(level LogLevel, msg string) {
+	if s.Log != nil {
+		// logging is best effort, the outcome of the stream is what counts
+		_ = s.Log(level, msg)
+	}
+}
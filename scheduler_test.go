@@ -0,0 +1,156 @@
+package airbyte
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func schedulerStreams(names ...string) []ConfiguredStream {
+	streams := make([]ConfiguredStream, len(names))
+	for i, n := range names {
+		streams[i] = ConfiguredStream{Stream: Stream{Name: n}}
+	}
+	return streams
+}
+
+func TestStreamSchedulerWorkers(t *testing.T) {
+	var running, peak int32
+	var mu sync.Mutex
+	var read []string
+	err := StreamScheduler{Workers: 2}.Run(context.Background(), schedulerStreams("a", "b", "c", "d", "e"),
+		func(ctx context.Context, s ConfiguredStream) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			read = append(read, s.Stream.Name)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 5 || peak != 2 {
+		t.Fatalf("expected 5 streams with 2 at once, read %v with %d at once", read, peak)
+	}
+}
+
+func TestStreamSchedulerCancels(t *testing.T) {
+	broken := errors.New("broken")
+	var started []string
+	var mu sync.Mutex
+	err := StreamScheduler{Workers: 2}.Run(context.Background(), schedulerStreams("slow", "failing", "never"),
+		func(ctx context.Context, s ConfiguredStream) error {
+			mu.Lock()
+			started = append(started, s.Stream.Name)
+			mu.Unlock()
+			switch s.Stream.Name {
+			case "failing":
+				return broken
+			case "slow":
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+
+	var errs StreamErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Stream != "failing" || !errors.Is(errs[0], broken) {
+		t.Fatalf("expected only the failing stream to fail, got %v", err)
+	}
+	for _, s := range started {
+		if s == "never" {
+			t.Error("expected the stream after the failure not to start")
+		}
+	}
+}
+
+func TestStreamSchedulerIsolatesFailures(t *testing.T) {
+	var mu sync.Mutex
+	read := map[string]bool{}
+	var last logMessage
+	logf := func(level LogLevel, msg string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		last = logMessage{Level: level, Message: msg}
+		return nil
+	}
+	err := StreamScheduler{Workers: 3, IsolateFailures: true, Log: logf}.Run(context.Background(), schedulerStreams("a", "b", "c", "d"),
+		func(ctx context.Context, s ConfiguredStream) error {
+			mu.Lock()
+			read[s.Stream.Name] = true
+			mu.Unlock()
+			switch s.Stream.Name {
+			case "a":
+				return errors.New("a is down")
+			case "c":
+				panic("c is broken")
+			}
+			return ctx.Err()
+		})
+
+	var errs StreamErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Stream != "a" || errs[1].Stream != "c" {
+		t.Fatalf("expected a and c to fail in catalog order, got %v", err)
+	}
+	if err.Error() != "2 streams failed: stream a: a is down; stream c: panic: c is broken" {
+		t.Errorf("unexpected error %q", err)
+	}
+	if len(read) != 4 {
+		t.Fatalf("expected every stream to be read, got %v", read)
+	}
+	if want := (logMessage{LogLevelFatal, "2 of 4 streams failed: " + err.Error()}); last != want {
+		t.Errorf("expected the failures to be summed up last, got %+v", last)
+	}
+}
+
+func TestCheckpointer(t *testing.T) {
+	var emitted []map[string]interface{}
+	cp := NewCheckpointer(map[string]interface{}{"a": 1}, func(v interface{}) error {
+		states := v.(map[string]interface{})
+		copied := make(map[string]interface{}, len(states))
+		for k, s := range states {
+			copied[k] = s
+		}
+		emitted = append(emitted, copied)
+		return nil
+	})
+
+	err := StreamScheduler{Workers: 4}.Run(context.Background(), schedulerStreams("b", "c", "d"),
+		func(ctx context.Context, s ConfiguredStream) error {
+			for i := 1; i <= 10; i++ {
+				n, _ := cp.Get(s.Stream.Name).(int)
+				if err := cp.Set(s.Stream.Name, n+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(emitted) != 30 {
+		t.Fatalf("expected a state per update, got %d", len(emitted))
+	}
+	last := emitted[len(emitted)-1]
+	for _, key := range []string{"b", "c", "d"} {
+		if last[key] != 10 {
+			t.Errorf("expected %s to be 10 in the last state, got %v", key, last[key])
+		}
+	}
+	if last["a"] != 1 {
+		t.Errorf("expected the state of the last sync to be kept, got %v", last["a"])
+	}
+}
@@ -0,0 +1,224 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+// typeBuilder turns fuzz input into a random go type
+type typeBuilder struct {
+	data         []byte
+	pos          int
+	hasInterface bool
+}
+
+func (b *typeBuilder) next() byte {
+	if b.pos >= len(b.data) {
+		return 0
+	}
+	c := b.data[b.pos]
+	b.pos++
+	return c
+}
+
+var leafTypes = []reflect.Type{
+	reflect.TypeOf(int(0)),
+	reflect.TypeOf(int64(0)),
+	reflect.TypeOf(uint8(0)),
+	reflect.TypeOf(uint64(0)),
+	reflect.TypeOf(float64(0)),
+	reflect.TypeOf(""),
+	reflect.TypeOf(false),
+}
+
+func (b *typeBuilder) build(depth int) reflect.Type {
+	c := b.next()
+	if depth > 3 {
+		return leafTypes[int(c)%len(leafTypes)]
+	}
+
+	switch c % 14 {
+	case 7:
+		return reflect.SliceOf(b.build(depth + 1))
+	case 8:
+		return reflect.MapOf(reflect.TypeOf(""), b.build(depth+1))
+	case 9:
+		return reflect.PtrTo(b.build(depth + 1))
+	case 10:
+		return reflect.ArrayOf(int(b.next()%3), b.build(depth+1))
+	case 11, 12:
+		return b.buildStruct(depth)
+	case 13:
+		b.hasInterface = true
+		return reflect.TypeOf((*interface{})(nil)).Elem()
+	}
+	return leafTypes[int(c%14)%len(leafTypes)]
+}
+
+func (b *typeBuilder) buildStruct(depth int) reflect.Type {
+	n := int(b.next() % 4)
+	fields := make([]reflect.StructField, 0, n)
+	for i := 0; i < n; i++ {
+		flags := b.next()
+		ft := b.build(depth + 1)
+		f := reflect.StructField{
+			Name: "F" + strconv.Itoa(depth) + strconv.Itoa(i),
+			Type: ft,
+		}
+
+		st := ft
+		if st.Kind() == reflect.Ptr {
+			st = st.Elem()
+		}
+		if flags%3 == 0 && st.Kind() == reflect.Struct {
+			f.Anonymous = true
+		} else {
+			tag := `json:"f` + strconv.Itoa(i)
+			if flags%2 == 0 {
+				tag += `,omitempty`
+			}
+			f.Tag = reflect.StructTag(tag + `"`)
+		}
+		fields = append(fields, f)
+	}
+	return reflect.StructOf(fields)
+}
+
+// FuzzGenerate feeds random types into the generator and checks that random values of those types
+// match the generated schema in both nullability modes
+func FuzzGenerate(f *testing.F) {
+	f.Add([]byte{7, 1}, int64(1))    // []int64
+	f.Add([]byte{8, 7, 5}, int64(2)) // map[string][]string
+	f.Add([]byte{11, 2, 3, 9, 5}, int64(3))
+	f.Add([]byte{11, 1, 1, 13}, int64(4)) // struct with an interface field
+
+	f.Fuzz(func(t *testing.T, data []byte, seed int64) {
+		b := &typeBuilder{data: data}
+		typ := reflect.StructOf([]reflect.StructField{{
+			Name: "V",
+			Type: b.build(0),
+			Tag:  `json:"v"`,
+		}})
+
+		for _, nullability := range []schema.Nullability{schema.NullableAll, schema.NullableExplicit} {
+			opts := schema.Options{Nullability: nullability}
+			props, err := airbyte.InferSchemaFromStructWithOptions(reflect.Zero(typ).Interface(), opts, airbyte.LogTracker{
+				Log: func(level airbyte.LogLevel, s string) error { return nil },
+			})
+			if err != nil {
+				t.Fatalf("%v: %v", typ, err)
+			}
+			if b.hasInterface {
+				// there is no telling what an interface holds
+				continue
+			}
+
+			v := randomValue(typ, rand.New(rand.NewSource(seed)))
+			record, err := json.Marshal(v.Interface())
+			if err != nil {
+				t.Fatalf("%v: %v", typ, err)
+			}
+			if err := airbytetest.ValidateRecord(record, props); err != nil {
+				t.Fatalf("%v with nullability %d: %s: %v", typ, nullability, record, err)
+			}
+		}
+	})
+}
+
+// randomValue returns a small random value of type t, including nil pointers, slices and maps
+func randomValue(t reflect.Type, r *rand.Rand) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		v.SetInt(r.Int63() - r.Int63())
+	case reflect.Uint8, reflect.Uint64:
+		v.SetUint(r.Uint64() >> uint(r.Intn(64)))
+	case reflect.Float64:
+		v.SetFloat(r.NormFloat64() * 1e6)
+	case reflect.String:
+		v.SetString(strconv.Itoa(r.Int()))
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 0)
+	case reflect.Ptr:
+		if r.Intn(3) > 0 {
+			v.Set(randomValue(t.Elem(), r).Addr())
+		}
+	case reflect.Slice:
+		if r.Intn(4) > 0 {
+			n := r.Intn(3)
+			v.Set(reflect.MakeSlice(t, n, n))
+			for i := 0; i < n; i++ {
+				v.Index(i).Set(randomValue(t.Elem(), r))
+			}
+		}
+	case reflect.Map:
+		if r.Intn(4) > 0 {
+			v.Set(reflect.MakeMap(t))
+			for i := r.Intn(3); i > 0; i-- {
+				v.SetMapIndex(reflect.ValueOf(strconv.Itoa(r.Int())), randomValue(t.Elem(), r))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			v.Index(i).Set(randomValue(t.Elem(), r))
+		}
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			v.Field(i).Set(randomValue(t.Field(i).Type, r))
+		}
+	}
+	return v
+}
+
+type node struct {
+	Name     string `json:"name"`
+	Children []node `json:"children"`
+}
+
+type base struct {
+	ID int `json:"id"`
+}
+
+type pointed struct {
+	ID int `json:"id"`
+}
+
+type withEmbedded struct {
+	*pointed
+	base   `json:"named"`
+	secret string
+}
+
+// regressions which can't be expressed as fuzz input, as reflect can't build recursive or named types
+func TestGenerateRegressions(t *testing.T) {
+	if _, err := schema.Generate(reflect.TypeOf(node{})); !errors.Is(err, schema.ErrSchemaInvalid) {
+		t.Fatalf("expected recursive type to fail, got %v", err)
+	}
+
+	s, err := schema.Generate(reflect.TypeOf(withEmbedded{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Properties["secret"]; ok {
+		t.Error("unexported field in schema")
+	}
+	if _, ok := s.Properties["named"].Properties["id"]; !ok {
+		t.Error("tagged embedded struct should not be flattened")
+	}
+	if _, ok := s.Properties["id"]; !ok {
+		t.Error("untagged embedded struct should be flattened")
+	}
+	for _, r := range s.Required {
+		if r == "id" {
+			t.Error("fields promoted through a nil-able embedded pointer can't be required")
+		}
+	}
+}
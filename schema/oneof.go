@@ -0,0 +1,386 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Variant is one concrete struct type which can be stored in an interface registered with RegisterOneOf
+type Variant struct {
+	// Title is the name of the option shown in the airbyte UI
+	Title string
+	// Type is the concrete struct type or pointer to struct type implementing the interface.
+	// It has to declare the discriminator property with a const tag, which tells the variants apart
+	Type reflect.Type
+}
+
+type oneOf struct {
+	discriminator string
+	variants      []Variant
+	// consts holds the json round tripped const value of every variant, in order
+	consts []interface{}
+}
+
+var (
+	oneOfsMu sync.RWMutex
+	oneOfs   = map[reflect.Type]*oneOf{}
+
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// RegisterOneOf registers the concrete types which can be stored in the interface type iface.
+// Fields of type iface then generate a oneOf schema with one object per variant and Unmarshal
+// decodes them into the variant whose discriminator property matches the const tag of its field.
+//
+// Example usage
+//  type Auth interface{ isAuth() }
+//
+//  type APIKeyAuth struct {
+//  	Method string `json:"auth_method" const:"api_key"`
+//  	APIKey string `json:"api_key" airbyte_secret:"true"`
+//  }
+//
+//  type OAuth struct {
+//  	Method       string `json:"auth_method" const:"oauth"`
+//  	ClientID     string `json:"client_id"`
+//  	ClientSecret string `json:"client_secret" airbyte_secret:"true"`
+//  }
+//
+//  err := schema.RegisterOneOf(reflect.TypeOf((*Auth)(nil)).Elem(), "auth_method",
+//  	schema.Variant{Title: "API Key", Type: reflect.TypeOf(APIKeyAuth{})},
+//  	schema.Variant{Title: "OAuth", Type: reflect.TypeOf(OAuth{})},
+//  )
+func RegisterOneOf(iface reflect.Type, discriminator string, variants ...Variant) error {
+	if iface == nil || iface.Kind() != reflect.Interface {
+		return fmt.Errorf("oneOf %v is not an interface: %w", iface, ErrSchemaInvalid)
+	}
+
+	if len(variants) == 0 {
+		return fmt.Errorf("oneOf %v has no variants: %w", iface, ErrSchemaInvalid)
+	}
+
+	o := &oneOf{
+		discriminator: discriminator,
+		variants:      variants,
+	}
+	for _, v := range variants {
+		if v.Type == nil || !v.Type.Implements(iface) {
+			return fmt.Errorf("oneOf %v variant %v does not implement it: %w", iface, v.Type, ErrSchemaInvalid)
+		}
+
+		st := v.Type
+		if st.Kind() == reflect.Ptr {
+			st = st.Elem()
+		}
+		if st.Kind() != reflect.Struct {
+			return fmt.Errorf("oneOf %v variant %v is not a struct: %w", iface, v.Type, ErrSchemaInvalid)
+		}
+
+		c, err := discriminatorConst(st, discriminator)
+		if err != nil {
+			return fmt.Errorf("oneOf %v variant %v: %w", iface, v.Type, err)
+		}
+
+		for _, other := range o.consts {
+			if reflect.DeepEqual(other, c) {
+				return fmt.Errorf("oneOf %v variant %v reuses discriminator %v: %w", iface, v.Type, c, ErrSchemaInvalid)
+			}
+		}
+		o.consts = append(o.consts, c)
+	}
+
+	oneOfsMu.Lock()
+	defer oneOfsMu.Unlock()
+	oneOfs[iface] = o
+	return nil
+}
+
+func lookupOneOf(t reflect.Type) (*oneOf, bool) {
+	oneOfsMu.RLock()
+	defer oneOfsMu.RUnlock()
+	o, ok := oneOfs[t]
+	return o, ok
+}
+
+// discriminatorConst returns the const value of the discriminator field as it looks after a json round trip
+func discriminatorConst(t reflect.Type, discriminator string) (interface{}, error) {
+	for _, f := range getFields(t) {
+		if jsonName(f.StructField) != discriminator {
+			continue
+		}
+
+		_, _, s, err := GenerateFromField(f.StructField, ModeAll)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil || s.Const == nil {
+			return nil, fmt.Errorf("discriminator %s needs a const tag: %w", discriminator, ErrSchemaInvalid)
+		}
+
+		b, err := json.Marshal(s.Const)
+		if err != nil {
+			return nil, err
+		}
+		var c interface{}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("discriminator %s is not a field: %w", discriminator, ErrSchemaInvalid)
+}
+
+// generateOneOf fills in the oneOf schema for a registered interface
+func generateOneOf(o *oneOf, opts Options, schema *Schema) (*Schema, error) {
+	schema.Type = opts.types(TypeObject)
+	for _, v := range o.variants {
+		t := v.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		s, err := GenerateWithOptions(t, opts, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.Title = v.Title
+
+		required := false
+		for _, r := range s.Required {
+			required = required || r == o.discriminator
+		}
+		if !required {
+			s.Required = append(s.Required, o.discriminator)
+		}
+
+		schema.OneOf = append(schema.OneOf, s)
+	}
+
+	return schema, nil
+}
+
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return strings.ToLower(f.Name) // matches GenerateFromField
+	}
+	return name
+}
+
+// Unmarshal works like json.Unmarshal, but decodes interface values registered with RegisterOneOf
+// into the variant selected by the discriminator property
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		// let json report the invalid target
+		return json.Unmarshal(data, v)
+	}
+
+	return decode(data, rv.Elem())
+}
+
+func decode(data []byte, v reflect.Value) error {
+	if !containsOneOf(v.Type(), map[reflect.Type]bool{}) {
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		o, _ := lookupOneOf(v.Type())
+		return decodeOneOf(o, data, v)
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(data, v.Elem())
+
+	case reflect.Slice, reflect.Array:
+		var raws []json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(raws), len(raws)))
+		}
+		for i := 0; i < len(raws) && i < v.Len(); i++ {
+			if err := decode(raws[i], v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key %v for oneOf values: %w", v.Type().Key(), ErrSchemaInvalid)
+		}
+		var raws map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(raws)))
+		}
+		for k, raw := range raws {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decode(raw, elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+		return nil
+
+	case reflect.Struct:
+		var raws map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return err
+		}
+		return decodeStruct(data, raws, v)
+	}
+
+	return json.Unmarshal(data, v.Addr().Interface())
+}
+
+func decodeStruct(data []byte, raws map[string]json.RawMessage, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			// embedded fields are flattened into the parent object
+			fv := v.Field(i)
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+				if ft.Kind() != reflect.Struct || !fv.CanSet() {
+					continue
+				}
+				if fv.IsNil() {
+					fv.Set(reflect.New(ft))
+				}
+				fv = fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := decodeStruct(data, raws, fv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		raw, ok := raws[name]
+		if !ok {
+			// json matches keys case-insensitively as a fallback
+			for k, r := range raws {
+				if strings.EqualFold(k, name) {
+					raw, ok = r, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := decode(raw, v.Field(i)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func decodeOneOf(o *oneOf, data []byte, v reflect.Value) error {
+	var raws map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+
+	raw, ok := raws[o.discriminator]
+	if !ok {
+		return fmt.Errorf("missing discriminator %s: %w", o.discriminator, ErrSchemaInvalid)
+	}
+	var d interface{}
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return err
+	}
+
+	for i, c := range o.consts {
+		if !reflect.DeepEqual(c, d) {
+			continue
+		}
+
+		vt := o.variants[i].Type
+		isPtr := vt.Kind() == reflect.Ptr
+		if isPtr {
+			vt = vt.Elem()
+		}
+
+		nv := reflect.New(vt)
+		if err := decode(data, nv.Elem()); err != nil {
+			return err
+		}
+		if isPtr {
+			v.Set(nv)
+		} else {
+			v.Set(nv.Elem())
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown %s %s: %w", o.discriminator, raw, ErrSchemaInvalid)
+}
+
+// containsOneOf reports whether a value of type t can hold a registered oneOf interface
+func containsOneOf(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		// custom unmarshalers are left alone
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		_, ok := lookupOneOf(t)
+		return ok
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return containsOneOf(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if containsOneOf(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
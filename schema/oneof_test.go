@@ -0,0 +1,67 @@
+package schema_test
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+type Auth interface{ isAuth() }
+
+type APIKeyAuth struct {
+	Method string `json:"auth_method" const:"api_key"`
+	APIKey string `json:"api_key" airbyte_secret:"true"`
+}
+
+func (APIKeyAuth) isAuth() {}
+
+type OAuth struct {
+	Method       string `json:"auth_method" const:"oauth"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret" airbyte_secret:"true"`
+}
+
+func (*OAuth) isAuth() {}
+
+type Config struct {
+	Name        string `json:"name"`
+	Credentials Auth   `json:"credentials" title:"Authentication"`
+	Fallbacks   []Auth `json:"fallbacks,omitempty"`
+}
+
+func ExampleRegisterOneOf() {
+	err := schema.RegisterOneOf(reflect.TypeOf((*Auth)(nil)).Elem(), "auth_method",
+		schema.Variant{Title: "API Key", Type: reflect.TypeOf(APIKeyAuth{})},
+		schema.Variant{Title: "OAuth", Type: reflect.TypeOf(&OAuth{})},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	s, err := schema.Generate(reflect.TypeOf(Config{}))
+	if err != nil {
+		panic(err)
+	}
+	for _, o := range s.Properties["credentials"].OneOf {
+		fmt.Println(o.Title, o.Properties["auth_method"].Const)
+	}
+
+	var cfg Config
+	err = schema.Unmarshal([]byte(`{
+		"name": "example",
+		"credentials": {"auth_method": "oauth", "client_id": "id", "client_secret": "secret"},
+		"fallbacks": [{"auth_method": "api_key", "api_key": "key"}]
+	}`), &cfg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%+v\n", cfg.Credentials)
+	fmt.Printf("%+v\n", cfg.Fallbacks[0])
+
+	// Output:
+	// API Key api_key
+	// OAuth oauth
+	// &{Method:oauth ClientID:id ClientSecret:secret}
+	// {Method:api_key APIKey:key}
+}
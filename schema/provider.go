@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"reflect"
+	"sync"
+)
+
+// SchemaProvider can be implemented by types which know their own schema better than the generator,
+// e.g. types with a custom json.Marshaler like decimals, uuids or enums.
+// JSONSchema is called on the zero value and has to return a new schema on every call,
+// as field tags like description are applied to the returned schema.
+type SchemaProvider interface {
+	JSONSchema() *Schema
+}
+
+var (
+	providerType = reflect.TypeOf((*SchemaProvider)(nil)).Elem()
+
+	registeredMu sync.RWMutex
+	registered   = map[reflect.Type]func() *Schema{}
+)
+
+// RegisterSchema maps a type which can't implement SchemaProvider, usually one from a third party package,
+// to the schema returned by fn. fn has to return a new schema on every call.
+//
+// Example usage
+//
+//	schema.RegisterSchema(reflect.TypeOf(decimal.Decimal{}), func() *schema.Schema {
+//		return &schema.Schema{Type: []string{schema.TypeNumber, "null"}, AirbyteType: "big_number"}
+//	})
+func RegisterSchema(t reflect.Type, fn func() *Schema) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered[t] = fn
+}
+
+// providedSchema returns the schema supplied by the type itself or the registry.
+// Pointers are not resolved here, GenerateWithMode dereferences them first.
+func providedSchema(t reflect.Type) (*Schema, bool) {
+	registeredMu.RLock()
+	fn, ok := registered[t]
+	registeredMu.RUnlock()
+	if ok {
+		return nonNil(fn()), true
+	}
+
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+		return nil, false
+	}
+
+	if t.Implements(providerType) {
+		return nonNil(reflect.Zero(t).Interface().(SchemaProvider).JSONSchema()), true
+	}
+
+	if reflect.PtrTo(t).Implements(providerType) {
+		return nonNil(reflect.New(t).Interface().(SchemaProvider).JSONSchema()), true
+	}
+
+	return nil, false
+}
+
+func nonNil(s *Schema) *Schema {
+	if s == nil {
+		return &Schema{}
+	}
+	return s
+}
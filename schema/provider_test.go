@@ -0,0 +1,43 @@
+package schema_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+type Status int
+
+func (Status) JSONSchema() *schema.Schema {
+	return &schema.Schema{Type: []string{schema.TypeString}, Enum: []interface{}{"active", "inactive"}}
+}
+
+type Account struct {
+	Status   Status         `json:"status" description:"account status"`
+	History  []Status       `json:"history"`
+	Nickname sql.NullString `json:"nickname"`
+}
+
+func ExampleSchemaProvider() {
+	schema.RegisterSchema(reflect.TypeOf(sql.NullString{}), func() *schema.Schema {
+		return &schema.Schema{Type: []string{schema.TypeString, "null"}}
+	})
+
+	s, err := schema.Generate(reflect.TypeOf(Account{}))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, p := range []*schema.Schema{s.Properties["status"], s.Properties["history"].Items, s.Properties["nickname"]} {
+		b, _ := json.Marshal(p)
+		fmt.Println(string(b))
+	}
+
+	// Output:
+	// {"type":["string"],"description":"account status","enum":["active","inactive"]}
+	// {"type":["string"],"enum":["active","inactive"]}
+	// {"type":["string","null"]}
+}
@@ -55,6 +55,53 @@ const (
 	ModeWrite
 )
 
+// Nullability defines which generated properties accept null.
+type Nullability int // #Edit new type
+
+const (
+	// NullableAll makes every property nullable, e.g. ["string", "null"].
+	NullableAll Nullability = iota
+	// NullableExplicit only makes pointers, omitempty fields and fields tagged
+	// with `nullable:"true"` nullable, and those fields aren't required. Slices
+	// and maps stay nullable as nil ones are encoded as null. Everything else is
+	// non-nullable.
+	NullableExplicit
+)
+
+// Options configures schema generation. The zero value matches
+// GenerateWithMode in ModeAll.
+type Options struct { // #Edit new type
+	Mode        Mode
+	Nullability Nullability
+
+	// generating holds the struct types currently being generated, to detect
+	// recursive types which would otherwise never finish
+	generating map[reflect.Type]bool
+}
+
+// types returns the type list of a generated property, which includes null
+// unless nullability is explicit.
+func (o Options) types(t string) []string { // #Edit new function
+	if o.Nullability == NullableExplicit {
+		return []string{t}
+	}
+	return []string{t, "null"}
+}
+
+// addNull adds null to the types of the schema. Schemas without a type
+// already accept null.
+func addNull(s *Schema) { // #Edit new function
+	if len(s.Type) == 0 {
+		return
+	}
+	for _, t := range s.Type {
+		if t == "null" {
+			return
+		}
+	}
+	s.Type = append(s.Type, "null")
+}
+
 // JSON Schema type constants
 const (
 	TypeBoolean = "boolean"
@@ -88,12 +135,12 @@ func F(value float64) *float64 {
 // Uses JSON parsing if the schema is not a string.
 func getTagValue(s *Schema, t reflect.Type, value string) (interface{}, error) {
 	// Special case: strings don't need quotes.
-	if s.Type[0] == TypeString {
+	if hasType(s, TypeString) { // #Edit from: if s.Type[0] == TypeString {
 		return value, nil
 	}
 
 	// Special case: array of strings with comma-separated values and no quotes.
-	if s.Type[0] == TypeArray && s.Items != nil && s.Items.Type[0] == TypeString && len(value) > 0 && value[0] != '[' {
+	if hasType(s, TypeArray) && s.Items != nil && hasType(s.Items, TypeString) && len(value) > 0 && value[0] != '[' { // #Edit from: if s.Type[0] == TypeArray && s.Items != nil && s.Items.Type[0] == TypeString && ...
 		values := []string{}
 		for _, s := range strings.Split(value, ",") {
 			values = append(values, strings.TrimSpace(s))
@@ -103,6 +150,9 @@ func getTagValue(s *Schema, t reflect.Type, value string) (interface{}, error) {
 
 	var v interface{}
 	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		if t.Kind() == reflect.Interface { // #Edit new lines: untyped values which aren't json are strings
+			return value, nil
+		}
 		return nil, err
 	}
 
@@ -132,6 +182,12 @@ func getTagValue(s *Schema, t reflect.Type, value string) (interface{}, error) {
 	return v, nil
 }
 
+// hasType reports whether the first type of the schema is t, schemas of
+// interfaces have no type
+func hasType(s *Schema, t string) bool { // #Edit new function
+	return len(s.Type) > 0 && s.Type[0] == t
+}
+
 // Schema represents a JSON Schema which can be generated from Go structs
 type Schema struct {
 	Type                 []string           `json:"type,omitempty"` // #Edit from: Type string `json:"type,omitempty"`
@@ -168,13 +224,23 @@ type Schema struct {
 	Deprecated           bool               `json:"deprecated,omitempty"`
 	ContentEncoding      string             `json:"contentEncoding,omitempty"`
 	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`          // #Edit new field
+	Const                interface{}        `json:"const,omitempty"`          // #Edit new field
+	AirbyteSecret        bool               `json:"airbyte_secret,omitempty"` // #Edit new field
+	AirbyteHidden        bool               `json:"airbyte_hidden,omitempty"` // #Edit new field
+	Order                *int               `json:"order,omitempty"`          // #Edit new field
+	Group                string             `json:"group,omitempty"`          // #Edit new field
+	AlwaysShow           bool               `json:"always_show,omitempty"`    // #Edit new field
+	Multiline            bool               `json:"multiline,omitempty"`      // #Edit new field
+	AirbyteType          string             `json:"airbyte_type,omitempty"`   // #Edit new field
+	Examples             []interface{}      `json:"examples,omitempty"`       // #Edit new field
 }
 
 // HasValidation returns true if at least one validator is set on the schema.
 // This excludes the schema's type but includes most other fields and can be
 // used to trigger additional slow validation steps when needed.
 func (s *Schema) HasValidation() bool {
-	if s.Items != nil || len(s.Properties) > 0 || s.AdditionalProperties != nil || len(s.PatternProperties) > 0 || len(s.Required) > 0 || len(s.Enum) > 0 || s.Minimum != nil || s.ExclusiveMinimum != nil || s.Maximum != nil || s.ExclusiveMaximum != nil || s.MultipleOf != 0 || s.MinLength != nil || s.MaxLength != nil || s.Pattern != "" || s.MinItems != nil || s.MaxItems != nil || s.UniqueItems || s.MinProperties != nil || s.MaxProperties != nil || len(s.AllOf) > 0 || len(s.AnyOf) > 0 || len(s.OneOf) > 0 || s.Not != nil || s.Ref != "" {
+	if s.Items != nil || len(s.Properties) > 0 || s.AdditionalProperties != nil || len(s.PatternProperties) > 0 || len(s.Required) > 0 || len(s.Enum) > 0 || s.Minimum != nil || s.ExclusiveMinimum != nil || s.Maximum != nil || s.ExclusiveMaximum != nil || s.MultipleOf != 0 || s.MinLength != nil || s.MaxLength != nil || s.Pattern != "" || s.MinItems != nil || s.MaxItems != nil || s.UniqueItems || s.MinProperties != nil || s.MaxProperties != nil || len(s.AllOf) > 0 || len(s.AnyOf) > 0 || len(s.OneOf) > 0 || s.Not != nil || s.Ref != "" || s.Const != nil { // #Edit from: || s.Not != nil || s.Ref != "" {
 		return true
 	}
 
@@ -201,40 +267,110 @@ func Generate(t reflect.Type) (*Schema, error) {
 	return GenerateWithMode(t, ModeAll, nil)
 }
 
+// field is a struct field found by getFields along with how it was reached. #Edit new type
+type field struct {
+	reflect.StructField
+	// depth is the number of embedded structs the field is promoted through
+	depth int
+	// viaPointer is set for fields promoted through an embedded pointer, they
+	// are missing from the json when the pointer is nil
+	viaPointer bool
+}
+
 // getFields performs a breadth-first search for all fields including embedded
 // ones. It may return multiple fields with the same name, the first of which
 // represents the outer-most declaration.
-func getFields(typ reflect.Type) []reflect.StructField {
-	fields := make([]reflect.StructField, 0, typ.NumField())
+func getFields(typ reflect.Type) []field { // #Edit from: func getFields(typ reflect.Type) []reflect.StructField {
+	return getFieldsAt(typ, 0, false)
+}
+
+func getFieldsAt(typ reflect.Type, depth int, viaPointer bool) []field { // #Edit new function, from getFields
+	fields := make([]field, 0, typ.NumField())
 	embedded := []reflect.StructField{}
 
 	for i := 0; i < typ.NumField(); i++ {
 		f := typ.Field(i)
-		if f.Anonymous {
+		if f.Anonymous && strings.Split(f.Tag.Get("json"), ",")[0] == "" { // #Edit from: if f.Anonymous { (json only flattens untagged embedded structs)
 			embedded = append(embedded, f)
 			continue
 		}
 
-		fields = append(fields, f)
+		if f.PkgPath != "" && !(f.Anonymous && isStruct(f.Type)) { // #Edit new lines: json ignores unexported fields, but encodes tagged embedded structs
+			continue
+		}
+
+		fields = append(fields, field{StructField: f, depth: depth, viaPointer: viaPointer})
 	}
 
 	for _, f := range embedded {
 		newTyp := f.Type
+		isPtr := false
 		if newTyp.Kind() == reflect.Ptr {
 			newTyp = newTyp.Elem()
+			isPtr = true
 		}
 		if newTyp.Kind() == reflect.Struct {
-			fields = append(fields, getFields(newTyp)...)
+			fields = append(fields, getFieldsAt(newTyp, depth+1, viaPointer || isPtr)...)
+		} else if f.PkgPath == "" { // #Edit new lines: embedded non-structs are regular fields
+			fields = append(fields, field{StructField: f, depth: depth, viaPointer: viaPointer})
 		}
 	}
 
 	return fields
 }
 
+// isStruct reports whether t is a struct or a pointer to one. #Edit new function
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// dominantField returns the index of the field json encodes out of fields
+// sharing a name: the shallowest one, or the only tagged one at that depth.
+// It returns false if json drops the name because the fields are ambiguous.
+func dominantField(fields []field) (int, bool) { // #Edit new function
+	min := fields[0].depth
+	for _, f := range fields[1:] {
+		if f.depth < min {
+			min = f.depth
+		}
+	}
+
+	shallowest, tagged := -1, -1
+	nShallowest, nTagged := 0, 0
+	for i, f := range fields {
+		if f.depth != min {
+			continue
+		}
+		shallowest = i
+		nShallowest++
+		if strings.Split(f.Tag.Get("json"), ",")[0] != "" {
+			tagged = i
+			nTagged++
+		}
+	}
+
+	if nShallowest == 1 {
+		return shallowest, true
+	}
+	if nTagged == 1 {
+		return tagged, true
+	}
+	return -1, false
+}
+
 // GenerateFromField generates a schema for a single struct field. It returns
 // the computed field name, whether it is optional, its schema, and any error
 // which may have occurred.
 func GenerateFromField(f reflect.StructField, mode Mode) (string, bool, *Schema, error) {
+	return GenerateFromFieldWithOptions(f, Options{Mode: mode}) // #Edit new line
+}
+
+// GenerateFromFieldWithOptions works like GenerateFromField, using opts to
+// configure the generated schema.
+func GenerateFromFieldWithOptions(f reflect.StructField, opts Options) (string, bool, *Schema, error) { // #Edit new function
 	jsonTags := strings.Split(f.Tag.Get("json"), ",")
 	name := strings.ToLower(f.Name)
 	if len(jsonTags) > 0 && jsonTags[0] != "" {
@@ -246,7 +382,7 @@ func GenerateFromField(f reflect.StructField, mode Mode) (string, bool, *Schema,
 		return name, false, nil, nil
 	}
 
-	s, err := GenerateWithMode(f.Type, mode, nil)
+	s, err := GenerateWithOptions(f.Type, opts, nil) // #Edit from: s, err := GenerateWithMode(f.Type, mode, nil)
 	if err != nil {
 		return name, false, nil, err
 	}
@@ -263,12 +399,67 @@ func GenerateFromField(f reflect.StructField, mode Mode) (string, bool, *Schema,
 		s.Format = tag
 	}
 
+	// #Edit start: airbyte specific spec keywords used by the UI
+	if tag, ok := f.Tag.Lookup("title"); ok {
+		s.Title = tag
+	}
+
+	if tag, ok := f.Tag.Lookup("group"); ok {
+		s.Group = tag
+	}
+
+	if tag, ok := f.Tag.Lookup("order"); ok {
+		order, err := strconv.Atoi(tag)
+		if err != nil {
+			return name, false, nil, err
+		}
+		s.Order = &order
+	}
+
+	if tag, ok := f.Tag.Lookup("const"); ok {
+		v, err := getTagValue(s, f.Type, tag)
+		if err != nil {
+			return name, false, nil, err
+		}
+
+		s.Const = v
+	}
+
+	if tag, ok := f.Tag.Lookup("airbyte_secret"); ok {
+		if !(tag == "true" || tag == "false") {
+			return name, false, nil, fmt.Errorf("%s airbyte_secret: boolean should be true or false: %w", f.Name, ErrSchemaInvalid)
+		}
+		s.AirbyteSecret = tag == "true"
+	}
+
+	if tag, ok := f.Tag.Lookup("airbyte_hidden"); ok {
+		if !(tag == "true" || tag == "false") {
+			return name, false, nil, fmt.Errorf("%s airbyte_hidden: boolean should be true or false: %w", f.Name, ErrSchemaInvalid)
+		}
+		s.AirbyteHidden = tag == "true"
+	}
+
+	if tag, ok := f.Tag.Lookup("always_show"); ok {
+		if !(tag == "true" || tag == "false") {
+			return name, false, nil, fmt.Errorf("%s always_show: boolean should be true or false: %w", f.Name, ErrSchemaInvalid)
+		}
+		s.AlwaysShow = tag == "true"
+	}
+
+	if tag, ok := f.Tag.Lookup("multiline"); ok {
+		if !(tag == "true" || tag == "false") {
+			return name, false, nil, fmt.Errorf("%s multiline: boolean should be true or false: %w", f.Name, ErrSchemaInvalid)
+		}
+		s.Multiline = tag == "true"
+	}
+	// #Edit end
+
 	if tag, ok := f.Tag.Lookup("enum"); ok {
 		s.Enum = []interface{}{}
 
 		enumType := f.Type
 		enumSchema := s
-		if s.Type[0] == TypeArray { // #Edit from: if s.Type == TypeArray {
+		if hasType(s, TypeArray) { // #Edit from: if s.Type == TypeArray {
 			// Enum values should be the type of the array elements, not the
 			// array itself!
 			enumType = f.Type.Elem()
@@ -445,6 +636,17 @@ func GenerateFromField(f reflect.StructField, mode Mode) (string, bool, *Schema,
 		}
 	}
 
+	// #Edit new lines: fields which may be null, e.g. nil pointers, don't have to be given either
+	if opts.Nullability == NullableExplicit && (s.Nullable || f.Type.Kind() == reflect.Ptr) {
+		optional = true
+	}
+
+	if opts.Nullability == NullableExplicit && optional { // #Edit new lines
+		// use the JSON Schema null type instead of the OpenAPI keyword
+		addNull(s)
+		s.Nullable = false
+	}
+
 	return name, optional, s, nil
 }
 
@@ -455,13 +657,24 @@ func GenerateFromField(f reflect.StructField, mode Mode) (string, bool, *Schema,
 // write-only field would not be included in read mode. If a schema is given
 // as input, add to it, otherwise creates a new schema.
 func GenerateWithMode(t reflect.Type, mode Mode, schema *Schema) (*Schema, error) {
+	return GenerateWithOptions(t, Options{Mode: mode}, schema) // #Edit new line
+}
+
+// GenerateWithOptions works like GenerateWithMode, using opts to configure
+// the mode and nullability of the generated schema.
+func GenerateWithOptions(t reflect.Type, opts Options, schema *Schema) (*Schema, error) { // #Edit new function
+	mode := opts.Mode
 	if schema == nil {
 		schema = &Schema{}
 	}
 
+	if s, ok := providedSchema(t); ok { // #Edit new lines
+		return s, nil
+	}
+
 	if t == ipType {
 		// Special case: IP address.
-		return &Schema{Type: []string{TypeString, "null"}, Format: "ipv4"}, nil // #Edit from: return &Schema{Type: TypeString, Format: "ipv4"}, nil
+		return &Schema{Type: opts.types(TypeString), Format: "ipv4"}, nil // #Edit from: return &Schema{Type: TypeString, Format: "ipv4"}, nil
 	}
 
 	switch t.Kind() {
@@ -469,18 +682,35 @@ func GenerateWithMode(t reflect.Type, mode Mode, schema *Schema) (*Schema, error
 		// Handle special cases.
 		switch t {
 		case timeType:
-			return &Schema{Type: []string{TypeString, "null"}, Format: "date-time"}, nil // #Edit from: return &Schema{Type: TypeString, Format: "date-time"}, nil
+			return &Schema{Type: opts.types(TypeString), Format: "date-time"}, nil // #Edit from: return &Schema{Type: TypeString, Format: "date-time"}, nil
 		case uriType:
-			return &Schema{Type: []string{TypeString, "null"}, Format: "uri"}, nil // #Edit from: return &Schema{Type: TypeString, Format: "uri"}, nil
+			return &Schema{Type: opts.types(TypeString), Format: "uri"}, nil // #Edit from: return &Schema{Type: TypeString, Format: "uri"}, nil
+		}
+
+		if opts.generating[t] { // #Edit new lines
+			return nil, fmt.Errorf("recursive type %s: %w", t, ErrSchemaInvalid)
 		}
+		if opts.generating == nil {
+			opts.generating = map[reflect.Type]bool{}
+		}
+		opts.generating[t] = true
+		defer delete(opts.generating, t)
 
 		properties := make(map[string]*Schema)
 		required := make([]string, 0)
-		schema.Type = []string{TypeObject, "null"} // #Edit from: schema.Type = TypeObject
+		schema.Type = opts.types(TypeObject) // #Edit from: schema.Type = TypeObject
 		schema.AdditionalProperties = false
 
+		// #Edit start: resolve fields sharing a name like encoding/json does
+		type candidate struct {
+			f        field
+			optional bool
+			s        *Schema
+		}
+		var names []string
+		candidates := map[string][]candidate{}
 		for _, f := range getFields(t) {
-			name, optional, s, err := GenerateFromField(f, mode)
+			name, optional, s, err := GenerateFromFieldWithOptions(f.StructField, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -490,11 +720,23 @@ func GenerateWithMode(t reflect.Type, mode Mode, schema *Schema) (*Schema, error
 				continue
 			}
 
-			if _, ok := properties[name]; ok {
-				// Item already exists, ignore it since we process embedded fields
-				// after top-level ones.
+			if _, ok := candidates[name]; !ok {
+				names = append(names, name)
+			}
+			candidates[name] = append(candidates[name], candidate{f: f, optional: optional || f.viaPointer, s: s})
+		}
+
+		for _, name := range names {
+			fields := make([]field, 0, len(candidates[name]))
+			for _, c := range candidates[name] {
+				fields = append(fields, c.f)
+			}
+			i, ok := dominantField(fields)
+			if !ok {
 				continue
 			}
+			optional, s := candidates[name][i].optional, candidates[name][i].s
+			// #Edit end
 
 			if s.ReadOnly && mode == ModeWrite {
 				continue
@@ -522,53 +764,67 @@ func GenerateWithMode(t reflect.Type, mode Mode, schema *Schema) (*Schema, error
 		return schema, nil // #Edit new line
 
 	case reflect.Map:
-		schema.Type = []string{TypeObject, "null"} // #Edit from: schema.Type = TypeObject
-		s, err := GenerateWithMode(t.Elem(), mode, nil)
+		schema.Type = opts.types(TypeObject) // #Edit from: schema.Type = TypeObject
+		s, err := GenerateWithOptions(t.Elem(), opts, nil)
 		if err != nil {
 			return nil, err
 		}
 		schema.AdditionalProperties = s
+		addNull(schema) // #Edit new line: nil maps are encoded as null
 	case reflect.Slice, reflect.Array:
-		if t.Elem().Kind() == reflect.Uint8 {
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 { // #Edit from: if t.Elem().Kind() == reflect.Uint8 { (byte arrays are encoded as json arrays)
 			// Special case: `[]byte` should be a Base-64 string.
-			schema.Type = []string{TypeString, "null"} // #Edit from: schema.Type = TypeString
+			schema.Type = opts.types(TypeString) // #Edit from: schema.Type = TypeString
 		} else {
-			schema.Type = []string{TypeArray, "null"} // #Edit from: schema.Type = TypeArray
-			s, err := GenerateWithMode(t.Elem(), mode, nil)
+			schema.Type = opts.types(TypeArray) // #Edit from: schema.Type = TypeArray
+			s, err := GenerateWithOptions(t.Elem(), opts, nil)
 			if err != nil {
 				return nil, err
 			}
 			schema.Items = s
 		}
+		if t.Kind() == reflect.Slice { // #Edit new lines: nil slices are encoded as null
+			addNull(schema)
+		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
-		schema.Type = []string{TypeInteger, "null"} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeInteger) // #Edit from: schema.Type = TypeInteger
 		schema.Format = "int32"
 	case reflect.Int64:
-		schema.Type = []string{TypeInteger, ""} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeInteger) // #Edit from: schema.Type = TypeInteger
 		schema.Format = "int64"
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
 		// Unsigned integers can't be negative.
-		schema.Type = []string{TypeInteger, "null"} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeInteger) // #Edit from: schema.Type = TypeInteger
 		schema.Format = "int32"
 		schema.Minimum = F(0.0)
 	case reflect.Uint64:
-		schema.Type = []string{TypeInteger, "null"} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeInteger) // #Edit from: schema.Type = TypeInteger
 		schema.Format = "int64"
 		schema.Minimum = F(0.0)
 	case reflect.Float32:
-		schema.Type = []string{TypeNumber, "null"} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeNumber) // #Edit from: schema.Type = TypeInteger
 		schema.Format = "float"
 	case reflect.Float64:
-		schema.Type = []string{TypeNumber, "null"} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeNumber) // #Edit from: schema.Type = TypeInteger
 		schema.Format = "double"
 	case reflect.Bool:
-		schema.Type = []string{TypeBoolean, "null"} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeBoolean) // #Edit from: schema.Type = TypeInteger
 	case reflect.String:
-		schema.Type = []string{TypeString, "null"} // #Edit from: schema.Type = TypeInteger
+		schema.Type = opts.types(TypeString) // #Edit from: schema.Type = TypeInteger
 	case reflect.Ptr:
-		return GenerateWithMode(t.Elem(), mode, schema)
+		s, err := GenerateWithOptions(t.Elem(), opts, schema) // #Edit from: return GenerateWithMode(t.Elem(), mode, schema)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Nullability == NullableExplicit {
+			addNull(s)
+		}
+		return s, nil
 	case reflect.Interface:
-		// Interfaces can be any type.
+		// Interfaces can be any type, unless their variants are registered.
+		if o, ok := lookupOneOf(t); ok { // #Edit new lines
+			return generateOneOf(o, opts, schema)
+		}
 	case reflect.Uintptr, reflect.UnsafePointer, reflect.Func:
 		// Ignored...
 	default:
@@ -0,0 +1,116 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+type Payment struct {
+	ID     int64    `json:"id"`
+	Amount float64  `json:"amount"`
+	Note   *string  `json:"note"`
+	Tags   []string `json:"tags,omitempty"`
+	Ref    string   `json:"ref" nullable:"true"`
+}
+
+func ExampleGenerateWithOptions() {
+	s, err := schema.GenerateWithOptions(reflect.TypeOf(Payment{}), schema.Options{
+		Nullability: schema.NullableExplicit,
+	}, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range []string{"id", "amount", "note", "tags", "ref"} {
+		b, _ := json.Marshal(s.Properties[name].Type)
+		fmt.Println(name, string(b))
+	}
+	fmt.Println(s.Required)
+
+	// Output:
+	// id ["integer"]
+	// amount ["number"]
+	// note ["string","null"]
+	// tags ["array","null"]
+	// ref ["string","null"]
+	// [id amount]
+}
+
+func TestGenerateFromField(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		field reflect.StructField
+		check func(*schema.Schema) bool
+		fails bool
+	}{
+		{"airbyte_secret", field(struct {
+			F string `airbyte_secret:"true"`
+		}{}), func(s *schema.Schema) bool { return s.AirbyteSecret }, false},
+		{"order", field(struct {
+			F string `order:"3"`
+		}{}), func(s *schema.Schema) bool { return s.Order != nil && *s.Order == 3 }, false},
+		{"title", field(struct {
+			F string `title:"Host"`
+		}{}), func(s *schema.Schema) bool { return s.Title == "Host" }, false},
+		{"group", field(struct {
+			F string `group:"auth"`
+		}{}), func(s *schema.Schema) bool { return s.Group == "auth" }, false},
+		{"airbyte_hidden", field(struct {
+			F string `airbyte_hidden:"true"`
+		}{}), func(s *schema.Schema) bool { return s.AirbyteHidden }, false},
+		{"always_show", field(struct {
+			F string `always_show:"true"`
+		}{}), func(s *schema.Schema) bool { return s.AlwaysShow }, false},
+		{"multiline", field(struct {
+			F string `multiline:"true"`
+		}{}), func(s *schema.Schema) bool { return s.Multiline }, false},
+		{"const string", field(struct {
+			F string `const:"oauth"`
+		}{}), func(s *schema.Schema) bool { return s.Const == "oauth" }, false},
+		{"const int", field(struct {
+			F int `const:"2"`
+		}{}), func(s *schema.Schema) bool { return s.Const == 2 }, false},
+		{"const interface", field(struct {
+			F interface{} `const:"oauth"`
+		}{}), func(s *schema.Schema) bool { return s.Const == "oauth" }, false},
+		{"const interface json", field(struct {
+			F interface{} `const:"2"`
+		}{}), func(s *schema.Schema) bool { return s.Const == 2.0 }, false},
+		{"invalid order", field(struct {
+			F string `order:"x"`
+		}{}), nil, true},
+		{"invalid const", field(struct {
+			F int `const:"x"`
+		}{}), nil, true},
+		{"invalid airbyte_secret", field(struct {
+			F string `airbyte_secret:"yes"`
+		}{}), nil, true},
+		{"invalid airbyte_hidden", field(struct {
+			F string `airbyte_hidden:"1"`
+		}{}), nil, true},
+		{"invalid always_show", field(struct {
+			F string `always_show:"on"`
+		}{}), nil, true},
+		{"invalid multiline", field(struct {
+			F string `multiline:""`
+		}{}), nil, true},
+	} {
+		_, _, s, err := schema.GenerateFromField(c.field, schema.ModeAll)
+		switch {
+		case c.fails && err == nil:
+			t.Errorf("%s: expected an error", c.name)
+		case !c.fails && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case !c.fails && !c.check(s):
+			t.Errorf("%s: unexpected schema %+v", c.name, s)
+		}
+	}
+}
+
+func field(v interface{}) reflect.StructField {
+	return reflect.TypeOf(v).Field(0)
+}
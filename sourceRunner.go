@@ -1,6 +1,8 @@
 package airbyte
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -20,6 +22,7 @@ func NewSourceRunner(src Source, w io.Writer) SourceRunner {
 		Record: newRecordWriter(w),
 		State:  newStateWriter(w),
 		Log:    newLogWriter(w),
+		Config: newConfigWriter(w),
 	}
 
 	return SourceRunner{
@@ -41,11 +44,20 @@ func NewSourceRunner(src Source, w io.Writer) SourceRunner {
 //  }
 // Yes, it really is that easy!
 func (sr SourceRunner) Start() error {
-	switch cmd(os.Args[1]) {
+	return sr.StartWithArgs(os.Args[1:])
+}
+
+// StartWithArgs starts your source with the given command line arguments (without the program name)
+// e.g. []string{"read", "--config", "config.json", "--catalog", "catalog.json"}
+// This is mostly useful to run your source in-process, see the airbytetest package
+func (sr SourceRunner) StartWithArgs(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expect a command")
+	}
+
+	switch cmd(args[0]) {
 	case cmdSpec:
-		spec, err := sr.src.Spec(LogTracker{
-			Log: sr.msgTracker.Log,
-		})
+		spec, err := sr.src.Spec(sr.logTracker())
 		if err != nil {
 			sr.msgTracker.Log(LogLevelError, "failed"+err.Error())
 			return err
@@ -56,13 +68,11 @@ func (sr SourceRunner) Start() error {
 		})
 
 	case cmdCheck:
-		inP, err := getSourceConfigPath()
+		inP, err := getSourceConfigPath(args)
 		if err != nil {
 			return err
 		}
-		err = sr.src.Check(inP, LogTracker{
-			Log: sr.msgTracker.Log,
-		})
+		err = sr.src.Check(inP, sr.logTracker())
 		if err != nil {
 			log.Println(err)
 			return write(sr.w, &message{
@@ -81,13 +91,11 @@ func (sr SourceRunner) Start() error {
 		})
 
 	case cmdDiscover:
-		inP, err := getSourceConfigPath()
+		inP, err := getSourceConfigPath(args)
 		if err != nil {
 			return err
 		}
-		ct, err := sr.src.Discover(inP, LogTracker{
-			Log: sr.msgTracker.Log},
-		)
+		ct, err := sr.src.Discover(inP, sr.logTracker())
 		if err != nil {
 			return err
 		}
@@ -97,34 +105,39 @@ func (sr SourceRunner) Start() error {
 		})
 
 	case cmdRead:
-		var incat ConfiguredCatalog
-		p, err := getCatalogPath()
+		srp, stp, incat, err := getReadArgs(args)
 		if err != nil {
 			return err
 		}
 
-		err = UnmarshalFromPath(p, &incat)
-		if err != nil {
-			return err
-		}
-
-		srp, err := getSourceConfigPath()
+		err = sr.src.Read(srp, stp, incat, sr.msgTracker)
 		if err != nil {
+			log.Println("failed")
 			return err
 		}
 
-		stp, err := getStatePath()
+	case cmdBench:
+		srp, stp, incat, err := getReadArgs(args)
 		if err != nil {
 			return err
 		}
 
-		err = sr.src.Read(srp, stp, &incat, sr.msgTracker)
+		report, err := Benchmark(sr.src, srp, stp, incat)
 		if err != nil {
-			log.Println("failed")
 			return err
 		}
+		enc := json.NewEncoder(sr.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
 
 	}
 
 	return nil
 }
+
+func (sr SourceRunner) logTracker() LogTracker {
+	return LogTracker{
+		Log:    sr.msgTracker.Log,
+		Config: sr.msgTracker.Config,
+	}
+}
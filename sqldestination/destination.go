@@ -0,0 +1,301 @@
+// Package sqldestination is a ready-made airbyte destination loading records into a database behind database/sql
+// Every stream is loaded into a table named after it in the schema of its namespace, with a column per property
+// of its json schema and EmittedAtColumn. Tables are created on the first sync and extended by the columns of
+// properties added later, columns are never dropped or changed
+//
+// Example config
+//
+//	{
+//		"dsn": "postgres://loader:secret@db:5432/warehouse?sslmode=require",
+//		"schema": "airbyte"
+//	}
+package sqldestination
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+// EmittedAtColumn is the time the source emitted a record in milliseconds since the epoch
+const EmittedAtColumn = "_airbyte_emitted_at"
+
+// tmpSuffix names the swap table of an overwritten stream and the staging table of a deduplicated stream
+const tmpSuffix = "_airbyte_tmp"
+
+// Destination is an airbyte.Destination loading every stream into a table, the driver has to be registered by
+// the connector
+//
+// Overwritten streams are loaded into a swap table which replaces the table once the sync succeeded, appended
+// streams are inserted into the table and deduplicated streams are merged into it batch by batch, keeping the
+// record with the greatest cursor of every primary key, the latest one without a cursor
+//
+// Example usage
+//
+//	import _ "github.com/lib/pq"
+//
+//	err := airbyte.NewDestinationRunner(sqldestination.Destination{Driver: "postgres"}, os.Stdin, os.Stdout).Start()
+type Destination struct {
+	// Driver is the name of the database/sql driver, e.g. "postgres", "mysql" or "sqlite3"
+	Driver string
+	// Dialect writes the statements, DialectFor(Driver) if nil
+	Dialect Dialect
+	// BatchSize is the number of records a stream buffers before they are inserted, 500 if 0
+	BatchSize int
+}
+
+var _ airbyte.Destination = Destination{}
+
+type Config struct {
+	DSN    string `json:"dsn" title:"Connection String" description:"data source name passed to the driver" order:"0" airbyte_secret:"true"`
+	Schema string `json:"schema,omitempty" title:"Default Schema" description:"schema of the tables of streams without a namespace, the default schema of the connection if empty" order:"1"`
+}
+
+// Spec returns the spec of Config
+func (d Destination) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecification, error) {
+	props, err := airbyte.InferSchemaFromStructWithOptions(Config{}, schema.Options{Nullability: schema.NullableExplicit}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+
+	return &airbyte.ConnectorSpecification{
+		SupportsIncremental: true,
+		SupportedDestinationSyncModes: []airbyte.DestinationSyncMode{
+			airbyte.DestinationSyncModeOverwrite,
+			airbyte.DestinationSyncModeAppend,
+			airbyte.DestinationSyncModeAppendDedup,
+		},
+		ConnectionSpecification: airbyte.ConnectionSpecification{
+			Title:       "Database",
+			Description: "Loads records into the tables of a database",
+			Type:        "object",
+			Required:    []airbyte.PropertyName{"dsn"},
+			Properties:  props,
+		},
+	}, nil
+}
+
+// Check fails unless the database can be reached
+func (d Destination) Check(dstCfgPath string, logTracker airbyte.LogTracker) error {
+	db, _, err := d.open(dstCfgPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.PingContext(context.Background())
+}
+
+// Open creates or extends the tables of the configured streams and the swap and staging tables
+func (d Destination) Open(dstCfgPath string, configuredCat *airbyte.ConfiguredCatalog,
+	logTracker airbyte.LogTracker) (airbyte.DestinationWriter, error) {
+	db, cfg, err := d.open(dstCfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &writer{
+		ctx:       context.Background(),
+		db:        db,
+		dialect:   d.dialect(),
+		batchSize: d.BatchSize,
+		streams:   map[[2]string]*stream{},
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = 500
+	}
+	for _, cs := range configuredCat.Streams {
+		s, err := w.prepare(cs, cfg.Schema, logTracker)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("stream %s: %w", cs.Stream.Name, err)
+		}
+		w.streams[[2]string{cs.Stream.Namespace, cs.Stream.Name}] = s
+		w.order = append(w.order, s)
+	}
+	return w, nil
+}
+
+// column is a column of a stream table
+type column struct {
+	name string
+	typ  airbyte.PropType
+}
+
+// columns returns EmittedAtColumn and a column per property in name order
+func columns(props map[airbyte.PropertyName]airbyte.PropertySpec) []column {
+	cols := []column{{name: EmittedAtColumn, typ: airbyte.Integer}}
+	for name, spec := range props {
+		if name != EmittedAtColumn {
+			cols = append(cols, column{name: string(name), typ: columnType(spec)})
+		}
+	}
+	sort.Slice(cols[1:], func(i, j int) bool {
+		return cols[i+1].name < cols[j+1].name
+	})
+	return cols
+}
+
+// columnType picks the type a property is stored as, values of properties with several types are stored as
+// strings and properties without a type as json
+func columnType(spec airbyte.PropertySpec) airbyte.PropType {
+	var types []airbyte.PropType
+	for _, t := range spec.Type {
+		if t != airbyte.Null {
+			types = append(types, t)
+		}
+	}
+	switch {
+	case len(types) == 0:
+		return airbyte.Object
+	case len(types) == 1:
+		return types[0]
+	case len(types) == 2 && hasType(types, airbyte.Integer) && hasType(types, airbyte.Number):
+		return airbyte.Number
+	}
+	return airbyte.String
+}
+
+func hasType(types []airbyte.PropType, t airbyte.PropType) bool {
+	for _, pt := range types {
+		if pt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// prepare creates the table of a stream or adds the columns it misses, and the swap or staging table
+func (w *writer) prepare(cs airbyte.ConfiguredStream, defaultSchema string, logTracker airbyte.LogTracker) (*stream, error) {
+	s := &stream{
+		mode:    cs.DestinationSyncMode,
+		columns: columns(cs.Stream.JSONSchema.Properties),
+	}
+	namespace := cs.Stream.Namespace
+	if namespace == "" {
+		namespace = defaultSchema
+	}
+	s.table = table{schema: namespace, name: cs.Stream.Name}
+	if !w.dialect.Schemas() && namespace != "" {
+		s.table = table{name: namespace + "_" + cs.Stream.Name}
+	}
+	s.tmp = table{schema: s.table.schema, name: s.table.name + tmpSuffix}
+
+	switch s.mode {
+	case airbyte.DestinationSyncModeOverwrite, airbyte.DestinationSyncModeAppend:
+	case airbyte.DestinationSyncModeAppendDedup:
+		if len(cs.PrimaryKey) == 0 {
+			return nil, errors.New("append_dedup needs a primary key")
+		}
+		for _, path := range cs.PrimaryKey {
+			if len(path) != 1 || s.column(path[0]) < 0 {
+				return nil, fmt.Errorf("primary key %s isn't a top level property", strings.Join(path, "."))
+			}
+			s.key = append(s.key, s.column(path[0]))
+		}
+		if len(cs.CursorField) > 1 || len(cs.CursorField) == 1 && s.column(cs.CursorField[0]) < 0 {
+			return nil, fmt.Errorf("cursor field %s isn't a top level property", strings.Join(cs.CursorField, "."))
+		}
+		s.cursor = -1
+		if len(cs.CursorField) == 1 {
+			s.cursor = s.column(cs.CursorField[0])
+		}
+	default:
+		return nil, fmt.Errorf("unsupported destination sync mode %q", s.mode)
+	}
+
+	if w.dialect.Schemas() && namespace != "" {
+		if _, err := w.db.ExecContext(w.ctx, "CREATE SCHEMA IF NOT EXISTS "+w.dialect.Quote(namespace)); err != nil {
+			return nil, err
+		}
+	}
+	if s.mode != airbyte.DestinationSyncModeAppend {
+		if err := w.create(s.tmp, s.columns, true); err != nil {
+			return nil, err
+		}
+	}
+	if s.mode == airbyte.DestinationSyncModeOverwrite {
+		return s, nil
+	}
+
+	if err := w.create(s.table, s.columns, false); err != nil {
+		return nil, err
+	}
+	existing, err := w.existing(s.table)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range s.columns {
+		if existing[c.name] {
+			continue
+		}
+		if err := logTracker.Log(airbyte.LogLevelInfo, fmt.Sprintf("adding column %s to %s", c.name, s.table.name)); err != nil {
+			return nil, err
+		}
+		stmt := "ALTER TABLE " + s.table.quoted(w.dialect) + " ADD COLUMN " + w.dialect.Quote(c.name) + " " + w.dialect.ColumnType(c.typ)
+		if _, err := w.db.ExecContext(w.ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// create creates a table with nullable columns, replace drops the table first
+func (w *writer) create(t table, cols []column, replace bool) error {
+	if replace {
+		if _, err := w.db.ExecContext(w.ctx, "DROP TABLE IF EXISTS "+t.quoted(w.dialect)); err != nil {
+			return err
+		}
+	}
+	defs := make([]string, len(cols))
+	for i, c := range cols {
+		defs[i] = w.dialect.Quote(c.name) + " " + w.dialect.ColumnType(c.typ)
+	}
+	_, err := w.db.ExecContext(w.ctx, "CREATE TABLE IF NOT EXISTS "+t.quoted(w.dialect)+" ("+strings.Join(defs, ", ")+")")
+	return err
+}
+
+// existing returns the names of the columns of a table
+func (w *writer) existing(t table) (map[string]bool, error) {
+	rows, err := w.db.QueryContext(w.ctx, "SELECT * FROM "+t.quoted(w.dialect)+" WHERE 1 = 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(names))
+	for _, n := range names {
+		existing[n] = true
+	}
+	return existing, nil
+}
+
+func (d Destination) dialect() Dialect {
+	if d.Dialect != nil {
+		return d.Dialect
+	}
+	return DialectFor(d.Driver)
+}
+
+func (d Destination) open(path string) (*sql.DB, *Config, error) {
+	var cfg Config
+	if err := airbyte.UnmarshalFromPath(path, &cfg); err != nil {
+		return nil, nil, err
+	}
+	if cfg.DSN == "" {
+		return nil, nil, errors.New("no dsn configured")
+	}
+	db, err := sql.Open(d.Driver, cfg.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, &cfg, nil
+}
@@ -0,0 +1,165 @@
+package sqldestination
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+	_ "modernc.org/sqlite"
+)
+
+func prop(types ...airbyte.PropType) airbyte.PropertySpec {
+	return airbyte.PropertySpec{PropertyType: airbyte.PropertyType{Type: types}}
+}
+
+func catalog() *airbyte.ConfiguredCatalog {
+	return &airbyte.ConfiguredCatalog{Streams: []airbyte.ConfiguredStream{
+		{
+			Stream: airbyte.Stream{Name: "users", JSONSchema: airbyte.Properties{Properties: map[airbyte.PropertyName]airbyte.PropertySpec{
+				"id": prop(airbyte.Integer), "tags": prop(airbyte.Array, airbyte.Null),
+			}}},
+			DestinationSyncMode: airbyte.DestinationSyncModeOverwrite,
+		},
+		{
+			Stream: airbyte.Stream{Name: "events", Namespace: "app", JSONSchema: airbyte.Properties{Properties: map[airbyte.PropertyName]airbyte.PropertySpec{
+				"kind": prop(airbyte.String),
+			}}},
+			DestinationSyncMode: airbyte.DestinationSyncModeAppend,
+		},
+		{
+			Stream: airbyte.Stream{Name: "accounts", JSONSchema: airbyte.Properties{Properties: map[airbyte.PropertyName]airbyte.PropertySpec{
+				"id": prop(airbyte.Integer), "name": prop(airbyte.String), "updated": prop(airbyte.Number), "active": prop(airbyte.Boolean),
+			}}},
+			DestinationSyncMode: airbyte.DestinationSyncModeAppendDedup,
+			PrimaryKey:          [][]string{{"id"}},
+			CursorField:         []string{"updated"},
+		},
+	}}
+}
+
+// messages returns the input of a sync, every line is a record of "stream data", a state of "STATE" or written
+// as is without a space
+func messages(lines ...string) *strings.Reader {
+	var b strings.Builder
+	for _, l := range lines {
+		if l == "STATE" {
+			b.WriteString(`{"type": "STATE", "state": {"data": {}}}` + "\n")
+			continue
+		}
+		parts := strings.SplitN(l, " ", 2)
+		if len(parts) == 1 {
+			b.WriteString(l + "\n")
+			continue
+		}
+		namespace := ""
+		if parts[0] == "events" {
+			namespace = "app"
+		}
+		fmt.Fprintf(&b, `{"type": "RECORD", "record": {"stream": %q, "namespace": %q, "data": %s, "emitted_at": 1}}`+"\n",
+			parts[0], namespace, parts[1])
+	}
+	return strings.NewReader(b.String())
+}
+
+func query(t *testing.T, db *sql.DB, q string) []string {
+	t.Helper()
+	rows, err := db.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	return got
+}
+
+func TestDestination(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "warehouse.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dst := Destination{Driver: "sqlite", BatchSize: 2}
+	config := map[string]interface{}{"dsn": dsn}
+
+	out, err := airbytetest.Write(dst, config, catalog(), messages(
+		`users {"id": 1, "tags": ["a"]}`,
+		`users {"id": 2}`,
+		`events {"kind": "click"}`,
+		`accounts {"id": 1, "name": "a", "updated": 2, "active": true}`,
+		`accounts {"id": 1, "name": "stale", "updated": 1}`,
+		`accounts {"id": 2, "name": "b", "updated": 1.5}`,
+		"STATE",
+		`accounts {"id": 3, "name": "c", "updated": 1}`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.States) != 1 {
+		t.Fatalf("expected the state to be passed on, got %d states", len(out.States))
+	}
+	if got := query(t, db, `SELECT id || ':' || COALESCE(tags, 'null') FROM users ORDER BY id`); !reflect.DeepEqual(got, []string{`1:["a"]`, "2:null"}) {
+		t.Errorf("unexpected users %v", got)
+	}
+	if got := query(t, db, `SELECT id || ':' || name || ':' || COALESCE(active, '') FROM accounts ORDER BY id`); !reflect.DeepEqual(got, []string{"1:a:1", "2:b:", "3:c:"}) {
+		t.Errorf("unexpected accounts %v", got)
+	}
+
+	// a failed sync keeps the overwritten table and the records before its last state
+	cat := catalog()
+	cat.Streams[1].Stream.JSONSchema.Properties["page"] = prop(airbyte.String, airbyte.Null)
+	_, err = airbytetest.Write(dst, config, cat, messages(
+		`events {"kind": "view", "page": "/"}`,
+		"STATE",
+		`users {"id": 3}`,
+		`accounts {"id": 1, "name": "old", "updated": 0}`,
+		`accounts {"id": 2, "name": "b2", "updated": 3}`,
+		"STATE",
+		`events {"kind": "lost"}`,
+		`{"type":`,
+	))
+	if err == nil {
+		t.Fatal("expected the broken message to fail the sync")
+	}
+	if got := query(t, db, `SELECT COUNT(*) FROM users`); got[0] != "2" {
+		t.Errorf("expected the users to be kept, got %s", got[0])
+	}
+	if got := query(t, db, `SELECT kind || ':' || COALESCE(page, '') FROM app_events ORDER BY rowid`); !reflect.DeepEqual(got, []string{"click:", "view:/"}) {
+		t.Errorf("unexpected events %v", got)
+	}
+	if got := query(t, db, `SELECT id || ':' || name FROM accounts ORDER BY id`); !reflect.DeepEqual(got, []string{"1:a", "2:b2", "3:c"}) {
+		t.Errorf("expected the newer account to win, got %v", got)
+	}
+	if got := query(t, db, `SELECT name FROM sqlite_master WHERE name LIKE '%_airbyte_tmp'`); len(got) != 0 {
+		t.Errorf("expected no swap or staging tables, got %v", got)
+	}
+
+	// the overwritten table is replaced once the sync succeeded
+	if _, err := airbytetest.Write(dst, config, catalog(), messages(`users {"id": 4}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := query(t, db, `SELECT id FROM users`); !reflect.DeepEqual(got, []string{"4"}) {
+		t.Errorf("unexpected users %v", got)
+	}
+}
+
+func TestDedupWithoutKey(t *testing.T) {
+	cat := catalog()
+	cat.Streams[2].PrimaryKey = [][]string{{"owner", "id"}}
+	config := map[string]interface{}{"dsn": filepath.Join(t.TempDir(), "warehouse.db")}
+	if _, err := airbytetest.Write(Destination{Driver: "sqlite"}, config, cat, messages()); err == nil {
+		t.Fatal("expected a nested primary key to fail")
+	}
+}
@@ -0,0 +1,88 @@
+package sqldestination
+
+import (
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/sqlsource"
+)
+
+// Dialect writes the statements of a database, it quotes identifiers and binds parameters like sqlsource.Dialect
+type Dialect interface {
+	sqlsource.Dialect
+	// ColumnType returns the type of the columns values of a json schema type are stored in
+	ColumnType(t airbyte.PropType) string
+	// Schemas reports whether the database has schemas, tables of namespaced streams are prefixed with the
+	// namespace otherwise
+	Schemas() bool
+	// Rename returns the statement renaming a table of a schema, an empty schema is the default one
+	Rename(schema, from, to string) string
+}
+
+var (
+	// Postgres stores numbers as NUMERIC and objects and arrays as JSONB
+	Postgres Dialect = dialect{
+		Dialect: sqlsource.Postgres,
+		types: map[airbyte.PropType]string{
+			airbyte.String: "TEXT", airbyte.Integer: "BIGINT", airbyte.Number: "NUMERIC", airbyte.Boolean: "BOOLEAN",
+			airbyte.Object: "JSONB", airbyte.Array: "JSONB",
+		},
+		schemas: true,
+	}
+	// MySQL stores numbers as DOUBLE and objects and arrays as JSON, schemas are databases
+	MySQL Dialect = dialect{
+		Dialect: sqlsource.MySQL,
+		types: map[airbyte.PropType]string{
+			airbyte.String: "TEXT", airbyte.Integer: "BIGINT", airbyte.Number: "DOUBLE", airbyte.Boolean: "BOOLEAN",
+			airbyte.Object: "JSON", airbyte.Array: "JSON",
+		},
+		schemas:       true,
+		qualifyRename: true,
+	}
+	// SQLite stores objects and arrays as json text, it has no schemas
+	SQLite Dialect = dialect{
+		Dialect: sqlsource.SQLite,
+		types: map[airbyte.PropType]string{
+			airbyte.String: "TEXT", airbyte.Integer: "INTEGER", airbyte.Number: "REAL", airbyte.Boolean: "BOOLEAN",
+			airbyte.Object: "TEXT", airbyte.Array: "TEXT",
+		},
+	}
+)
+
+// DialectFor returns the dialect of a database/sql driver name, Postgres for drivers it doesn't know
+func DialectFor(driver string) Dialect {
+	switch driver {
+	case "mysql":
+		return MySQL
+	case "sqlite", "sqlite3":
+		return SQLite
+	}
+	return Postgres
+}
+
+type dialect struct {
+	sqlsource.Dialect
+	types   map[airbyte.PropType]string
+	schemas bool
+	// qualifyRename is set if the new name of a renamed table needs its schema, it's in the default schema otherwise
+	qualifyRename bool
+}
+
+func (d dialect) ColumnType(t airbyte.PropType) string {
+	return d.types[t]
+}
+
+func (d dialect) Schemas() bool {
+	return d.schemas
+}
+
+func (d dialect) Rename(schema, from, to string) string {
+	qualify := func(name string) string {
+		if schema == "" {
+			return d.Quote(name)
+		}
+		return d.Quote(schema) + "." + d.Quote(name)
+	}
+	if d.qualifyRename {
+		return "ALTER TABLE " + qualify(from) + " RENAME TO " + qualify(to)
+	}
+	return "ALTER TABLE " + qualify(from) + " RENAME TO " + d.Quote(to)
+}
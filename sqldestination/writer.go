@@ -0,0 +1,354 @@
+package sqldestination
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// maxParams is the most parameters a statement binds, the lowest limit of the supported databases which is sqlite's
+const maxParams = 32766
+
+type table struct {
+	schema string
+	name   string
+}
+
+func (t table) quoted(d Dialect) string {
+	if t.schema == "" {
+		return d.Quote(t.name)
+	}
+	return d.Quote(t.schema) + "." + d.Quote(t.name)
+}
+
+// col is the qualified name of a column of the table
+func (t table) col(d Dialect, name string) string {
+	return t.quoted(d) + "." + d.Quote(name)
+}
+
+// stream buffers the rows of a stream until they are inserted in a batch
+type stream struct {
+	mode    airbyte.DestinationSyncMode
+	table   table
+	tmp     table
+	columns []column
+	// key and cursor are the indexes of the primary key and cursor columns of a deduplicated stream,
+	// cursor is -1 without a cursor
+	key    []int
+	cursor int
+
+	rows [][]interface{}
+	// keys indexes the buffered rows of a deduplicated stream by primary key
+	keys map[string]int
+}
+
+func (s *stream) column(name string) int {
+	for i, c := range s.columns {
+		if c.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// add buffers a row, a deduplicated stream keeps the row with the greatest cursor of every primary key
+// Rows with a null in their key are never deduplicated
+func (s *stream) add(row []interface{}) {
+	if s.mode != airbyte.DestinationSyncModeAppendDedup {
+		s.rows = append(s.rows, row)
+		return
+	}
+
+	key := make([]interface{}, len(s.key))
+	for i, k := range s.key {
+		if row[k] == nil {
+			s.rows = append(s.rows, row)
+			return
+		}
+		key[i] = row[k]
+	}
+	b, _ := json.Marshal(key)
+	if s.keys == nil {
+		s.keys = map[string]int{}
+	}
+	if i, ok := s.keys[string(b)]; ok {
+		if s.cursor < 0 || compare(row[s.cursor], s.rows[i][s.cursor], s.columns[s.cursor].typ) >= 0 {
+			s.rows[i] = row
+		}
+		return
+	}
+	s.keys[string(b)] = len(s.rows)
+	s.rows = append(s.rows, row)
+}
+
+// writer loads the records of a sync, the records since the last state are written in a transaction which is
+// committed by Flush
+type writer struct {
+	ctx       context.Context
+	db        *sql.DB
+	dialect   Dialect
+	batchSize int
+	streams   map[[2]string]*stream
+	order     []*stream
+
+	tx *sql.Tx
+}
+
+func (w *writer) Write(data json.RawMessage, streamName string, namespace string, emittedAt int64) error {
+	s, ok := w.streams[[2]string{namespace, streamName}]
+	if !ok {
+		return fmt.Errorf("record of stream %s which isn't configured", streamName)
+	}
+
+	var rec map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&rec); err != nil {
+		return fmt.Errorf("record of stream %s: %w", streamName, err)
+	}
+	row := make([]interface{}, len(s.columns))
+	row[0] = emittedAt
+	for i := 1; i < len(s.columns); i++ {
+		v, err := value(rec[s.columns[i].name], s.columns[i].typ)
+		if err != nil {
+			return err
+		}
+		row[i] = v
+	}
+	s.add(row)
+
+	if len(s.rows) >= w.batchSize {
+		return w.insert(s)
+	}
+	return nil
+}
+
+// Flush inserts the buffered rows and commits the transaction
+func (w *writer) Flush() error {
+	for _, s := range w.order {
+		if err := w.insert(s); err != nil {
+			return err
+		}
+	}
+	if w.tx == nil {
+		return nil
+	}
+	err := w.tx.Commit()
+	w.tx = nil
+	return err
+}
+
+// Close commits the rows since the last state and replaces the tables of overwritten streams by their swap
+// tables if the sync succeeded, it rolls the rows back otherwise
+func (w *writer) Close(succeeded bool) error {
+	defer w.db.Close()
+	if succeeded {
+		if err := w.Flush(); err != nil {
+			w.rollback()
+			return err
+		}
+		for _, s := range w.order {
+			if s.mode == airbyte.DestinationSyncModeOverwrite {
+				if err := w.swap(s); err != nil {
+					w.rollback()
+					return err
+				}
+			}
+		}
+	} else {
+		w.rollback()
+	}
+
+	var err error
+	for _, s := range w.order {
+		if s.mode != airbyte.DestinationSyncModeAppend {
+			if _, derr := w.db.ExecContext(w.ctx, "DROP TABLE IF EXISTS "+s.tmp.quoted(w.dialect)); err == nil && succeeded {
+				err = derr
+			}
+		}
+	}
+	return err
+}
+
+func (w *writer) rollback() {
+	if w.tx != nil {
+		w.tx.Rollback()
+		w.tx = nil
+	}
+}
+
+// swap drops the table of an overwritten stream and renames its swap table
+func (w *writer) swap(s *stream) error {
+	tx, err := w.db.BeginTx(w.ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(w.ctx, "DROP TABLE IF EXISTS "+s.table.quoted(w.dialect)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(w.ctx, w.dialect.Rename(s.tmp.schema, s.tmp.name, s.table.name)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// insert inserts the buffered rows of a stream with as few statements as the parameter limit allows, the rows
+// of a deduplicated stream are inserted into its staging table and merged into its table
+func (w *writer) insert(s *stream) error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+	if w.tx == nil {
+		tx, err := w.db.BeginTx(w.ctx, nil)
+		if err != nil {
+			return err
+		}
+		w.tx = tx
+	}
+
+	target := s.tmp
+	if s.mode == airbyte.DestinationSyncModeAppend {
+		target = s.table
+	}
+	names := w.names(s)
+	per := maxParams / len(s.columns)
+	for start := 0; start < len(s.rows); start += per {
+		end := start + per
+		if end > len(s.rows) {
+			end = len(s.rows)
+		}
+
+		var b strings.Builder
+		b.WriteString("INSERT INTO " + target.quoted(w.dialect) + " (" + names + ") VALUES ")
+		args := make([]interface{}, 0, (end-start)*len(s.columns))
+		for i, row := range s.rows[start:end] {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteByte('(')
+			for j, v := range row {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				args = append(args, v)
+				b.WriteString(w.dialect.Placeholder(len(args)))
+			}
+			b.WriteByte(')')
+		}
+		if _, err := w.tx.ExecContext(w.ctx, b.String(), args...); err != nil {
+			return fmt.Errorf("insert into %s: %w", target.name, err)
+		}
+	}
+	s.rows = s.rows[:0]
+	s.keys = nil
+
+	if s.mode == airbyte.DestinationSyncModeAppendDedup {
+		return w.merge(s)
+	}
+	return nil
+}
+
+// merge moves the staged rows of a deduplicated stream into its table, replacing the rows with the same primary
+// key unless they have a greater cursor
+func (w *writer) merge(s *stream) error {
+	d := w.dialect
+	tbl, tmp := s.table.quoted(d), s.tmp.quoted(d)
+	match := make([]string, len(s.key))
+	for i, k := range s.key {
+		match[i] = s.table.col(d, s.columns[k].name) + " = " + s.tmp.col(d, s.columns[k].name)
+	}
+	on := strings.Join(match, " AND ")
+
+	var stmts []string
+	if s.cursor >= 0 {
+		cursor := s.columns[s.cursor].name
+		stmts = append(stmts, "DELETE FROM "+tmp+" WHERE EXISTS (SELECT 1 FROM "+tbl+" WHERE "+on+
+			" AND "+s.table.col(d, cursor)+" > "+s.tmp.col(d, cursor)+")")
+	}
+	names := w.names(s)
+	stmts = append(stmts,
+		"DELETE FROM "+tbl+" WHERE EXISTS (SELECT 1 FROM "+tmp+" WHERE "+on+")",
+		"INSERT INTO "+tbl+" ("+names+") SELECT "+names+" FROM "+tmp,
+		"DELETE FROM "+tmp,
+	)
+	for _, stmt := range stmts {
+		if _, err := w.tx.ExecContext(w.ctx, stmt); err != nil {
+			return fmt.Errorf("merge into %s: %w", s.table.name, err)
+		}
+	}
+	return nil
+}
+
+func (w *writer) names(s *stream) string {
+	names := make([]string, len(s.columns))
+	for i, c := range s.columns {
+		names[i] = w.dialect.Quote(c.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// value converts a json value to the parameter of a column of the type, objects, arrays and values which don't
+// match their type are stored as json
+func value(v interface{}, typ airbyte.PropType) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		switch typ {
+		case airbyte.Integer:
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+			if f, err := v.Float64(); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+				return int64(f), nil
+			}
+		case airbyte.Number:
+			return string(v), nil
+		}
+	case bool:
+		if typ == airbyte.Boolean {
+			return v, nil
+		}
+	case string:
+		if typ == airbyte.String {
+			return v, nil
+		}
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// compare orders the values of a cursor column, nulls come first
+func compare(a, b interface{}, typ airbyte.PropType) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if typ == airbyte.Integer || typ == airbyte.Number {
+		x, xerr := strconv.ParseFloat(fmt.Sprint(a), 64)
+		y, yerr := strconv.ParseFloat(fmt.Sprint(b), 64)
+		if xerr == nil && yerr == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,205 @@
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Column is a column of a table or view
+type Column struct {
+	// Schema is the schema of the table, it's the namespace of its stream. Empty for databases without schemas
+	Schema string
+	Table  string
+	Name   string
+	// Type is the database type of the column, e.g. "character varying" or "timestamp with time zone"
+	Type     string
+	Nullable bool
+	// PrimaryKey is the position of the column in the primary key of the table starting at 1, 0 if it isn't part of it
+	PrimaryKey int
+}
+
+// Dialect discovers the tables of a database and writes the queries which read them
+type Dialect interface {
+	// Columns returns the columns of every table and view of the schemas in table and column order,
+	// every schema but the system ones if schemas is empty
+	Columns(ctx context.Context, db *sql.DB, schemas []string) ([]Column, error)
+	// Quote quotes an identifier
+	Quote(ident string) string
+	// Placeholder returns the bind parameter n of a query starting at 1, e.g. $1 or ?
+	Placeholder(n int) string
+}
+
+var (
+	// Postgres discovers tables through information_schema, it also works for CockroachDB and Redshift
+	Postgres Dialect = informationSchema{quote: `"`, placeholder: func(n int) string { return fmt.Sprintf("$%d", n) }}
+	// MySQL discovers tables through information_schema, schemas are databases
+	MySQL Dialect = informationSchema{quote: "`", placeholder: func(int) string { return "?" }}
+	// SQLServer discovers tables through information_schema
+	SQLServer Dialect = informationSchema{quote: `"`, placeholder: func(n int) string { return fmt.Sprintf("@p%d", n) }}
+	// SQLite discovers the tables of the main database through sqlite_master and table_info, it has no schemas
+	SQLite Dialect = sqlite{}
+)
+
+// DialectFor returns the dialect of a database/sql driver name, Postgres for drivers it doesn't know
+func DialectFor(driver string) Dialect {
+	switch driver {
+	case "mysql":
+		return MySQL
+	case "sqlserver", "mssql":
+		return SQLServer
+	case "sqlite", "sqlite3":
+		return SQLite
+	}
+	return Postgres
+}
+
+// systemSchemas aren't discovered unless they are configured
+var systemSchemas = []string{"information_schema", "pg_catalog", "mysql", "performance_schema", "sys"}
+
+type informationSchema struct {
+	quote       string
+	placeholder func(n int) string
+}
+
+func (d informationSchema) Columns(ctx context.Context, db *sql.DB, schemas []string) ([]Column, error) {
+	filter, args := d.schemaFilter("c.table_schema", schemas)
+	rows, err := db.QueryContext(ctx, `SELECT c.table_schema, c.table_name, c.column_name, c.data_type, c.is_nullable
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE t.table_type IN ('BASE TABLE', 'VIEW') AND `+filter+`
+		ORDER BY c.table_schema, c.table_name, c.ordinal_position`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("columns: %w", err)
+	}
+	defer rows.Close()
+
+	var cols []Column
+	for rows.Next() {
+		var c Column
+		var nullable string
+		if err := rows.Scan(&c.Schema, &c.Table, &c.Name, &c.Type, &nullable); err != nil {
+			return nil, err
+		}
+		c.Nullable = nullable == "YES"
+		cols = append(cols, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	filter, args = d.schemaFilter("kcu.table_schema", schemas)
+	rows, err = db.QueryContext(ctx, `SELECT kcu.table_schema, kcu.table_name, kcu.column_name, kcu.ordinal_position
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = tc.constraint_schema
+			AND kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema AND kcu.table_name = tc.table_name
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND `+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("primary keys: %w", err)
+	}
+	defer rows.Close()
+
+	pks := map[[3]string]int{}
+	for rows.Next() {
+		var schema, table, column string
+		var pos int
+		if err := rows.Scan(&schema, &table, &column, &pos); err != nil {
+			return nil, err
+		}
+		pks[[3]string{schema, table, column}] = pos
+	}
+	for i, c := range cols {
+		cols[i].PrimaryKey = pks[[3]string{c.Schema, c.Table, c.Name}]
+	}
+	return cols, rows.Err()
+}
+
+// schemaFilter returns the condition on the schema column of a discovery query and its arguments
+func (d informationSchema) schemaFilter(column string, schemas []string) (string, []interface{}) {
+	not := ""
+	if len(schemas) == 0 {
+		not, schemas = "NOT ", systemSchemas
+	}
+	params := make([]string, len(schemas))
+	args := make([]interface{}, len(schemas))
+	for i, s := range schemas {
+		params[i] = d.placeholder(i + 1)
+		args[i] = s
+	}
+	return fmt.Sprintf("%s %sIN (%s)", column, not, strings.Join(params, ", ")), args
+}
+
+func (d informationSchema) Quote(ident string) string {
+	return d.quote + strings.ReplaceAll(ident, d.quote, d.quote+d.quote) + d.quote
+}
+
+func (d informationSchema) Placeholder(n int) string {
+	return d.placeholder(n)
+}
+
+type sqlite struct{}
+
+func (sqlite) Columns(ctx context.Context, db *sql.DB, schemas []string) ([]Column, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master
+		WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var cols []Column
+	for _, t := range tables {
+		rows, err := db.QueryContext(ctx, `SELECT name, type, "notnull", pk FROM pragma_table_info(?) ORDER BY cid`, t)
+		if err != nil {
+			return nil, fmt.Errorf("columns of %s: %w", t, err)
+		}
+		var table []Column
+		pk, keys := -1, 0
+		for rows.Next() {
+			c := Column{Table: t}
+			var notNull bool
+			if err := rows.Scan(&c.Name, &c.Type, &notNull, &c.PrimaryKey); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			c.Nullable = !notNull
+			table = append(table, c)
+			if c.PrimaryKey > 0 {
+				keys++
+				pk = len(table) - 1
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		// only a single column INTEGER PRIMARY KEY is the rowid, which is never null, other primary key columns
+		// take nulls like any column
+		if keys == 1 && strings.EqualFold(table[pk].Type, "INTEGER") {
+			table[pk].Nullable = false
+		}
+		cols = append(cols, table...)
+	}
+	return cols, nil
+}
+
+func (sqlite) Quote(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
+
+func (sqlite) Placeholder(int) string {
+	return "?"
+}
@@ -0,0 +1,392 @@
+// Package sqlsource is a ready-made airbyte source for relational databases behind database/sql
+// Every table and view of the configured schemas is a stream named after the table in the namespace of its schema,
+// incremental syncs read the rows whose cursor column is at or after the cursor of the previous sync
+//
+// Example config
+//
+//	{
+//		"dsn": "postgres://reader:secret@db:5432/shop?sslmode=require",
+//		"schemas": ["public", "billing"]
+//	}
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/schema"
+)
+
+// Source is an airbyte.Source reading the tables of a database, the driver has to be registered by the connector
+//
+// Example usage
+//
+//	import _ "github.com/lib/pq"
+//
+//	err := airbyte.NewSourceRunner(sqlsource.Source{Driver: "postgres"}, os.Stdout).Start()
+type Source struct {
+	// Driver is the name of the database/sql driver, e.g. "postgres", "mysql" or "sqlite3"
+	Driver string
+	// Dialect discovers the tables and writes the queries, DialectFor(Driver) if nil
+	Dialect Dialect
+	// CheckpointRows is the number of rows of an incremental stream after which the state is emitted, 10000 if 0
+	// The state is emitted after the last row as well
+	CheckpointRows int
+	// Workers is the number of tables read at once, see airbyte.StreamScheduler
+	Workers int
+}
+
+var _ airbyte.Source = Source{}
+
+type Config struct {
+	DSN     string   `json:"dsn" title:"Connection String" description:"data source name passed to the driver" order:"0" airbyte_secret:"true"`
+	Schemas []string `json:"schemas,omitempty" title:"Schemas" description:"schemas whose tables are synced, every schema but the system ones if empty" order:"1"`
+}
+
+// State keeps the cursor of every incremental stream by namespace and stream name, see StateKey
+type State map[string]StreamState
+
+// StreamState is the greatest value of the cursor column read by a stream
+// Rows are read in cursor order, the next sync reads the rows at or after the cursor so rows committed late with
+// the cursor of the last row aren't missed. Those rows are read twice, a primary key dedups them
+type StreamState struct {
+	CursorField string `json:"cursor_field"`
+	Cursor      string `json:"cursor"`
+}
+
+// StateKey is the key of the state of a stream, namespace.name or name without a namespace
+func StateKey(s airbyte.Stream) string {
+	if s.Namespace == "" {
+		return s.Name
+	}
+	return s.Namespace + "." + s.Name
+}
+
+// Spec returns the spec of Config
+func (s Source) Spec(logTracker airbyte.LogTracker) (*airbyte.ConnectorSpecification, error) {
+	props, err := airbyte.InferSchemaFromStructWithOptions(Config{}, schema.Options{Nullability: schema.NullableExplicit}, logTracker)
+	if err != nil {
+		return nil, err
+	}
+
+	return &airbyte.ConnectorSpecification{
+		SupportsIncremental: true,
+		SupportedDestinationSyncModes: []airbyte.DestinationSyncMode{
+			airbyte.DestinationSyncModeOverwrite,
+			airbyte.DestinationSyncModeAppend,
+		},
+		ConnectionSpecification: airbyte.ConnectionSpecification{
+			Title:       "Database",
+			Description: "Reads the tables of a database",
+			Type:        "object",
+			Required:    []airbyte.PropertyName{"dsn"},
+			Properties:  props,
+		},
+	}, nil
+}
+
+// Check fails unless the database can be reached
+func (s Source) Check(srcCfgPath string, logTracker airbyte.LogTracker) error {
+	db, _, err := s.open(srcCfgPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.PingContext(context.Background())
+}
+
+// Discover returns a stream for every table and view, the primary key of a table is the primary key of its stream
+// Any column can be the cursor of an incremental sync
+func (s Source) Discover(srcCfgPath string, logTracker airbyte.LogTracker) (*airbyte.Catalog, error) {
+	db, cfg, err := s.open(srcCfgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tables, err := s.tables(context.Background(), db, cfg)
+	if err != nil {
+		return nil, err
+	}
+	cat := &airbyte.Catalog{}
+	for _, t := range tables {
+		cat.Streams = append(cat.Streams, t.stream())
+	}
+	return cat, nil
+}
+
+// Read reads the configured tables, incremental streams only read the rows at or after their cursor
+func (s Source) Read(sourceCfgPath string, prevStatePath string, configuredCat *airbyte.ConfiguredCatalog,
+	tracker airbyte.MessageTracker) error {
+	db, cfg, err := s.open(sourceCfgPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	state := State{}
+	if prevStatePath != "" {
+		if err := airbyte.UnmarshalFromPath(prevStatePath, &state); err != nil {
+			return err
+		}
+		if state == nil {
+			state = State{}
+		}
+	}
+
+	ctx := context.Background()
+	discovered, err := s.tables(ctx, db, cfg)
+	if err != nil {
+		return err
+	}
+	tables := make(map[string]*table, len(discovered))
+	for _, t := range discovered {
+		tables[StateKey(t.stream())] = t
+	}
+	for _, cs := range configuredCat.Streams {
+		if tables[StateKey(cs.Stream)] == nil {
+			return fmt.Errorf("stream %s: no such table", StateKey(cs.Stream))
+		}
+	}
+
+	states := make(map[string]interface{}, len(state))
+	for key, ss := range state {
+		states[key] = ss
+	}
+	cp := airbyte.NewCheckpointer(states, tracker.State)
+	sched := airbyte.StreamScheduler{Workers: s.Workers, Log: tracker.Log}
+	return sched.Run(ctx, configuredCat.Streams, func(ctx context.Context, cs airbyte.ConfiguredStream) error {
+		return s.read(ctx, db, tables[StateKey(cs.Stream)], cs, cp, tracker)
+	})
+}
+
+func (s Source) read(ctx context.Context, db *sql.DB, t *table, cs airbyte.ConfiguredStream, cp *airbyte.Checkpointer,
+	tracker airbyte.MessageTracker) error {
+	d := s.dialect()
+	key := StateKey(cs.Stream)
+	cols := t.selected(cs.Stream.JSONSchema.Properties)
+
+	var cur *column
+	var args []interface{}
+	var where, order string
+	ss, _ := cp.Get(key).(StreamState)
+	if cs.SyncMode == airbyte.SyncModeIncremental {
+		if len(cs.CursorField) != 1 {
+			return errors.New("incremental syncs need a cursor field")
+		}
+		if cur = t.column(cs.CursorField[0]); cur == nil {
+			return fmt.Errorf("cursor field %s is not a column", cs.CursorField[0])
+		}
+		if !containsColumn(cols, cur) {
+			cols = append(cols, cur)
+		}
+		if ss.CursorField != cur.Name {
+			if ss.CursorField != "" {
+				msg := fmt.Sprintf("stream %s: the cursor field changed from %s to %s, reading every row", key, ss.CursorField, cur.Name)
+				if err := tracker.Log(airbyte.LogLevelWarn, msg); err != nil {
+					return err
+				}
+			}
+			ss = StreamState{CursorField: cur.Name}
+		}
+		if ss.Cursor != "" {
+			v, err := cur.kind.bind(ss.Cursor)
+			if err != nil {
+				return fmt.Errorf("cursor %q: %w", ss.Cursor, err)
+			}
+			where = " WHERE " + d.Quote(cur.Name) + " >= " + d.Placeholder(1)
+			args = append(args, v)
+		}
+		order = " ORDER BY " + d.Quote(cur.Name)
+	}
+
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = d.Quote(c.Name)
+	}
+	query := "SELECT " + strings.Join(names, ", ") + " FROM " + t.quoted(d) + where + order
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	every := s.CheckpointRows
+	if every <= 0 {
+		every = 10000
+	}
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for n := 1; rows.Next(); n++ {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		rec := make(map[string]interface{}, len(cols))
+		for i, c := range cols {
+			rec[c.Name] = c.kind.value(values[i])
+		}
+		if err := tracker.Record(rec, cs.Stream.Name, cs.Stream.Namespace); err != nil {
+			return err
+		}
+
+		if cur == nil {
+			continue
+		}
+		if v := rec[cur.Name]; v != nil {
+			ss.Cursor = cursor(v)
+		}
+		if n%every == 0 && ss.Cursor != "" {
+			if err := cp.Set(key, ss); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if cur != nil && ss.Cursor != "" {
+		return cp.Set(key, ss)
+	}
+	return nil
+}
+
+// table is a discovered table or view
+type table struct {
+	schema  string
+	name    string
+	columns []*column
+}
+
+type column struct {
+	Column
+	kind kind
+}
+
+func (t *table) stream() airbyte.Stream {
+	props := make(map[airbyte.PropertyName]airbyte.PropertySpec, len(t.columns))
+	var pk []*column
+	for _, c := range t.columns {
+		props[airbyte.PropertyName(c.Name)] = c.kind.spec(c.Nullable)
+		if c.PrimaryKey > 0 {
+			pk = append(pk, c)
+		}
+	}
+	// columns are ordered by table position, the key by key position
+	keys := make([][]string, len(pk))
+	for _, c := range pk {
+		if c.PrimaryKey <= len(pk) {
+			keys[c.PrimaryKey-1] = []string{c.Name}
+		}
+	}
+	for _, k := range keys {
+		if k == nil {
+			keys = nil
+			break
+		}
+	}
+
+	return airbyte.Stream{
+		Name:       t.name,
+		Namespace:  t.schema,
+		JSONSchema: airbyte.Properties{Properties: props},
+		SupportedSyncModes: []airbyte.SyncMode{
+			airbyte.SyncModeFullRefresh,
+			airbyte.SyncModeIncremental,
+		},
+		SourceDefinedPrimaryKey: keys,
+	}
+}
+
+func (t *table) column(name string) *column {
+	for _, c := range t.columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// selected returns the columns in the configured schema, every column if the schema has no properties
+func (t *table) selected(props map[airbyte.PropertyName]airbyte.PropertySpec) []*column {
+	if len(props) == 0 {
+		return t.columns
+	}
+	var cols []*column
+	for _, c := range t.columns {
+		if _, ok := props[airbyte.PropertyName(c.Name)]; ok {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+func (t *table) quoted(d Dialect) string {
+	if t.schema == "" {
+		return d.Quote(t.name)
+	}
+	return d.Quote(t.schema) + "." + d.Quote(t.name)
+}
+
+func containsColumn(cols []*column, c *column) bool {
+	for _, x := range cols {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// tables groups the discovered columns by table
+func (s Source) tables(ctx context.Context, db *sql.DB, cfg *Config) ([]*table, error) {
+	cols, err := s.dialect().Columns(ctx, db, cfg.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	var tables []*table
+	for _, c := range cols {
+		if len(tables) == 0 || tables[len(tables)-1].schema != c.Schema || tables[len(tables)-1].name != c.Table {
+			tables = append(tables, &table{schema: c.Schema, name: c.Table})
+		}
+		t := tables[len(tables)-1]
+		t.columns = append(t.columns, &column{Column: c, kind: kindOf(c.Type)})
+	}
+	return tables, nil
+}
+
+func (s Source) dialect() Dialect {
+	if s.Dialect != nil {
+		return s.Dialect
+	}
+	return DialectFor(s.Driver)
+}
+
+func (s Source) open(path string) (*sql.DB, *Config, error) {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open(s.Driver, cfg.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, cfg, nil
+}
+
+func loadConfig(path string) (*Config, error) {
+	var cfg Config
+	if err := airbyte.UnmarshalFromPath(path, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.DSN == "" {
+		return nil, errors.New("no dsn configured")
+	}
+	return &cfg, nil
+}
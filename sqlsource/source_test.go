@@ -0,0 +1,222 @@
+package sqlsource
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+	"github.com/bitstrapped/airbyte/airbytetest"
+	_ "modernc.org/sqlite"
+)
+
+func exec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSource(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "shop.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exec(t, db, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, score NUMERIC(5, 2), active BOOLEAN,
+		updated_at DATETIME, avatar BLOB)`)
+	exec(t, db, `CREATE TABLE memberships (user_id INTEGER, group_id INTEGER, role VARCHAR(20), PRIMARY KEY (group_id, user_id))`)
+	exec(t, db, `CREATE VIEW active_users AS SELECT id, name FROM users WHERE active`)
+	jan := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"ada", "bob", "cy"} {
+		exec(t, db, `INSERT INTO users VALUES (?, ?, ?, ?, ?, ?)`, i+1, name, 1.5, i != 1, jan.AddDate(0, 0, i), []byte{1})
+	}
+	exec(t, db, `INSERT INTO memberships VALUES (1, 10, 'owner'), (2, 10, NULL)`)
+
+	src := Source{Driver: "sqlite"}
+	config := map[string]interface{}{"dsn": dsn}
+	out, err := airbytetest.Check(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ConnectionStatus != airbytetest.StatusSucceeded {
+		t.Fatalf("expected the check to pass, got %v", out.Logs)
+	}
+
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range disc.Catalog.Streams {
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"active_users", "memberships", "users"}) {
+		t.Fatalf("unexpected streams %v", names)
+	}
+	users := disc.Catalog.Streams[2]
+	for field, want := range map[airbyte.PropertyName]airbyte.PropertyType{
+		"id":         {Type: []airbyte.PropType{airbyte.Integer}},
+		"name":       {Type: []airbyte.PropType{airbyte.String}},
+		"score":      {Type: []airbyte.PropType{airbyte.Number, airbyte.Null}},
+		"active":     {Type: []airbyte.PropType{airbyte.Boolean, airbyte.Null}},
+		"updated_at": {Type: []airbyte.PropType{airbyte.String, airbyte.Null}, AirbyteType: airbyte.TimestampWOTZ},
+		"avatar":     {Type: []airbyte.PropType{airbyte.String, airbyte.Null}},
+	} {
+		if got := users.JSONSchema.Properties[field].PropertyType; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected users.%s to be %+v, got %+v", field, want, got)
+		}
+	}
+	// sqlite only keeps nulls out of a single column INTEGER PRIMARY KEY
+	if got := disc.Catalog.Streams[1].JSONSchema.Properties["group_id"].Type; !reflect.DeepEqual(got, []airbyte.PropType{airbyte.Integer, airbyte.Null}) {
+		t.Errorf("expected memberships.group_id to be nullable, got %v", got)
+	}
+	if !reflect.DeepEqual(users.SourceDefinedPrimaryKey, [][]string{{"id"}}) ||
+		!reflect.DeepEqual(disc.Catalog.Streams[1].SourceDefinedPrimaryKey, [][]string{{"group_id"}, {"user_id"}}) ||
+		disc.Catalog.Streams[0].SourceDefinedPrimaryKey != nil {
+		t.Errorf("unexpected primary keys %v, %v and %v", users.SourceDefinedPrimaryKey,
+			disc.Catalog.Streams[1].SourceDefinedPrimaryKey, disc.Catalog.Streams[0].SourceDefinedPrimaryKey)
+	}
+
+	cat := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental)
+	cat.Streams = cat.Streams[1:]
+	cat.Streams[0].SyncMode = airbyte.SyncModeFullRefresh
+	delete(cat.Streams[0].Stream.JSONSchema.Properties, "role")
+	cat.Streams[1].CursorField = []string{"updated_at"}
+	out, err = airbytetest.Read(src, config, cat, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Records["memberships"]) != 2 || len(out.Records["users"]) != 3 {
+		t.Fatalf("expected 2 memberships and 3 users, got %d and %d", len(out.Records["memberships"]), len(out.Records["users"]))
+	}
+	var membership, user map[string]interface{}
+	if err := out.Records["memberships"][0].Unmarshal(&membership); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := membership["role"]; ok || membership["group_id"] != 10.0 {
+		t.Errorf("expected a membership without role, got %v", membership)
+	}
+	if err := out.Records["users"][1].Unmarshal(&user); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"id": 2.0, "name": "bob", "score": 1.5, "active": false,
+		"updated_at": "2023-01-02T00:00:00", "avatar": "AQ=="}
+	if !reflect.DeepEqual(user, want) {
+		t.Errorf("expected %v, got %v", want, user)
+	}
+	var st State
+	if err := out.LastState(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st["users"] != (StreamState{CursorField: "updated_at", Cursor: "2023-01-03T00:00:00"}) || len(st) != 1 {
+		t.Fatalf("expected the users cursor at the third user, got %+v", st)
+	}
+
+	// the next sync reads the last user again and the rows updated since
+	exec(t, db, `UPDATE users SET name = 'ada2', updated_at = ? WHERE id = 1`, jan.AddDate(0, 1, 0))
+	exec(t, db, `INSERT INTO users (id, name, updated_at) VALUES (4, 'di', ?)`, jan.AddDate(0, 0, 10))
+	out, err = airbytetest.Read(src, config, cat, out.States[len(out.States)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []float64
+	for _, r := range out.Records["users"] {
+		var u map[string]interface{}
+		if err := r.Unmarshal(&u); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u["id"].(float64))
+	}
+	if !reflect.DeepEqual(ids, []float64{3, 4, 1}) {
+		t.Fatalf("expected users 3, 4 and 1 in cursor order, got %v", ids)
+	}
+}
+
+func TestCheckpointRows(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "events.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exec(t, db, `CREATE TABLE events (seq BIGINT, kind TEXT)`)
+	exec(t, db, `INSERT INTO events VALUES (3, 'c'), (1, 'a'), (NULL, 'x'), (2, 'b'), (5, 'e')`)
+
+	src := Source{Driver: "sqlite", CheckpointRows: 2}
+	config := map[string]interface{}{"dsn": dsn}
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental)
+	cat.Streams[0].CursorField = []string{"seq"}
+	out, err := airbytetest.Read(src, config, cat, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cursors []string
+	for _, raw := range out.States {
+		var st State
+		if err := json.Unmarshal(raw, &st); err != nil {
+			t.Fatal(err)
+		}
+		cursors = append(cursors, st["events"].Cursor)
+	}
+	// rows without a cursor are read first and don't move it
+	if len(out.Records["events"]) != 5 || !reflect.DeepEqual(cursors, []string{"1", "3", "5"}) {
+		t.Fatalf("expected 5 events and cursors 1, 3 and 5, got %d events and %v", len(out.Records["events"]), cursors)
+	}
+
+	cat.Streams[0].CursorField = nil
+	if _, err := airbytetest.Read(src, config, cat, nil); err == nil {
+		t.Fatal("expected an error without a cursor field")
+	}
+}
+
+func TestTextTimestampCursor(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "logs.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// the driver scans smalldatetime columns as the text they store, like mysql does without parseTime
+	exec(t, db, `CREATE TABLE logs (id INTEGER, at SMALLDATETIME)`)
+	exec(t, db, `INSERT INTO logs VALUES (1, '2023-01-01 10:00:00'), (2, '2023-01-02 10:00:00')`)
+
+	src := Source{Driver: "sqlite"}
+	config := map[string]interface{}{"dsn": dsn}
+	disc, err := airbytetest.Discover(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat := airbytetest.ConfigureCatalog(disc.Catalog, airbyte.SyncModeIncremental)
+	cat.Streams[0].CursorField = []string{"at"}
+	out, err := airbytetest.Read(src, config, cat, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the next sync compares the text cursor to the stored text
+	exec(t, db, `INSERT INTO logs VALUES (3, '2023-01-03 10:00:00')`)
+	out, err = airbytetest.Read(src, config, cat, out.States[len(out.States)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []float64
+	for _, r := range out.Records["logs"] {
+		var l map[string]interface{}
+		if err := r.Unmarshal(&l); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, l["id"].(float64))
+	}
+	if !reflect.DeepEqual(ids, []float64{2, 3}) {
+		t.Fatalf("expected logs 2 and 3, got %v", ids)
+	}
+}
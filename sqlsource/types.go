@@ -0,0 +1,209 @@
+package sqlsource
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitstrapped/airbyte"
+)
+
+// kind is the json representation of a database type
+type kind int
+
+const (
+	kindString kind = iota
+	kindInteger
+	kindNumber
+	kindBoolean
+	kindDate
+	kindTime
+	kindTimestamp
+	kindTimestampTZ
+	kindJSON
+	kindBinary
+)
+
+const timestampLayout = "2006-01-02T15:04:05.999999999"
+
+// kindOf maps a database type to its kind, type names nobody knows fall back to the sqlite affinity rules
+// so "VARCHAR2" is a string and "UNSIGNED BIG INT" an integer
+func kindOf(dbType string) kind {
+	t := strings.ToLower(strings.TrimSpace(dbType))
+	// numeric(10,2) is numeric and timestamp(3) with time zone a timestamp with time zone
+	if i := strings.IndexByte(t, '('); i >= 0 {
+		if j := strings.IndexByte(t[i:], ')'); j >= 0 {
+			t = strings.Join(strings.Fields(t[:i]+" "+t[i+j+1:]), " ")
+		}
+	}
+
+	switch t {
+	case "boolean", "bool":
+		return kindBoolean
+	case "smallint", "integer", "int", "int2", "int4", "int8", "bigint", "tinyint", "mediumint",
+		"smallserial", "serial", "bigserial", "year":
+		return kindInteger
+	case "real", "float", "float4", "float8", "double", "double precision", "numeric", "decimal":
+		return kindNumber
+	case "date":
+		return kindDate
+	case "time", "time without time zone", "time with time zone", "timetz":
+		return kindTime
+	case "timestamp", "timestamp without time zone", "datetime", "datetime2", "smalldatetime":
+		return kindTimestamp
+	case "timestamp with time zone", "timestamptz", "datetimeoffset":
+		return kindTimestampTZ
+	case "json", "jsonb":
+		return kindJSON
+	case "bytea", "blob", "binary", "varbinary", "tinyblob", "mediumblob", "longblob", "image":
+		return kindBinary
+	case "interval", "point":
+		return kindString
+	}
+
+	switch {
+	case strings.Contains(t, "int"):
+		return kindInteger
+	case strings.Contains(t, "char"), strings.Contains(t, "clob"), strings.Contains(t, "text"):
+		return kindString
+	case strings.Contains(t, "blob"):
+		return kindBinary
+	case strings.Contains(t, "real"), strings.Contains(t, "floa"), strings.Contains(t, "doub"),
+		strings.Contains(t, "numeric"), strings.Contains(t, "decimal"):
+		return kindNumber
+	case strings.Contains(t, "bool"):
+		return kindBoolean
+	case strings.Contains(t, "timestamp"), strings.Contains(t, "datetime"):
+		return kindTimestamp
+	case strings.Contains(t, "date"):
+		return kindDate
+	}
+	return kindString
+}
+
+// spec returns the json schema of a column of the kind, binary values are base64 encoded strings
+func (k kind) spec(nullable bool) airbyte.PropertySpec {
+	var spec airbyte.PropertySpec
+	switch k {
+	case kindInteger:
+		spec.Type = []airbyte.PropType{airbyte.Integer}
+	case kindNumber:
+		spec.Type = []airbyte.PropType{airbyte.Number}
+	case kindBoolean:
+		spec.Type = []airbyte.PropType{airbyte.Boolean}
+	case kindDate:
+		spec.Type = []airbyte.PropType{airbyte.String}
+		spec.Format = airbyte.Date
+	case kindTimestamp:
+		spec.Type = []airbyte.PropType{airbyte.String}
+		spec.Format = "date-time"
+		spec.AirbyteType = airbyte.TimestampWOTZ
+	case kindTimestampTZ:
+		spec.Type = []airbyte.PropType{airbyte.String}
+		spec.Format = "date-time"
+		spec.AirbyteType = airbyte.TimestampWithTZ
+	case kindJSON:
+		spec.Type = []airbyte.PropType{airbyte.Object, airbyte.Array}
+	default:
+		spec.Type = []airbyte.PropType{airbyte.String}
+	}
+	if nullable {
+		spec.Type = append(spec.Type, airbyte.Null)
+	}
+	return spec
+}
+
+// value converts a value scanned from a column of the kind to its json value
+// Drivers return numerics and texts as bytes or strings and booleans as integers, times are formatted
+// like their schema says
+func (k kind) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		if k == kindBinary {
+			return v
+		}
+		return k.text(string(v))
+	case string:
+		return k.text(v)
+	case time.Time:
+		switch k {
+		case kindDate:
+			return v.Format("2006-01-02")
+		case kindTime:
+			return v.Format("15:04:05.999999999")
+		case kindTimestamp:
+			return v.Format(timestampLayout)
+		}
+		return v.Format(time.RFC3339Nano)
+	case int64:
+		if k == kindBoolean {
+			return v != 0
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+	}
+	return v
+}
+
+func (k kind) text(s string) interface{} {
+	switch k {
+	case kindInteger, kindNumber:
+		if isNumber(s) {
+			return json.Number(s)
+		}
+	case kindBoolean:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case kindJSON:
+		if json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+	}
+	return s
+}
+
+// cursor formats the json value of a cursor column for the state
+func cursor(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// bind parses a cursor of the state into the query parameter it's compared to, timestamps are bound as times
+// so drivers format them like the values they store
+// Timestamps the driver scanned as text, like mysql without parseTime does, are bound as the text they were read as
+func (k kind) bind(cursor string) (interface{}, error) {
+	layout := time.RFC3339Nano
+	switch k {
+	case kindInteger:
+		return strconv.ParseInt(cursor, 10, 64)
+	case kindTimestamp:
+		layout = timestampLayout
+		fallthrough
+	case kindTimestampTZ:
+		if t, err := time.Parse(layout, cursor); err == nil {
+			return t, nil
+		}
+	}
+	return cursor, nil
+}
+
+// isNumber reports whether s is a json number
+func isNumber(s string) bool {
+	return s != "" && (s[0] == '-' || s[0] >= '0' && s[0] <= '9') && s[len(s)-1] >= '0' && s[len(s)-1] <= '9' &&
+		json.Valid([]byte(s))
+}
@@ -0,0 +1,72 @@
+package sqlsource
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKindOf(t *testing.T) {
+	for typ, want := range map[string]kind{
+		"character varying":           kindString,
+		"NUMERIC(10, 2)":              kindNumber,
+		"timestamp(3) with time zone": kindTimestampTZ,
+		"timestamp without time zone": kindTimestamp,
+		"datetime":                    kindTimestamp,
+		"jsonb":                       kindJSON,
+		"bytea":                       kindBinary,
+		"interval":                    kindString,
+		"UNSIGNED BIG INT":            kindInteger,
+		"VARCHAR2(30)":                kindString,
+		"DOUBLE PRECISION":            kindNumber,
+		"USER-DEFINED":                kindString,
+		"":                            kindString,
+		"tinyint":                     kindInteger,
+		"time with time zone":         kindTime,
+		"date":                        kindDate,
+		"boolean":                     kindBoolean,
+	} {
+		if got := kindOf(typ); got != want {
+			t.Errorf("%q: expected kind %d, got %d", typ, want, got)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	at := time.Date(2023, 1, 2, 3, 4, 5, 600, time.FixedZone("", 3600))
+	for _, c := range []struct {
+		kind kind
+		in   interface{}
+		want interface{}
+	}{
+		{kindNumber, []byte("12.30"), json.Number("12.30")},
+		{kindInteger, "007", "007"},
+		{kindBoolean, int64(1), true},
+		{kindJSON, []byte(`{"a": 1}`), json.RawMessage(`{"a": 1}`)},
+		{kindString, []byte("x"), "x"},
+		{kindBinary, []byte("x"), []byte("x")},
+		{kindDate, at, "2023-01-02"},
+		{kindTimestamp, at, "2023-01-02T03:04:05.0000006"},
+		{kindTimestampTZ, at, "2023-01-02T03:04:05.0000006+01:00"},
+		{kindNumber, nil, nil},
+	} {
+		if got := c.kind.value(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v as kind %d: expected %#v, got %#v", c.in, c.kind, c.want, got)
+		}
+	}
+}
+
+func TestSchemaFilter(t *testing.T) {
+	filter, args := Postgres.(informationSchema).schemaFilter("c.table_schema", []string{"public", "billing"})
+	if filter != "c.table_schema IN ($1, $2)" || !reflect.DeepEqual(args, []interface{}{"public", "billing"}) {
+		t.Errorf("unexpected filter %q %v", filter, args)
+	}
+	filter, args = MySQL.(informationSchema).schemaFilter("c.table_schema", nil)
+	if filter != "c.table_schema NOT IN (?, ?, ?, ?, ?)" || len(args) != len(systemSchemas) {
+		t.Errorf("unexpected filter %q %v", filter, args)
+	}
+	if q := MySQL.Quote("a`b"); q != "`a``b`" {
+		t.Errorf("unexpected quoted identifier %s", q)
+	}
+}
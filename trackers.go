@@ -9,9 +9,14 @@ type MessageTracker struct {
 	Record RecordWriter
 	// Log logs out to airbyte
 	Log LogWriter
+	// Config emits an updated connector config which airbyte persists for the next syncs,
+	// e.g. after a refresh token was rotated and the old one stopped working
+	Config ConfigWriter
 }
 
 // LogTracker is a single struct which holds a tracker which can be used for logs
 type LogTracker struct {
 	Log LogWriter
+	// Config emits an updated connector config, see MessageTracker
+	Config ConfigWriter
 }